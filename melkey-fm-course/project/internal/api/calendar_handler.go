@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/calendar"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/tokens"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// calendarTokenTTL is long on purpose: calendar apps keep polling the same
+// URL, so the token is rotated by minting a new one rather than by expiry.
+const calendarTokenTTL = 10 * 365 * 24 * time.Hour
+
+type CalendarHandler struct {
+	workoutStore store.WorkoutStore
+	tokenStore   store.TokenStore
+	userStore    store.UserStore
+	logger       *log.Logger
+}
+
+func NewCalendarHandler(workoutStore store.WorkoutStore, tokenStore store.TokenStore, userStore store.UserStore, logger *log.Logger) *CalendarHandler {
+	return &CalendarHandler{
+		workoutStore: workoutStore,
+		tokenStore:   tokenStore,
+		userStore:    userStore,
+		logger:       logger,
+	}
+}
+
+// HandleCreateCalendarToken mints the secret feed URL for the current user.
+// Any previous feed URL stops working.
+func (ch *CalendarHandler) HandleCreateCalendarToken(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	err := ch.tokenStore.DeleteAllTokensForUser(user.ID, tokens.ScopeCalendar)
+	if err != nil {
+		ch.logger.Printf("ERROR: deleteCalendarTokens: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	token, err := ch.tokenStore.CreateNewToken(user.ID, calendarTokenTTL, tokens.ScopeCalendar)
+	if err != nil {
+		ch.logger.Printf("ERROR: createCalendarToken: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"calendar": utils.Envelope{
+		"token": token.Plaintext,
+		"url":   fmt.Sprintf("/calendar/%s.ics", token.Plaintext),
+	}})
+}
+
+func (ch *CalendarHandler) HandleGetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	user, err := ch.userStore.GetUserToken(tokens.ScopeCalendar, token)
+	if err != nil {
+		ch.logger.Printf("ERROR: getCalendarUser: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	workouts, err := ch.workoutStore.GetCalendarWorkouts(user.ID)
+	if err != nil {
+		ch.logger.Printf("ERROR: getCalendarWorkouts: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	cal := &calendar.Calendar{Name: user.Username + " workouts", Location: user.Location()}
+	for _, workout := range workouts {
+		event, ok := calendarEvent(workout)
+		if ok {
+			cal.Events = append(cal.Events, event)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="workouts.ics"`)
+	_, err = cal.WriteTo(w)
+	if err != nil {
+		ch.logger.Printf("ERROR: writeCalendar: %v", err)
+	}
+}
+
+// calendarEvent turns a plan into a (possibly recurring) event, and a
+// completed workout into an override of the occurrence it fulfilled.
+func calendarEvent(workout *store.Workout) (calendar.Event, bool) {
+	event := calendar.Event{
+		Duration:    time.Duration(workout.DurationSeconds) * time.Second,
+		Summary:     workout.Title,
+		Description: workout.Description,
+	}
+
+	switch {
+	case workout.ScheduledAt != nil:
+		event.UID = workoutUID(workout.ID)
+		event.Start = *workout.ScheduledAt
+		event.Status = "CONFIRMED"
+		if workout.Recurrence != nil {
+			rule, err := calendar.ParseRule(*workout.Recurrence)
+			if err != nil {
+				return event, false
+			}
+			event.Rule = rule
+		}
+	case workout.PlannedWorkoutID != nil && workout.PlannedFor != nil:
+		event.UID = workoutUID(int(*workout.PlannedWorkoutID))
+		event.Start = workout.PerformedAt
+		event.RecurrenceID = workout.PlannedFor
+		event.Summary = "✓ " + workout.Title
+		event.Description = strings.TrimSpace(fmt.Sprintf("Completed as workout %d. %s", workout.ID, workout.Description))
+		event.Updated = workout.CreatedAt
+	default:
+		return event, false
+	}
+
+	return event, true
+}
+
+func workoutUID(id int) string {
+	return fmt.Sprintf("workout-%d@workouts", id)
+}
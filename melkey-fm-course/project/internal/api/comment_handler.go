@@ -0,0 +1,307 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+)
+
+const maxCommentLength = 2000
+
+var errInvalidComment = errors.New("body must be between 1 and 2000 characters")
+
+type commentRequest struct {
+	Body     string `json:"body"`
+	ParentID *int64 `json:"parent_id"`
+}
+
+type reactionRequest struct {
+	Kind string `json:"kind"`
+}
+
+type CommentHandler struct {
+	commentStore store.CommentStore
+	workoutStore store.WorkoutStore
+	followStore  store.FollowStore
+	logger       *log.Logger
+}
+
+func NewCommentHandler(commentStore store.CommentStore, workoutStore store.WorkoutStore, followStore store.FollowStore, logger *log.Logger) *CommentHandler {
+	return &CommentHandler{
+		commentStore: commentStore,
+		workoutStore: workoutStore,
+		followStore:  followStore,
+		logger:       logger,
+	}
+}
+
+// visibleWorkout loads the workout named in the path and writes the error
+// response itself when the caller cannot see it.
+func (ch *CommentHandler) visibleWorkout(w http.ResponseWriter, r *http.Request) *store.Workout {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	workout, err := ch.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		ch.logger.Printf("ERROR: getWorkoutByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+	if workout == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	visible, err := canViewWorkout(r, ch.followStore, workout)
+	if err != nil {
+		ch.logger.Printf("ERROR: isFollowing: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+	if !visible {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	return workout
+}
+
+func (ch *CommentHandler) HandleListComments(w http.ResponseWriter, r *http.Request) {
+	workout := ch.visibleWorkout(w, r)
+	if workout == nil {
+		return
+	}
+
+	comments, err := ch.commentStore.ListComments(int64(workout.ID))
+	if err != nil {
+		ch.logger.Printf("ERROR: listComments: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"comments": comments})
+}
+
+func (ch *CommentHandler) HandleCreateComment(w http.ResponseWriter, r *http.Request) {
+	workout := ch.visibleWorkout(w, r)
+	if workout == nil {
+		return
+	}
+
+	var req commentRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	body, err := validateCommentBody(req.Body)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	comment := &store.Comment{
+		WorkoutID: int64(workout.ID),
+		UserID:    user.ID,
+		ParentID:  req.ParentID,
+		Body:      body,
+	}
+
+	err = ch.commentStore.CreateComment(comment)
+	if errors.Is(err, store.ErrInvalidParent) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		ch.logger.Printf("ERROR: createComment: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create comment"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"comment": comment})
+}
+
+// HandleUpdateComment lets authors edit their own comments.
+func (ch *CommentHandler) HandleUpdateComment(w http.ResponseWriter, r *http.Request) {
+	commentID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req commentRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	body, err := validateCommentBody(req.Body)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	comment, err := ch.commentStore.GetComment(commentID)
+	if err != nil {
+		ch.logger.Printf("ERROR: getComment: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if comment == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	if comment.UserID != user.ID {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you can only edit your own comments"})
+		return
+	}
+
+	comment.Body = body
+	err = ch.commentStore.UpdateComment(comment)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		ch.logger.Printf("ERROR: updateComment: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update comment"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"comment": comment})
+}
+
+// HandleDeleteComment lets authors delete their comments and workout owners
+// moderate any comment on their workout.
+func (ch *CommentHandler) HandleDeleteComment(w http.ResponseWriter, r *http.Request) {
+	commentID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	comment, err := ch.commentStore.GetComment(commentID)
+	if err != nil {
+		ch.logger.Printf("ERROR: getComment: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if comment == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	if comment.UserID != user.ID {
+		workout, err := ch.workoutStore.GetWorkoutByID(comment.WorkoutID)
+		if err != nil {
+			ch.logger.Printf("ERROR: getWorkoutByID: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if workout == nil || workout.UserID == nil || *workout.UserID != user.ID {
+			utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to delete this comment"})
+			return
+		}
+	}
+
+	err = ch.commentStore.DeleteComment(commentID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		ch.logger.Printf("ERROR: deleteComment: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete comment"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleSetReaction records the caller's reaction, replacing any earlier one.
+func (ch *CommentHandler) HandleSetReaction(w http.ResponseWriter, r *http.Request) {
+	workout := ch.visibleWorkout(w, r)
+	if workout == nil {
+		return
+	}
+
+	var req reactionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	if !slices.Contains(store.ReactionKinds, req.Kind) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "kind must be one of " + strings.Join(store.ReactionKinds, " ")})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = ch.commentStore.SetReaction(int64(workout.ID), user.ID, req.Kind)
+	if err != nil {
+		ch.logger.Printf("ERROR: setReaction: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to save reaction"})
+		return
+	}
+
+	ch.writeEngagement(w, int64(workout.ID), user.ID)
+}
+
+func (ch *CommentHandler) HandleDeleteReaction(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = ch.commentStore.DeleteReaction(workoutID, user.ID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		ch.logger.Printf("ERROR: deleteReaction: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete reaction"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ch *CommentHandler) writeEngagement(w http.ResponseWriter, workoutID, viewerID int64) {
+	engagement, err := ch.commentStore.GetEngagement(workoutID, viewerID)
+	if err != nil {
+		ch.logger.Printf("ERROR: getEngagement: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"engagement": engagement})
+}
+
+func validateCommentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > maxCommentLength {
+		return "", errInvalidComment
+	}
+	return body, nil
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+)
+
+const (
+	defaultFeedPageSize = 20
+	maxFeedPageSize     = 100
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+type FollowHandler struct {
+	followStore store.FollowStore
+	logger      *log.Logger
+}
+
+func NewFollowHandler(followStore store.FollowStore, logger *log.Logger) *FollowHandler {
+	return &FollowHandler{
+		followStore: followStore,
+		logger:      logger,
+	}
+}
+
+func (fh *FollowHandler) HandleFollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	if followeeID == user.ID {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "you cannot follow yourself"})
+		return
+	}
+
+	follow, err := fh.followStore.Follow(user.ID, followeeID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		fh.logger.Printf("ERROR: follow: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"follow": follow})
+}
+
+func (fh *FollowHandler) HandleUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = fh.followStore.Unfollow(user.ID, followeeID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		fh.logger.Printf("ERROR: unfollow: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (fh *FollowHandler) HandleListFollowRequests(w http.ResponseWriter, r *http.Request) {
+	fh.listFollowers(w, r, store.FollowPending)
+}
+
+func (fh *FollowHandler) HandleListFollowers(w http.ResponseWriter, r *http.Request) {
+	fh.listFollowers(w, r, store.FollowAccepted)
+}
+
+func (fh *FollowHandler) listFollowers(w http.ResponseWriter, r *http.Request, status store.FollowStatus) {
+	user := middleware.GetUser(r)
+
+	followers, err := fh.followStore.ListFollowers(user.ID, status)
+	if err != nil {
+		fh.logger.Printf("ERROR: listFollowers: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"followers": followers})
+}
+
+func (fh *FollowHandler) HandleListFollowing(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	following, err := fh.followStore.ListFollowing(user.ID)
+	if err != nil {
+		fh.logger.Printf("ERROR: listFollowing: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"following": following})
+}
+
+// HandleAcceptFollowRequest approves the request from the user in the path.
+func (fh *FollowHandler) HandleAcceptFollowRequest(w http.ResponseWriter, r *http.Request) {
+	followerID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = fh.followStore.AcceptFollow(followerID, user.ID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		fh.logger.Printf("ERROR: acceptFollow: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRemoveFollower rejects a pending request or removes an existing
+// follower.
+func (fh *FollowHandler) HandleRemoveFollower(w http.ResponseWriter, r *http.Request) {
+	followerID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = fh.followStore.Unfollow(followerID, user.ID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		fh.logger.Printf("ERROR: removeFollower: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetFeed pages through followed users' workouts with an opaque cursor;
+// next_cursor is empty once the feed is exhausted.
+func (fh *FollowHandler) HandleGetFeed(w http.ResponseWriter, r *http.Request) {
+	limit, _, err := readPage(r, defaultFeedPageSize, maxFeedPageSize)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	var after *store.FeedCursor
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		after, err = decodeFeedCursor(value)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	items, err := fh.followStore.GetFeed(user.ID, after, limit)
+	if err != nil {
+		fh.logger.Printf("ERROR: getFeed: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	nextCursor := ""
+	if len(items) == limit {
+		last := items[len(items)-1]
+		nextCursor = encodeFeedCursor(store.FeedCursor{PerformedAt: last.PerformedAt, WorkoutID: last.ID})
+	}
+
+	for _, item := range items {
+		workoutOut(item.Workout, prefs)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"feed": items, "next_cursor": nextCursor, "units": prefs})
+}
+
+func encodeFeedCursor(c store.FeedCursor) string {
+	raw := fmt.Sprintf("%d:%d", c.PerformedAt.UnixNano(), c.WorkoutID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeFeedCursor(value string) (*store.FeedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	workoutID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &store.FeedCursor{PerformedAt: time.Unix(0, n), WorkoutID: workoutID}, nil
+}
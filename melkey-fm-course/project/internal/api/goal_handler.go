@@ -0,0 +1,160 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+)
+
+type createGoalRequest struct {
+	Kind         store.GoalKind    `json:"kind"`
+	Period       store.StatsPeriod `json:"period"`
+	Target       float64           `json:"target"`
+	ExerciseName string            `json:"exercise_name"`
+}
+
+type GoalHandler struct {
+	goalStore store.GoalStore
+	logger    *log.Logger
+}
+
+func NewGoalHandler(goalStore store.GoalStore, logger *log.Logger) *GoalHandler {
+	return &GoalHandler{
+		goalStore: goalStore,
+		logger:    logger,
+	}
+}
+
+func (gh *GoalHandler) HandleListGoals(w http.ResponseWriter, r *http.Request) {
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	goals, err := gh.goalStore.ListGoalProgress(user.ID, time.Now())
+	if err != nil {
+		gh.logger.Printf("ERROR: listGoalProgress: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	for i := range goals {
+		if goals[i].Kind == store.GoalLift {
+			goals[i].Target = prefs.WeightOut(goals[i].Target)
+			goals[i].Current = prefs.WeightOut(goals[i].Current)
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"goals": goals, "units": prefs})
+}
+
+func (gh *GoalHandler) HandleCreateGoal(w http.ResponseWriter, r *http.Request) {
+	var req createGoalRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	goal, err := goalFromRequest(req, user.ID)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if goal.Kind == store.GoalLift {
+		goal.Target = prefs.WeightIn(goal.Target)
+	}
+
+	err = gh.goalStore.CreateGoal(goal)
+	if err != nil {
+		gh.logger.Printf("ERROR: createGoal: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create goal"})
+		return
+	}
+
+	// workouts logged before the goal existed may already meet it
+	err = gh.goalStore.EvaluateGoals(user.ID, time.Now())
+	if err != nil {
+		gh.logger.Printf("ERROR: evaluateGoals: %v", err)
+	}
+
+	if goal.Kind == store.GoalLift {
+		goal.Target = prefs.WeightOut(goal.Target)
+	}
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"goal": goal, "units": prefs})
+}
+
+func goalFromRequest(req createGoalRequest, userID int64) (*store.Goal, error) {
+	if !req.Kind.Valid() {
+		return nil, errors.New("kind must be one of workouts, calories, duration_seconds or lift")
+	}
+	if req.Target <= 0 {
+		return nil, errors.New("target must be a positive number")
+	}
+
+	goal := &store.Goal{
+		UserID: userID,
+		Kind:   req.Kind,
+		Target: req.Target,
+	}
+
+	if req.Kind == store.GoalLift {
+		name := strings.TrimSpace(req.ExerciseName)
+		if name == "" {
+			return nil, errors.New("lift goals need an exercise_name")
+		}
+		if req.Period != "" {
+			return nil, errors.New("lift goals have no period")
+		}
+		goal.ExerciseName = &name
+		return goal, nil
+	}
+
+	if req.Period != store.StatsPeriodWeek && req.Period != store.StatsPeriodMonth {
+		return nil, errors.New("period must be week or month")
+	}
+	if req.ExerciseName != "" {
+		return nil, errors.New("exercise_name is only used by lift goals")
+	}
+	goal.Period = req.Period
+	return goal, nil
+}
+
+func (gh *GoalHandler) HandleDeleteGoal(w http.ResponseWriter, r *http.Request) {
+	goalID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = gh.goalStore.DeleteGoal(goalID, user.ID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		gh.logger.Printf("ERROR: deleteGoal: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete goal"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
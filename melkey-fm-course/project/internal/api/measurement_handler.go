@@ -0,0 +1,304 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/units"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+)
+
+const (
+	defaultMeasurementWindow = 7
+	maxMeasurementWindow     = 90
+)
+
+var errInvalidMeasurementKind = errors.New("unknown measurement kind")
+
+type measurementRequest struct {
+	Kind       *store.MeasurementKind `json:"kind"`
+	Value      *float64               `json:"value"`
+	Notes      *string                `json:"notes"`
+	MeasuredAt *time.Time             `json:"measured_at"`
+}
+
+type MeasurementHandler struct {
+	measurementStore store.MeasurementStore
+	logger           *log.Logger
+}
+
+func NewMeasurementHandler(measurementStore store.MeasurementStore, logger *log.Logger) *MeasurementHandler {
+	return &MeasurementHandler{
+		measurementStore: measurementStore,
+		logger:           logger,
+	}
+}
+
+func (mh *MeasurementHandler) HandleCreateMeasurement(w http.ResponseWriter, r *http.Request) {
+	var req measurementRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	if req.Kind == nil || req.Value == nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "kind and value are required"})
+		return
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	m := &store.Measurement{UserID: user.ID}
+	err = applyMeasurementRequest(m, req, prefs)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = mh.measurementStore.CreateMeasurement(m)
+	if err != nil {
+		mh.logger.Printf("ERROR: createMeasurement: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create measurement"})
+		return
+	}
+
+	measurementOut(m, prefs)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"measurement": m})
+}
+
+// HandleListMeasurements accepts kind, from, to (YYYY-MM-DD in the user's
+// timezone or RFC 3339) and window, the number of measurements the moving
+// average spans.
+func (mh *MeasurementHandler) HandleListMeasurements(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	user := middleware.GetUser(r)
+
+	filter := store.MeasurementFilter{
+		UserID: user.ID,
+		Kind:   store.MeasurementKind(query.Get("kind")),
+		Window: defaultMeasurementWindow,
+	}
+
+	if filter.Kind != "" {
+		if _, ok := store.MeasurementKinds[filter.Kind]; !ok {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": errInvalidMeasurementKind.Error()})
+			return
+		}
+	}
+
+	for _, bound := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := parseStatsTime(value, user.Location())
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid " + bound.name + ", expected YYYY-MM-DD or RFC 3339"})
+			return
+		}
+		*bound.dst = &t
+	}
+
+	if value := query.Get("window"); value != "" {
+		window, err := strconv.Atoi(value)
+		if err != nil || window < 1 || window > maxMeasurementWindow {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "window must be between 1 and 90"})
+			return
+		}
+		filter.Window = window
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	measurements, err := mh.measurementStore.ListMeasurements(filter)
+	if err != nil {
+		mh.logger.Printf("ERROR: listMeasurements: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	for i := range measurements {
+		measurementOut(&measurements[i], prefs)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurements": measurements})
+}
+
+func (mh *MeasurementHandler) HandleGetMeasurement(w http.ResponseWriter, r *http.Request) {
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	m := mh.ownMeasurement(w, r)
+	if m == nil {
+		return
+	}
+
+	measurementOut(m, prefs)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurement": m})
+}
+
+func (mh *MeasurementHandler) HandleUpdateMeasurement(w http.ResponseWriter, r *http.Request) {
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	m := mh.ownMeasurement(w, r)
+	if m == nil {
+		return
+	}
+
+	var req measurementRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	err = applyMeasurementRequest(m, req, prefs)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = mh.measurementStore.UpdateMeasurement(m)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		mh.logger.Printf("ERROR: updateMeasurement: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update measurement"})
+		return
+	}
+
+	measurementOut(m, prefs)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurement": m})
+}
+
+func (mh *MeasurementHandler) HandleDeleteMeasurement(w http.ResponseWriter, r *http.Request) {
+	measurementID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = mh.measurementStore.DeleteMeasurement(measurementID, user.ID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		mh.logger.Printf("ERROR: deleteMeasurement: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete measurement"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetRelativeStrength divides each exercise's heaviest logged weight by
+// body weight; ?exercise= narrows it to one exercise.
+func (mh *MeasurementHandler) HandleGetRelativeStrength(w http.ResponseWriter, r *http.Request) {
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	strength, err := mh.measurementStore.GetRelativeStrength(user.ID, r.URL.Query().Get("exercise"))
+	if err != nil {
+		mh.logger.Printf("ERROR: getRelativeStrength: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	// ratios are unitless and stay as computed
+	for i := range strength {
+		rs := &strength[i]
+		rs.MaxWeight = prefs.WeightOut(rs.MaxWeight)
+		rs.BodyWeight = weightOut(rs.BodyWeight, prefs)
+		rs.CurrentBodyWeight = weightOut(rs.CurrentBodyWeight, prefs)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"relative_strength": strength, "units": prefs})
+}
+
+// ownMeasurement loads the caller's measurement named in the path and writes
+// the error response itself when there is none.
+func (mh *MeasurementHandler) ownMeasurement(w http.ResponseWriter, r *http.Request) *store.Measurement {
+	measurementID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	user := middleware.GetUser(r)
+	m, err := mh.measurementStore.GetMeasurement(measurementID, user.ID)
+	if err != nil {
+		mh.logger.Printf("ERROR: getMeasurement: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+	if m == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	return m
+}
+
+// applyMeasurementRequest copies the set fields onto m. A body weight in the
+// request is in the caller's weight unit and is stored in kilograms.
+func applyMeasurementRequest(m *store.Measurement, req measurementRequest, prefs units.Preference) error {
+	if req.Kind != nil {
+		if _, ok := store.MeasurementKinds[*req.Kind]; !ok {
+			return errInvalidMeasurementKind
+		}
+		m.Kind = *req.Kind
+	}
+	if req.Value != nil {
+		if *req.Value <= 0 {
+			return errors.New("value must be a positive number")
+		}
+		m.Value = *req.Value
+	}
+	if req.Value != nil && m.Kind == store.MeasurementBodyWeight {
+		m.Value = prefs.WeightIn(m.Value)
+	}
+	if m.Kind == store.MeasurementBodyFat && m.Value >= 100 {
+		return errors.New("body_fat is a percentage below 100")
+	}
+	if req.Notes != nil {
+		m.Notes = *req.Notes
+	}
+	if req.MeasuredAt != nil {
+		m.MeasuredAt = *req.MeasuredAt
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+)
+
+const (
+	maxSearchLength       = 200
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+)
+
+type SearchHandler struct {
+	searchStore store.SearchStore
+	logger      *log.Logger
+}
+
+func NewSearchHandler(searchStore store.SearchStore, logger *log.Logger) *SearchHandler {
+	return &SearchHandler{
+		searchStore: searchStore,
+		logger:      logger,
+	}
+}
+
+func (sh *SearchHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if text == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "q is required"})
+		return
+	}
+	if utf8.RuneCountInString(text) > maxSearchLength {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "q must be at most 200 characters"})
+		return
+	}
+
+	limit, offset, err := readPage(r, defaultSearchPageSize, maxSearchPageSize)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	hits, err := sh.searchStore.SearchWorkouts(store.SearchQuery{
+		UserID: user.ID,
+		Text:   text,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		sh.logger.Printf("ERROR: searchWorkouts: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"results": hits})
+}
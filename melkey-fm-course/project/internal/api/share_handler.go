@@ -0,0 +1,221 @@
+package api
+
+import (
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/units"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed templates/shared_workout.html
+var shareTemplateFS embed.FS
+
+var sharedWorkoutTemplate = template.Must(template.New("shared_workout.html").
+	Funcs(template.FuncMap{"clock": clock}).
+	ParseFS(shareTemplateFS, "templates/shared_workout.html"))
+
+// clock formats seconds as m:ss, or h:mm:ss from an hour up.
+func clock(seconds int) string {
+	h, m, s := seconds/3600, seconds/60%60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+type sharedWorkoutView struct {
+	*store.SharedWorkout
+	Units units.Preference
+}
+
+type createShareLinkRequest struct {
+	// ExpiresIn is a Go duration such as "72h"; omit both fields for a link
+	// that lasts until revoked.
+	ExpiresIn string     `json:"expires_in"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type ShareHandler struct {
+	shareStore   store.ShareStore
+	workoutStore store.WorkoutStore
+	logger       *log.Logger
+}
+
+func NewShareHandler(shareStore store.ShareStore, workoutStore store.WorkoutStore, logger *log.Logger) *ShareHandler {
+	return &ShareHandler{
+		shareStore:   shareStore,
+		workoutStore: workoutStore,
+		logger:       logger,
+	}
+}
+
+func (sh *ShareHandler) HandleCreateShareLink(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req createShareLinkRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	expiresAt, err := shareExpiry(req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	workout, err := sh.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		sh.logger.Printf("ERROR: getWorkoutByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if workout == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	if workout.UserID == nil || *workout.UserID != user.ID {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you can only share your own workouts"})
+		return
+	}
+
+	link, err := sh.shareStore.CreateShareLink(workoutID, user.ID, expiresAt)
+	if err != nil {
+		sh.logger.Printf("ERROR: createShareLink: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create share link"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"share_link": link, "url": "/s/" + link.Token})
+}
+
+func shareExpiry(req createShareLinkRequest) (*time.Time, error) {
+	if req.ExpiresIn != "" && req.ExpiresAt != nil {
+		return nil, errors.New("set either expires_in or expires_at, not both")
+	}
+
+	if req.ExpiresIn != "" {
+		ttl, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			return nil, errors.New("expires_in must be a positive duration such as 72h")
+		}
+		expiresAt := time.Now().Add(ttl)
+		return &expiresAt, nil
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+	return req.ExpiresAt, nil
+}
+
+func (sh *ShareHandler) HandleListShareLinks(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	links, err := sh.shareStore.ListShareLinks(user.ID)
+	if err != nil {
+		sh.logger.Printf("ERROR: listShareLinks: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"share_links": links})
+}
+
+func (sh *ShareHandler) HandleRevokeShareLink(w http.ResponseWriter, r *http.Request) {
+	linkID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = sh.shareStore.RevokeShareLink(linkID, user.ID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		sh.logger.Printf("ERROR: revokeShareLink: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to revoke share link"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetSharedWorkout serves the read-only view behind a share link. It
+// renders HTML for browsers and JSON otherwise; ?format= overrides the Accept
+// header.
+func (sh *ShareHandler) HandleGetSharedWorkout(w http.ResponseWriter, r *http.Request) {
+	// keep the token out of caches and Referer headers of outgoing links
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+	shared, err := sh.shareStore.GetSharedWorkout(chi.URLParam(r, "token"))
+	if err != nil {
+		sh.logger.Printf("ERROR: getSharedWorkout: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if shared == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// viewers are anonymous, so only ?units= moves them off metric
+	prefs, err := readUnits(r, units.Metric)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	workoutOut(shared.Workout, prefs)
+	shared.Workout.PerformedAt = shared.Workout.PerformedAt.In(store.Location(shared.Timezone))
+
+	if !wantsHTML(r) {
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+			"workout":    shared.Workout,
+			"shared_by":  shared.Username,
+			"expires_at": shared.Link.ExpiresAt,
+			"units":      prefs,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = sharedWorkoutTemplate.Execute(w, sharedWorkoutView{SharedWorkout: shared, Units: prefs})
+	if err != nil {
+		sh.logger.Printf("ERROR: renderSharedWorkout: %v", err)
+	}
+}
+
+func wantsHTML(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "html":
+		return true
+	case "json":
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
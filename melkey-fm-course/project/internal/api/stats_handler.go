@@ -0,0 +1,94 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+)
+
+type StatsHandler struct {
+	statsStore store.StatsStore
+	logger     *log.Logger
+}
+
+func NewStatsHandler(statsStore store.StatsStore, logger *log.Logger) *StatsHandler {
+	return &StatsHandler{
+		statsStore: statsStore,
+		logger:     logger,
+	}
+}
+
+// HandleGetStats buckets the current user's workouts by when they were
+// performed. Buckets follow ?tz= when given, else the user's timezone.
+func (sh *StatsHandler) HandleGetStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	user := middleware.GetUser(r)
+
+	filter := store.StatsFilter{
+		UserID:   user.ID,
+		Period:   store.StatsPeriodWeek,
+		Location: user.Location(),
+	}
+
+	if period := query.Get("period"); period != "" {
+		filter.Period = store.StatsPeriod(period)
+		if !filter.Period.Valid() {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "period must be one of day, week or month"})
+			return
+		}
+	}
+
+	if tz := query.Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tz, expected an IANA timezone name"})
+			return
+		}
+		filter.Location = loc
+	}
+
+	for _, bound := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := parseStatsTime(value, filter.Location)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid " + bound.name + ", expected YYYY-MM-DD or RFC 3339"})
+			return
+		}
+		*bound.dst = &t
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	stats, err := sh.statsStore.GetTrainingStats(filter)
+	if err != nil {
+		sh.logger.Printf("ERROR: getTrainingStats: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	statsOut(stats, prefs)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"stats": stats, "units": prefs})
+}
+
+// parseStatsTime accepts a full RFC 3339 timestamp or a bare date, which is
+// taken as midnight in loc so date ranges line up with the period buckets.
+func parseStatsTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, value, loc)
+}
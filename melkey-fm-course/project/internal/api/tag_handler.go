@@ -0,0 +1,194 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+)
+
+type tagRequest struct {
+	Name string `json:"name"`
+}
+
+type workoutTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type TagHandler struct {
+	tagStore     store.TagStore
+	workoutStore store.WorkoutStore
+	logger       *log.Logger
+}
+
+func NewTagHandler(tagStore store.TagStore, workoutStore store.WorkoutStore, logger *log.Logger) *TagHandler {
+	return &TagHandler{
+		tagStore:     tagStore,
+		workoutStore: workoutStore,
+		logger:       logger,
+	}
+}
+
+func (th *TagHandler) HandleListTags(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	tags, err := th.tagStore.ListTags(user.ID)
+	if err != nil {
+		th.logger.Printf("ERROR: listTags: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"tags": tags})
+}
+
+func (th *TagHandler) HandleCreateTag(w http.ResponseWriter, r *http.Request) {
+	var req tagRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	name, err := store.NormalizeTagName(req.Name)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	tag, err := th.tagStore.CreateTag(user.ID, name)
+	if err != nil {
+		th.logger.Printf("ERROR: createTag: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create tag"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"tag": tag})
+}
+
+func (th *TagHandler) HandleRenameTag(w http.ResponseWriter, r *http.Request) {
+	tagID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req tagRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	tag, err := th.tagStore.RenameTag(tagID, user.ID, req.Name)
+	if errors.Is(err, store.ErrInvalidTag) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, store.ErrTagExists) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		th.logger.Printf("ERROR: renameTag: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to rename tag"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"tag": tag})
+}
+
+func (th *TagHandler) HandleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	tagID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = th.tagStore.DeleteTag(tagID, user.ID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		th.logger.Printf("ERROR: deleteTag: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete tag"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleSetWorkoutTags replaces a workout's tags, creating any the user does
+// not have yet.
+func (th *TagHandler) HandleSetWorkoutTags(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req workoutTagsRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Tags == nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	workout, err := th.workoutStore.SetWorkoutTags(workoutID, user.ID, tags, actorFrom(r))
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		th.logger.Printf("ERROR: setWorkoutTags: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update tags"})
+		return
+	}
+
+	workoutOut(workout, prefs)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout, "units": prefs})
+}
+
+// normalizeTags validates tag names from a request, keeping nil as nil so
+// partial updates can tell "no change" from "remove all tags".
+func normalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		name, err := store.NormalizeTagName(tag)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, name)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+)
+
+type TrashHandler struct {
+	workoutStore store.WorkoutStore
+	retention    time.Duration
+	logger       *log.Logger
+}
+
+func NewTrashHandler(workoutStore store.WorkoutStore, retention time.Duration, logger *log.Logger) *TrashHandler {
+	return &TrashHandler{
+		workoutStore: workoutStore,
+		retention:    retention,
+		logger:       logger,
+	}
+}
+
+type trashedWorkout struct {
+	*store.Workout
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+func (th *TrashHandler) HandleGetTrash(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	workouts, err := th.workoutStore.ListTrashedWorkouts(user.ID)
+	if err != nil {
+		th.logger.Printf("ERROR: listTrashedWorkouts: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	trash := make([]trashedWorkout, 0, len(workouts))
+	for _, workout := range workouts {
+		trash = append(trash, trashedWorkout{
+			Workout: workout,
+			PurgeAt: workout.DeletedAt.Add(th.retention),
+		})
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"trash": trash})
+}
+
+func (th *TrashHandler) HandleRestoreWorkout(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = th.workoutStore.RestoreWorkout(workoutID, user.ID, actorFrom(r))
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		th.logger.Printf("ERROR: restoreWorkout: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to restore workout"})
+		return
+	}
+
+	workout, err := th.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		th.logger.Printf("ERROR: getWorkoutByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	workoutOut(workout, prefs)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout, "units": prefs})
+}
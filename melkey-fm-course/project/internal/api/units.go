@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/units"
+)
+
+// callerUnits is the caller's saved preference, metric for anonymous callers,
+// with any ?units= override applied. Weights in request bodies are read in
+// the same units responses are written in.
+func callerUnits(r *http.Request) (units.Preference, error) {
+	return readUnits(r, userUnits(middleware.GetUser(r)))
+}
+
+func readUnits(r *http.Request, base units.Preference) (units.Preference, error) {
+	value := r.URL.Query().Get("units")
+	if value == "" {
+		return base, nil
+	}
+	return base.Override(value)
+}
+
+func userUnits(user *store.User) units.Preference {
+	if !user.Units.Weight.Valid() || !user.Units.Distance.Valid() {
+		return units.Metric
+	}
+	return user.Units
+}
+
+// workoutIn converts entry weights from the request's units to kilograms.
+func workoutIn(workout *store.Workout, p units.Preference) {
+	for _, entry := range workout.FlatEntries() {
+		entry.Weight = weightIn(entry.Weight, p)
+		for i := range entry.SetDetails {
+			entry.SetDetails[i].Weight = weightIn(entry.SetDetails[i].Weight, p)
+		}
+	}
+}
+
+// workoutOut converts a stored workout to the response's units.
+func workoutOut(workout *store.Workout, p units.Preference) {
+	for _, entry := range workout.FlatEntries() {
+		entry.Weight = weightOut(entry.Weight, p)
+		for i := range entry.SetDetails {
+			entry.SetDetails[i].Weight = weightOut(entry.SetDetails[i].Weight, p)
+		}
+	}
+	if workout.Volume != nil {
+		workout.Volume.Tonnage = p.TonnageOut(workout.Volume.Tonnage)
+	}
+
+	if route := workout.Route; route != nil {
+		routeOut(route, p)
+	}
+}
+
+func routeOut(route *store.WorkoutRoute, p units.Preference) {
+	distance := p.DistanceOut(route.DistanceMeters)
+	route.Distance = &distance
+	if route.PaceSecondsPerKm != nil {
+		pace := p.PaceOut(*route.PaceSecondsPerKm)
+		route.PaceSecondsPerUnit = &pace
+	}
+}
+
+// measurementOut converts body weights; the other kinds have a single unit.
+func measurementOut(m *store.Measurement, p units.Preference) {
+	if m.Kind != store.MeasurementBodyWeight {
+		return
+	}
+	m.Value = p.WeightOut(m.Value)
+	m.MovingAverage = weightOut(m.MovingAverage, p)
+	m.Unit = string(p.Weight)
+}
+
+// statsOut converts tonnage and top weights.
+func statsOut(stats *store.TrainingStats, p units.Preference) {
+	for i := range stats.Periods {
+		period := &stats.Periods[i]
+		period.Tonnage = p.TonnageOut(period.Tonnage)
+		period.TonnageChange = tonnageOut(period.TonnageChange, p)
+	}
+	for i := range stats.Exercises {
+		for j := range stats.Exercises[i].Points {
+			point := &stats.Exercises[i].Points[j]
+			point.MaxWeight = weightOut(point.MaxWeight, p)
+			point.Tonnage = p.TonnageOut(point.Tonnage)
+			point.TonnageMovingAvg = p.TonnageOut(point.TonnageMovingAvg)
+			point.TonnageChange = tonnageOut(point.TonnageChange, p)
+		}
+	}
+}
+
+func weightIn(v *float64, p units.Preference) *float64 {
+	if v == nil {
+		return nil
+	}
+	kg := p.WeightIn(*v)
+	return &kg
+}
+
+func weightOut(kg *float64, p units.Preference) *float64 {
+	if kg == nil {
+		return nil
+	}
+	w := p.WeightOut(*kg)
+	return &w
+}
+
+func tonnageOut(kg *float64, p units.Preference) *float64 {
+	if kg == nil {
+		return nil
+	}
+	t := p.TonnageOut(*kg)
+	return &t
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/units"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+)
+
+type registerUserRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Bio      string `json:"bio"`
+	Private  bool   `json:"is_private"`
+	Timezone string `json:"timezone"`
+}
+
+type updateUserRequest struct {
+	Bio             *string         `json:"bio"`
+	IsPrivate       *bool           `json:"is_private"`
+	WeightUnit      *units.Weight   `json:"weight_unit"`
+	DistanceUnit    *units.Distance `json:"distance_unit"`
+	WeightIncrement *float64        `json:"weight_increment"`
+	Timezone        *string         `json:"timezone"`
+}
+
+type UserHandler struct {
+	userStore store.UserStore
+	logger    *log.Logger
+}
+
+func NewUserHandler(userStore store.UserStore, logger *log.Logger) *UserHandler {
+	return &UserHandler{
+		userStore: userStore,
+		logger:    logger,
+	}
+}
+
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// parseTimezone accepts an IANA name such as "Europe/Berlin" and returns its
+// canonical form. "Local" is refused since it means the server's zone.
+func parseTimezone(name string) (string, error) {
+	if name == "" || name == "Local" {
+		return "", errors.New("timezone must be an IANA name such as Europe/Berlin")
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return "", errors.New("timezone must be an IANA name such as Europe/Berlin")
+	}
+	return loc.String(), nil
+}
+
+func (h *UserHandler) validateRegisterRequest(req *registerUserRequest) error {
+	if req.Username == "" {
+		return errors.New("username is required")
+	}
+	if len(req.Username) > 50 {
+		return errors.New("username cannot be greater than 50 characters")
+	}
+	if req.Email == "" {
+		return errors.New("email is required")
+	}
+	if !emailRegex.MatchString(req.Email) {
+		return errors.New("invalid email format")
+	}
+	if req.Password == "" {
+		return errors.New("password is required")
+	}
+	if req.Timezone != "" {
+		tz, err := parseTimezone(req.Timezone)
+		if err != nil {
+			return err
+		}
+		req.Timezone = tz
+	}
+	return nil
+}
+
+func (h *UserHandler) HandleRegisterUser(w http.ResponseWriter, r *http.Request) {
+	var req registerUserRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding register request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	err = h.validateRegisterRequest(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := &store.User{
+		Username:  req.Username,
+		Email:     req.Email,
+		Bio:       req.Bio,
+		IsPrivate: req.Private,
+		Units:     units.Metric,
+		Timezone:  req.Timezone,
+	}
+
+	err = user.PasswordHash.Set(req.Password)
+	if err != nil {
+		h.logger.Printf("ERROR: hashing password: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	err = h.userStore.CreateUser(user)
+	if err != nil {
+		h.logger.Printf("ERROR: registering user: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"user": user})
+}
+
+// HandleUpdateMe changes the current user's profile settings.
+func (h *UserHandler) HandleUpdateMe(w http.ResponseWriter, r *http.Request) {
+	var req updateUserRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	// copy so the user cached on the request context is left alone
+	user := *middleware.GetUser(r)
+	if req.Bio != nil {
+		user.Bio = *req.Bio
+	}
+	if req.IsPrivate != nil {
+		user.IsPrivate = *req.IsPrivate
+	}
+	if req.WeightUnit != nil {
+		if !req.WeightUnit.Valid() {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "weight_unit must be kg or lb"})
+			return
+		}
+		user.Units.Weight = *req.WeightUnit
+	}
+	if req.DistanceUnit != nil {
+		if !req.DistanceUnit.Valid() {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "distance_unit must be km or mi"})
+			return
+		}
+		user.Units.Distance = *req.DistanceUnit
+	}
+	if req.WeightIncrement != nil {
+		if *req.WeightIncrement < 0 || *req.WeightIncrement > 10 {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "weight_increment must be between 0 and 10"})
+			return
+		}
+		user.Units.WeightIncrement = *req.WeightIncrement
+	}
+	if req.Timezone != nil {
+		tz, err := parseTimezone(*req.Timezone)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		user.Timezone = tz
+	}
+
+	err = h.userStore.UpdateUser(&user)
+	if err != nil {
+		h.logger.Printf("ERROR: updateUser: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": user})
+}
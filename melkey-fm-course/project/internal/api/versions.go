@@ -0,0 +1,263 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+)
+
+// maxVersionedBodyBytes bounds the request bodies DurationMinutes rewrites;
+// anything larger than a workout with its entries is not worth reading.
+const maxVersionedBodyBytes = 1 << 20
+
+var errNotJSON = errors.New("not a JSON document")
+
+// DurationMinutes adapts a handler to the v1 representation, in which every
+// duration_seconds is duration_minutes instead, total_duration_seconds is
+// total_duration_minutes and duration goals are duration_minutes goals with
+// their target and progress in minutes: request bodies are converted
+// to seconds before next sees them and JSON responses back to minutes
+// afterwards, rounded to the nearest minute.
+//
+// Converting at the edge keeps one handler per endpoint instead of a copy for
+// every version. The response is buffered in memory to be rewritten, so
+// handlers that stream or write large bodies must not be wrapped. Endpoints
+// whose v1 representation differs beyond renaming fields should get a handler
+// of their own instead.
+func DurationMinutes(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody {
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxVersionedBodyBytes+1))
+			if err != nil {
+				utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+				return
+			}
+
+			// uploads and other bodies that are not a JSON document are
+			// handed on untouched, for next to accept or reject
+			rewritten := body
+			if len(body) <= maxVersionedBodyBytes {
+				rewritten, err = rewriteJSON(body, minutesToSeconds)
+				if errors.Is(err, errNotJSON) {
+					rewritten, err = body, nil
+				}
+				if err != nil {
+					utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+					return
+				}
+				r.ContentLength = int64(len(rewritten))
+			}
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(rewritten), r.Body))
+		}
+
+		rec := &responseBuffer{header: http.Header{}, status: http.StatusOK}
+		next(rec, r)
+
+		body := rec.body.Bytes()
+		if isJSON(rec.header.Get("Content-Type")) && len(body) > 0 {
+			converted, err := rewriteJSON(body, secondsToMinutes)
+			if err != nil {
+				// next wrote something that is not JSON after all; pass it
+				// along unchanged rather than fail the request
+				converted = body
+			}
+			body = converted
+		}
+
+		for key, values := range rec.header {
+			w.Header()[key] = values
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(rec.status)
+		w.Write(body)
+	}
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// responseBuffer holds a response so it can be rewritten before it is sent.
+// It is only suited to handlers that do not stream.
+type responseBuffer struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rb *responseBuffer) Header() http.Header {
+	return rb.header
+}
+
+func (rb *responseBuffer) WriteHeader(status int) {
+	if rb.wroteHeader {
+		return
+	}
+	rb.status = status
+	rb.wroteHeader = true
+}
+
+func (rb *responseBuffer) Write(p []byte) (int, error) {
+	rb.wroteHeader = true
+	return rb.body.Write(p)
+}
+
+// rewriteJSON applies convert to every object in a JSON document and
+// re-encodes it the way utils.WriteJSON does.
+func rewriteJSON(body []byte, convert func(map[string]any) error) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	err := dec.Decode(&doc)
+	if err != nil {
+		return nil, errNotJSON
+	}
+
+	err = walkObjects(doc, convert)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func walkObjects(v any, fn func(map[string]any) error) error {
+	switch v := v.(type) {
+	case map[string]any:
+		err := fn(v)
+		if err != nil {
+			return err
+		}
+		for _, child := range v {
+			err = walkObjects(child, fn)
+			if err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, child := range v {
+			err := walkObjects(child, fn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// goalDurationMinutes is the v1 kind of store.GoalDurationSeconds.
+const goalDurationMinutes = "duration_minutes"
+
+// durationFields pairs each duration field of v2 with its v1 name.
+var durationFields = [][2]string{
+	{"duration_seconds", "duration_minutes"},
+	{"total_duration_seconds", "total_duration_minutes"},
+}
+
+// minutesToSeconds converts a v1 request. The seconds fields did not exist in
+// v1, so like any unknown field they are ignored.
+func minutesToSeconds(obj map[string]any) error {
+	for _, field := range durationFields {
+		err := renameToSeconds(obj, field[0], field[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	// v1 duration goals count minutes; their targets may be fractional
+	if obj["kind"] == goalDurationMinutes {
+		obj["kind"] = string(store.GoalDurationSeconds)
+		return scale(obj, "target", 60, 1)
+	}
+	return nil
+}
+
+func renameToSeconds(obj map[string]any, secondsKey, minutesKey string) error {
+	delete(obj, secondsKey)
+	value, ok := obj[minutesKey]
+	if !ok {
+		return nil
+	}
+	delete(obj, minutesKey)
+
+	n, ok := value.(json.Number)
+	if !ok {
+		// null stays null
+		obj[secondsKey] = value
+		return nil
+	}
+	minutes, err := n.Int64()
+	if err != nil {
+		return fmt.Errorf("%s must be a whole number, got %s", minutesKey, n)
+	}
+	obj[secondsKey] = json.Number(strconv.FormatInt(minutes*60, 10))
+	return nil
+}
+
+func secondsToMinutes(obj map[string]any) error {
+	for _, field := range durationFields {
+		err := renameToMinutes(obj, field[0], field[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	if obj["kind"] == string(store.GoalDurationSeconds) {
+		obj["kind"] = goalDurationMinutes
+		err := scale(obj, "target", 1, 60)
+		if err != nil {
+			return err
+		}
+		return scale(obj, "current", 1, 60)
+	}
+	return nil
+}
+
+func renameToMinutes(obj map[string]any, secondsKey, minutesKey string) error {
+	value, ok := obj[secondsKey]
+	if !ok {
+		return nil
+	}
+	delete(obj, secondsKey)
+
+	n, ok := value.(json.Number)
+	if !ok {
+		obj[minutesKey] = value
+		return nil
+	}
+	seconds, err := n.Float64()
+	if err != nil {
+		return err
+	}
+	obj[minutesKey] = json.Number(strconv.FormatFloat(math.Round(seconds/60), 'f', -1, 64))
+	return nil
+}
+
+// scale multiplies a numeric field by num/den, leaving anything else as is.
+func scale(obj map[string]any, key string, num, den float64) error {
+	n, ok := obj[key].(json.Number)
+	if !ok {
+		return nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return fmt.Errorf("%s must be a number, got %s", key, n)
+	}
+	obj[key] = json.Number(strconv.FormatFloat(f*num/den, 'f', -1, 64))
+	return nil
+}
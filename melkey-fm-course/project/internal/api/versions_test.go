@@ -0,0 +1,261 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+)
+
+// sameJSON reports whether two documents are equal once decoded, so that
+// expectations do not depend on key order or indentation.
+func sameJSON(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("decoding %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("decoding expectation %s: %v", want, err)
+	}
+	return reflect.DeepEqual(g, w)
+}
+
+func TestMinutesToSeconds(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "workout and entries",
+			body: `{"title":"Run","duration_minutes":45,"entries":[{"duration_minutes":3},{"reps":5}]}`,
+			want: `{"title":"Run","duration_seconds":2700,"entries":[{"duration_seconds":180},{"reps":5}]}`,
+		},
+		{
+			name: "groups",
+			body: `{"groups":[{"rounds":3,"entries":[{"duration_minutes":1}]}]}`,
+			want: `{"groups":[{"rounds":3,"entries":[{"duration_seconds":60}]}]}`,
+		},
+		{
+			name: "null stays null",
+			body: `{"entries":[{"duration_minutes":null}]}`,
+			want: `{"entries":[{"duration_seconds":null}]}`,
+		},
+		{
+			name: "duration_seconds is not part of v1",
+			body: `{"duration_seconds":10,"entries":[{"duration_seconds":10,"duration_minutes":2}]}`,
+			want: `{"entries":[{"duration_seconds":120}]}`,
+		},
+		{
+			name: "large values keep their precision",
+			body: `{"duration_minutes":9007199254740}`,
+			want: `{"duration_seconds":540431955284400}`,
+		},
+		{
+			name: "duration goals",
+			body: `{"kind":"duration_minutes","period":"week","target":90.5}`,
+			want: `{"kind":"duration_seconds","period":"week","target":5430}`,
+		},
+		{
+			name: "other goals",
+			body: `{"kind":"calories","period":"week","target":2000}`,
+			want: `{"kind":"calories","period":"week","target":2000}`,
+		},
+		{
+			name:    "fractional minutes",
+			body:    `{"duration_minutes":1.5}`,
+			wantErr: "duration_minutes must be a whole number, got 1.5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rewriteJSON([]byte(tt.body), minutesToSeconds)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("rewriteJSON error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("rewriteJSON: %v", err)
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Errorf("rewriteJSON = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecondsToMinutes(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "workout and entries",
+			body: `{"workout":{"duration_seconds":2700,"entries":[{"duration_seconds":180},{"reps":5}]}}`,
+			want: `{"workout":{"duration_minutes":45,"entries":[{"duration_minutes":3},{"reps":5}]}}`,
+		},
+		{
+			name: "rounded to the nearest minute",
+			body: `[{"duration_seconds":89},{"duration_seconds":90},{"duration_seconds":29}]`,
+			want: `[{"duration_minutes":1},{"duration_minutes":2},{"duration_minutes":0}]`,
+		},
+		{
+			name: "null stays null",
+			body: `{"duration_seconds":null}`,
+			want: `{"duration_minutes":null}`,
+		},
+		{
+			name: "stats totals",
+			body: `{"periods":[{"sessions":2,"total_duration_seconds":5430}]}`,
+			want: `{"periods":[{"sessions":2,"total_duration_minutes":91}]}`,
+		},
+		{
+			name: "duration goals",
+			body: `{"goals":[{"kind":"duration_seconds","target":5430,"current":1800,"percent":33.1}]}`,
+			want: `{"goals":[{"kind":"duration_minutes","target":90.5,"current":30,"percent":33.1}]}`,
+		},
+		{
+			name: "other goals",
+			body: `{"goals":[{"kind":"workouts","target":3,"current":1}]}`,
+			want: `{"goals":[{"kind":"workouts","target":3,"current":1}]}`,
+		},
+		{
+			name: "documents without durations",
+			body: `{"error":"workout not found"}`,
+			want: `{"error":"workout not found"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rewriteJSON([]byte(tt.body), secondsToMinutes)
+			if err != nil {
+				t.Fatalf("rewriteJSON: %v", err)
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Errorf("rewriteJSON = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteJSONNotJSON(t *testing.T) {
+	for _, body := range []string{"", "title,date\n", "<html>"} {
+		if _, err := rewriteJSON([]byte(body), minutesToSeconds); !errors.Is(err, errNotJSON) {
+			t.Errorf("rewriteJSON(%q) = %v, want errNotJSON", body, err)
+		}
+	}
+}
+
+func TestDurationMinutes(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		handler     http.HandlerFunc
+		wantStatus  int
+		wantSeen    string
+		wantBody    string
+		wantRawBody string
+	}{
+		{
+			name: "request and response are converted",
+			body: `{"title":"Run","duration_minutes":45}`,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": map[string]any{"id": 1, "duration_seconds": 2700}})
+			},
+			wantStatus: http.StatusCreated,
+			wantSeen:   `{"title":"Run","duration_seconds":2700}`,
+			wantBody:   `{"workout":{"id":1,"duration_minutes":45}}`,
+		},
+		{
+			name:       "invalid v1 durations are rejected before the handler",
+			body:       `{"duration_minutes":2.5}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"duration_minutes must be a whole number, got 2.5"}`,
+		},
+		{
+			name: "bodies that are not JSON are passed on",
+			body: "title,duration_minutes\nRun,45\n",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusAccepted)
+				io.WriteString(w, "duration_seconds stays as is")
+			},
+			wantStatus:  http.StatusAccepted,
+			wantSeen:    "title,duration_minutes\nRun,45\n",
+			wantRawBody: "duration_seconds stays as is",
+		},
+		{
+			name: "requests without a body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				utils.WriteJSON(w, http.StatusOK, utils.Envelope{"duration_seconds": 61})
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"duration_minutes":1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen []byte
+			called := false
+			next := func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				var err error
+				seen, err = io.ReadAll(r.Body)
+				if err != nil {
+					t.Fatalf("reading body: %v", err)
+				}
+				if r.ContentLength != int64(len(seen)) && r.ContentLength != 0 {
+					t.Errorf("ContentLength = %d, body has %d bytes", r.ContentLength, len(seen))
+				}
+				tt.handler(w, r)
+			}
+
+			var req *http.Request
+			if tt.body == "" {
+				req = httptest.NewRequest(http.MethodGet, "/workouts/1", nil)
+			} else {
+				req = httptest.NewRequest(http.MethodPost, "/workouts", strings.NewReader(tt.body))
+			}
+			rec := httptest.NewRecorder()
+			DurationMinutes(next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if called != (tt.handler != nil) {
+				t.Fatalf("handler called = %v, want %v", called, tt.handler != nil)
+			}
+			if tt.wantSeen != "" {
+				if strings.HasPrefix(tt.wantSeen, "{") {
+					if !sameJSON(t, seen, tt.wantSeen) {
+						t.Errorf("handler saw %s, want %s", seen, tt.wantSeen)
+					}
+				} else if string(seen) != tt.wantSeen {
+					t.Errorf("handler saw %q, want %q", seen, tt.wantSeen)
+				}
+			}
+			if tt.wantRawBody != "" {
+				if got := rec.Body.String(); got != tt.wantRawBody {
+					t.Errorf("body = %q, want %q", got, tt.wantRawBody)
+				}
+			} else if !sameJSON(t, rec.Body.Bytes(), tt.wantBody) {
+				t.Errorf("body = %s, want %s", rec.Body.Bytes(), tt.wantBody)
+			}
+		})
+	}
+}
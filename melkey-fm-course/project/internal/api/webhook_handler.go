@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/webhooks"
+)
+
+const (
+	defaultDeliveryPageSize = 50
+	maxDeliveryPageSize     = 200
+
+	maxWebhookURLLength = 2048
+)
+
+type createWebhookRequest struct {
+	URL string `json:"url"`
+	// Events defaults to every workout event.
+	Events []store.WebhookEvent `json:"events"`
+}
+
+type WebhookHandler struct {
+	webhookStore store.WebhookStore
+	logger       *log.Logger
+}
+
+func NewWebhookHandler(webhookStore store.WebhookStore, logger *log.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		webhookStore: webhookStore,
+		logger:       logger,
+	}
+}
+
+// HandleCreateWebhook registers an endpoint. The response carries the signing
+// secret, which is not shown again.
+func (wh *WebhookHandler) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req createWebhookRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	endpoint, err := webhookFromRequest(req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	err = checkWebhookHost(r.Context(), endpoint.URL)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	endpoint.UserID = middleware.GetUser(r).ID
+
+	err = wh.webhookStore.CreateWebhookEndpoint(endpoint)
+	if err != nil {
+		wh.logger.Printf("ERROR: createWebhookEndpoint: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create webhook"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"webhook": endpoint})
+}
+
+func webhookFromRequest(req createWebhookRequest) (*store.WebhookEndpoint, error) {
+	if len(req.URL) > maxWebhookURLLength {
+		return nil, errors.New("url is too long")
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, errors.New("url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return nil, errors.New("url cannot contain credentials")
+	}
+
+	events := req.Events
+	if len(events) == 0 {
+		events = store.WebhookEvents
+	}
+	endpoint := &store.WebhookEndpoint{URL: u.String()}
+	for _, event := range events {
+		if !event.Valid() {
+			return nil, errors.New("events must be workout.created, workout.updated or workout.deleted")
+		}
+		if !slices.Contains(endpoint.Events, event) {
+			endpoint.Events = append(endpoint.Events, event)
+		}
+	}
+	return endpoint, nil
+}
+
+// checkWebhookHost refuses endpoints on the server's own network.
+func checkWebhookHost(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	err = webhooks.CheckHost(ctx, u.Hostname())
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return errors.New("url host could not be resolved")
+	}
+	return err
+}
+
+func (wh *WebhookHandler) HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	endpoints, err := wh.webhookStore.ListWebhookEndpoints(user.ID)
+	if err != nil {
+		wh.logger.Printf("ERROR: listWebhookEndpoints: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"webhooks": endpoints})
+}
+
+func (wh *WebhookHandler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	endpointID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = wh.webhookStore.DeleteWebhookEndpoint(endpointID, user.ID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		wh.logger.Printf("ERROR: deleteWebhookEndpoint: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete webhook"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListWebhookDeliveries returns the delivery log of an endpoint, newest
+// first. It accepts ?status=pending|succeeded|dead and ?limit=.
+func (wh *WebhookHandler) HandleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	endpointID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	limit, _, err := readPage(r, defaultDeliveryPageSize, maxDeliveryPageSize)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	status := store.WebhookDeliveryStatus(r.URL.Query().Get("status"))
+	if status != "" && !status.Valid() {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "status must be one of pending, succeeded or dead"})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	deliveries, err := wh.webhookStore.ListWebhookDeliveries(endpointID, user.ID, status, limit)
+	if err != nil {
+		wh.logger.Printf("ERROR: listWebhookDeliveries: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if deliveries == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"deliveries": deliveries})
+}
+
+// HandleRetryWebhookDelivery requeues a dead delivery.
+func (wh *WebhookHandler) HandleRetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	deliveryID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = wh.webhookStore.RetryWebhookDelivery(deliveryID, user.ID)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		wh.logger.Printf("ERROR: retryWebhookDelivery: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to retry delivery"})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
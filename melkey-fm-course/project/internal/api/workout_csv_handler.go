@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/workoutcsv"
+)
+
+const maxImportBytes = 10 << 20
+
+// HandleExportWorkoutsCSV writes weights in the caller's units, the same ones
+// HandleImportWorkouts reads this format in.
+func (wh *WorkoutHandler) HandleExportWorkoutsCSV(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	modified, err := wh.workoutStore.WorkoutsLastModified(user.ID)
+	if err != nil {
+		wh.logger.Printf("ERROR: exportWorkoutsCSV: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if utils.NotModified(w, r, modified) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="workouts.csv"`)
+
+	cw := workoutcsv.NewWriter(w)
+	err = cw.WriteHeader()
+	if err != nil {
+		wh.logger.Printf("ERROR: exportWorkoutsCSV: %v", err)
+		return
+	}
+
+	// dates are written with the offset of the user's timezone
+	loc := user.Location()
+	err = wh.workoutStore.ExportWorkouts(user.ID, func(workout *store.Workout) error {
+		workout.PerformedAt = workout.PerformedAt.In(loc)
+		workoutOut(workout, prefs)
+		err := cw.WriteWorkout(workout)
+		if err != nil {
+			return err
+		}
+		return cw.Flush()
+	})
+	if err != nil {
+		// the status line has already gone out, all we can do is log and stop
+		wh.logger.Printf("ERROR: exportWorkoutsCSV: %v", err)
+		return
+	}
+
+	err = cw.Flush()
+	if err != nil {
+		wh.logger.Printf("ERROR: exportWorkoutsCSV: %v", err)
+	}
+}
+
+// HandleImportWorkouts accepts a CSV either as the raw request body or as the
+// "file" field of a multipart form. With ?dry_run=true nothing is written and
+// the response only reports what would be imported. Dates without an offset
+// are read in ?tz=, defaulting to the user's timezone. Weights in this API's
+// own format are read in the caller's units; Strong and Hevy exports say
+// which unit they use.
+func (wh *WorkoutHandler) HandleImportWorkouts(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	query := r.URL.Query()
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	dryRun := false
+	if value := query.Get("dry_run"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "dry_run must be true or false"})
+			return
+		}
+	}
+
+	loc := user.Location()
+	if tz := query.Get("tz"); tz != "" {
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tz, expected an IANA timezone name"})
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	var body io.Reader = r.Body
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "multipart upload must include a file field"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	result, err := workoutcsv.Parse(body, loc)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			utils.WriteJSON(w, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "import file is too large"})
+		default:
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		}
+		return
+	}
+
+	entries := 0
+	for _, workout := range result.Workouts {
+		workout.UserID = &user.ID
+		if result.Format == workoutcsv.FormatNative {
+			workoutIn(workout, prefs)
+		}
+		entries += len(workout.Entries)
+	}
+
+	report := utils.Envelope{
+		"format":   result.Format,
+		"dry_run":  dryRun,
+		"workouts": len(result.Workouts),
+		"entries":  entries,
+		"errors":   result.Errors,
+	}
+
+	if dryRun {
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{"import": report})
+		return
+	}
+	if len(result.Errors) > 0 {
+		utils.WriteJSON(w, http.StatusUnprocessableEntity, utils.Envelope{"import": report})
+		return
+	}
+
+	err = wh.workoutStore.ImportWorkouts(result.Workouts, actorFrom(r))
+	if err != nil {
+		wh.logger.Printf("ERROR: importWorkouts: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to import workouts"})
+		return
+	}
+
+	wh.evaluateGoals(r, result.Workouts...)
+
+	ids := make([]int, 0, len(result.Workouts))
+	for _, workout := range result.Workouts {
+		ids = append(ids, workout.ID)
+	}
+	report["workout_ids"] = ids
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"import": report})
+}
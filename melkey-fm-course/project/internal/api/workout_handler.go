@@ -1,33 +1,655 @@
 package api
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/go-chi/chi/v5"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/calendar"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/calories"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/units"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 )
 
-type WorkoutHandler struct{}
+var errInvalidBodyWeight = errors.New("body_weight_kg must be a positive number")
 
-func NewWorkoutHandler() *WorkoutHandler {
-	return &WorkoutHandler{}
+type updateWorkoutRequest struct {
+	Title           *string              `json:"title"`
+	Description     *string              `json:"description"`
+	DurationSeconds *int                 `json:"duration_seconds"`
+	CaloriesBurned  *int                 `json:"calories_burned"`
+	Entries         []store.WorkoutEntry `json:"entries"`
+	// Groups are replaced together with Entries; sending either one clears
+	// the other unless it is sent too.
+	Groups      []store.EntryGroup `json:"groups"`
+	Tags        []string           `json:"tags"`
+	Visibility  *store.Visibility  `json:"visibility"`
+	PerformedAt *time.Time         `json:"performed_at"`
+}
+
+const (
+	defaultWorkoutPageSize = 50
+	maxWorkoutPageSize     = 200
+
+	maxGroupRounds = 100
+	maxRestSeconds = 3600
+
+	maxEntrySets = 100
+
+	// performedAtLeeway allows for clocks on the client running slightly
+	// ahead of the server.
+	performedAtLeeway = 5 * time.Minute
+)
+
+var errPerformedInFuture = errors.New("performed_at cannot be in the future")
+
+var errInvalidVisibility = errors.New("visibility must be one of private, followers or public")
+
+type WorkoutHandler struct {
+	workoutStore store.WorkoutStore
+	followStore  store.FollowStore
+	commentStore store.CommentStore
+	goalStore    store.GoalStore
+	logger       *log.Logger
+}
+
+func NewWorkoutHandler(workoutStore store.WorkoutStore, followStore store.FollowStore, commentStore store.CommentStore, goalStore store.GoalStore, logger *log.Logger) *WorkoutHandler {
+	return &WorkoutHandler{
+		workoutStore: workoutStore,
+		followStore:  followStore,
+		commentStore: commentStore,
+		goalStore:    goalStore,
+		logger:       logger,
+	}
 }
 
 func (wh *WorkoutHandler) HandleGetWorkoutByID(w http.ResponseWriter, r *http.Request) {
-	paramsWorkoutId := chi.URLParam(r, "id")
-	if paramsWorkoutId == "" {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
-	workoutID, err := strconv.ParseInt(paramsWorkoutId, 10, 64)
+
+	bodyWeightKg, err := readBodyWeight(r)
 	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	workout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		wh.logger.Printf("ERROR: getWorkoutByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if workout == nil {
 		http.NotFound(w, r)
 		return
 	}
-	fmt.Fprintf(w, "workout id: %d\n", workoutID)
+
+	visible, err := wh.canView(r, workout)
+	if err != nil {
+		wh.logger.Printf("ERROR: isFollowing: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if !visible {
+		http.NotFound(w, r)
+		return
+	}
+
+	workout.Engagement, err = wh.commentStore.GetEngagement(workoutID, middleware.GetUser(r).ID)
+	if err != nil {
+		wh.logger.Printf("ERROR: getEngagement: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	calories.Fill(workout, bodyWeightKg)
+	workoutOut(workout, prefs)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout, "units": prefs})
+}
+
+// HandleListWorkouts lists the current user's workouts. Repeated tag
+// parameters narrow the list to workouts carrying all of them, or any of them
+// with match=any.
+func (wh *WorkoutHandler) HandleListWorkouts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	user := middleware.GetUser(r)
+
+	filter := store.WorkoutFilter{
+		UserID: user.ID,
+		Match:  store.TagMatchAll,
+	}
+
+	for _, tag := range query["tag"] {
+		name, err := store.NormalizeTagName(tag)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		filter.Tags = append(filter.Tags, name)
+	}
+
+	if value := query.Get("match"); value != "" {
+		filter.Match = store.TagMatch(value)
+		if !filter.Match.Valid() {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "match must be all or any"})
+			return
+		}
+	}
+
+	limit, offset, err := readPage(r, defaultWorkoutPageSize, maxWorkoutPageSize)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	filter.Limit = limit
+	filter.Offset = offset
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	modified, err := wh.workoutStore.WorkoutsLastModified(user.ID)
+	if err != nil {
+		wh.logger.Printf("ERROR: workoutsLastModified: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if utils.NotModified(w, r, modified) {
+		return
+	}
+
+	workouts, err := wh.workoutStore.ListWorkouts(filter)
+	if err != nil {
+		wh.logger.Printf("ERROR: listWorkouts: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	for _, workout := range workouts {
+		workoutOut(workout, prefs)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workouts": workouts, "units": prefs})
 }
 
 func (wh *WorkoutHandler) HandleCreatetWorkout(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "Create new workout\n")
+	var workout store.Workout
+	err := json.NewDecoder(r.Body).Decode(&workout)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	bodyWeightKg, err := readBodyWeight(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	err = validateGroups(workout.Groups)
+	if err == nil {
+		err = validateSets(&workout)
+	}
+	if err == nil {
+		err = validateDurations(&workout)
+	}
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	workoutIn(&workout, prefs)
+	err = validateWeights(&workout, prefs)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	workout.UserID = &user.ID
+
+	var plan *store.Workout
+	if workout.PlannedWorkoutID != nil {
+		plan, err = wh.workoutStore.GetWorkoutByID(*workout.PlannedWorkoutID)
+		if err != nil {
+			wh.logger.Printf("ERROR: getWorkoutByID: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+	}
+
+	if time.Until(workout.PerformedAt) > performedAtLeeway {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": errPerformedInFuture.Error()})
+		return
+	}
+
+	err = validateSchedule(&workout, plan, user.ID, user.Location())
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	workout.Tags, err = normalizeTags(workout.Tags)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if workout.Visibility != "" && !workout.Visibility.Valid() {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": errInvalidVisibility.Error()})
+		return
+	}
+
+	// the stored value always reflects what the user entered
+	workout.CaloriesEstimated = false
+	createdWorkout, err := wh.workoutStore.CreateWorkout(&workout, actorFrom(r))
+	if err != nil {
+		wh.logger.Printf("ERROR: createWorkout: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create workout"})
+		return
+	}
+
+	wh.evaluateGoals(r, createdWorkout)
+
+	calories.Fill(createdWorkout, bodyWeightKg)
+	workoutOut(createdWorkout, prefs)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": createdWorkout, "units": prefs})
+}
+
+func (wh *WorkoutHandler) HandleUpdateWorkoutByID(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	existingWorkout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		wh.logger.Printf("ERROR: getWorkoutByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if existingWorkout == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	if existingWorkout.UserID == nil || *existingWorkout.UserID != user.ID {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to update this workout"})
+		return
+	}
+
+	var req updateWorkoutRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if req.Title != nil {
+		existingWorkout.Title = *req.Title
+	}
+	if req.Description != nil {
+		existingWorkout.Description = *req.Description
+	}
+	if req.DurationSeconds != nil {
+		existingWorkout.DurationSeconds = *req.DurationSeconds
+	}
+	if req.CaloriesBurned != nil {
+		existingWorkout.CaloriesBurned = req.CaloriesBurned
+	}
+	if req.Entries != nil || req.Groups != nil {
+		err = validateGroups(req.Groups)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		existingWorkout.Entries = req.Entries
+		if existingWorkout.Entries == nil {
+			existingWorkout.Entries = []store.WorkoutEntry{}
+		}
+		existingWorkout.Groups = req.Groups
+		err = validateSets(existingWorkout)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		workoutIn(existingWorkout, prefs)
+		err = validateWeights(existingWorkout, prefs)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+	if req.PerformedAt != nil {
+		if time.Until(*req.PerformedAt) > performedAtLeeway {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": errPerformedInFuture.Error()})
+			return
+		}
+		existingWorkout.PerformedAt = *req.PerformedAt
+	}
+	if req.Visibility != nil {
+		if !req.Visibility.Valid() {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": errInvalidVisibility.Error()})
+			return
+		}
+		existingWorkout.Visibility = *req.Visibility
+	}
+	if req.Tags != nil {
+		existingWorkout.Tags, err = normalizeTags(req.Tags)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+
+	err = validateDurations(existingWorkout)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = wh.workoutStore.UpdateWorkout(existingWorkout, actorFrom(r))
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		wh.logger.Printf("ERROR: updateWorkout: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update workout"})
+		return
+	}
+
+	wh.evaluateGoals(r, existingWorkout)
+
+	workoutOut(existingWorkout, prefs)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": existingWorkout, "units": prefs})
+}
+
+func (wh *WorkoutHandler) HandleDeleteWorkoutByID(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	err = wh.workoutStore.DeleteWorkout(workoutID, user.ID, actorFrom(r))
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		wh.logger.Printf("ERROR: deleteWorkout: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete workout"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateGroups checks each group's settings and that it holds enough
+// entries for its kind: exactly two for a superset, two or more for a circuit
+// and three or more for a giant set.
+func validateGroups(groups []store.EntryGroup) error {
+	for i, group := range groups {
+		if !group.Kind.Valid() {
+			return fmt.Errorf("groups[%d]: kind must be one of superset, circuit or giant_set", i)
+		}
+		if group.Rounds < 1 || group.Rounds > maxGroupRounds {
+			return fmt.Errorf("groups[%d]: rounds must be between 1 and %d", i, maxGroupRounds)
+		}
+		if group.RestSeconds != nil && (*group.RestSeconds < 0 || *group.RestSeconds > maxRestSeconds) {
+			return fmt.Errorf("groups[%d]: rest_seconds must be between 0 and %d", i, maxRestSeconds)
+		}
+
+		n := len(group.Entries)
+		switch {
+		case group.Kind == store.EntryGroupSuperset && n != 2:
+			return fmt.Errorf("groups[%d]: a superset pairs exactly 2 entries", i)
+		case group.Kind == store.EntryGroupCircuit && n < 2:
+			return fmt.Errorf("groups[%d]: a circuit needs at least 2 entries", i)
+		case group.Kind == store.EntryGroupGiantSet && n < 3:
+			return fmt.Errorf("groups[%d]: a giant set needs at least 3 entries", i)
+		}
+
+		for j, entry := range group.Entries {
+			if entry.ExerciseName == "" {
+				return fmt.Errorf("groups[%d].entries[%d]: exercise_name is required", i, j)
+			}
+		}
+	}
+	return nil
+}
+
+// validateSets checks the per-set details of every entry, grouped or not.
+func validateSets(workout *store.Workout) error {
+	for _, entry := range workout.FlatEntries() {
+		if len(entry.SetDetails) > maxEntrySets {
+			return fmt.Errorf("%s: at most %d sets can be logged per entry", entry.ExerciseName, maxEntrySets)
+		}
+		for i, set := range entry.SetDetails {
+			n := i + 1
+			switch {
+			case set.Type != "" && !set.Type.Valid():
+				return fmt.Errorf("%s set %d: set_type must be one of warmup, working, drop or failure", entry.ExerciseName, n)
+			case set.Reps != nil && *set.Reps < 0:
+				return fmt.Errorf("%s set %d: reps cannot be negative", entry.ExerciseName, n)
+			case set.Weight != nil && *set.Weight < 0:
+				return fmt.Errorf("%s set %d: weight cannot be negative", entry.ExerciseName, n)
+			case set.RPE != nil && (*set.RPE < 1 || *set.RPE > 10):
+				return fmt.Errorf("%s set %d: rpe must be between 1 and 10", entry.ExerciseName, n)
+			case set.RIR != nil && (*set.RIR < 0 || *set.RIR > 10):
+				return fmt.Errorf("%s set %d: rir must be between 0 and 10", entry.ExerciseName, n)
+			case set.RestSeconds != nil && (*set.RestSeconds < 0 || *set.RestSeconds > maxRestSeconds):
+				return fmt.Errorf("%s set %d: rest_seconds must be between 0 and %d", entry.ExerciseName, n, maxRestSeconds)
+			}
+		}
+	}
+	return nil
+}
+
+// validateWeights checks weights once they are in kilograms, so the limit is
+// the same whichever unit they were sent in. Errors quote it in p's unit.
+func validateWeights(workout *store.Workout, p units.Preference) error {
+	tooHeavy := func(weight *float64) bool {
+		return weight != nil && *weight > store.MaxWeightKg
+	}
+	limit := fmt.Sprintf("%v %s", p.WeightOut(store.MaxWeightKg), p.Weight)
+
+	for _, entry := range workout.FlatEntries() {
+		if tooHeavy(entry.Weight) {
+			return fmt.Errorf("%s: weight cannot exceed %s", entry.ExerciseName, limit)
+		}
+		for i, set := range entry.SetDetails {
+			if tooHeavy(set.Weight) {
+				return fmt.Errorf("%s set %d: weight cannot exceed %s", entry.ExerciseName, i+1, limit)
+			}
+		}
+	}
+	return nil
+}
+
+// validateDurations rejects negative durations on the workout and its entries.
+func validateDurations(workout *store.Workout) error {
+	if workout.DurationSeconds < 0 {
+		return errors.New("duration cannot be negative")
+	}
+	for _, entry := range workout.FlatEntries() {
+		if entry.DurationSeconds != nil && *entry.DurationSeconds < 0 {
+			return fmt.Errorf("%s: duration cannot be negative", entry.ExerciseName)
+		}
+	}
+	return nil
+}
+
+// validateSchedule normalises the recurrence rule of a planned workout and,
+// for a completed workout, checks that it fulfils a real occurrence of plan,
+// which must be one of the user's own planned workouts. Occurrences are
+// expanded in loc so they keep the user's wall clock time across DST changes.
+func validateSchedule(workout *store.Workout, plan *store.Workout, userID int64, loc *time.Location) error {
+	if workout.Recurrence != nil {
+		if workout.ScheduledAt == nil {
+			return errors.New("recurrence requires scheduled_at")
+		}
+		rule, err := calendar.ParseRule(*workout.Recurrence)
+		if err != nil {
+			return err
+		}
+		normalised := rule.String()
+		workout.Recurrence = &normalised
+	}
+
+	if workout.PlannedWorkoutID == nil {
+		if workout.PlannedFor != nil {
+			return errors.New("planned_for requires planned_workout_id")
+		}
+		return nil
+	}
+	if workout.ScheduledAt != nil {
+		return errors.New("a planned workout cannot fulfil another plan")
+	}
+	if workout.PlannedFor == nil {
+		return errors.New("planned_workout_id requires planned_for")
+	}
+
+	if plan == nil || plan.ScheduledAt == nil || plan.UserID == nil || *plan.UserID != userID {
+		return errors.New("planned_workout_id does not reference one of your planned workouts")
+	}
+
+	if plan.Recurrence == nil {
+		if !plan.ScheduledAt.Equal(*workout.PlannedFor) {
+			return errors.New("planned_for does not match the planned workout")
+		}
+		return nil
+	}
+
+	rule, err := calendar.ParseRule(*plan.Recurrence)
+	if err != nil {
+		return err
+	}
+	if !rule.Includes(plan.ScheduledAt.In(loc), workout.PlannedFor.In(loc)) {
+		return errors.New("planned_for is not an occurrence of the planned workout")
+	}
+	return nil
+}
+
+// canView reports whether the caller may read workout.
+func (wh *WorkoutHandler) canView(r *http.Request, workout *store.Workout) (bool, error) {
+	return canViewWorkout(r, wh.followStore, workout)
+}
+
+// canViewWorkout applies the workout's visibility to the caller. Workouts
+// from before ownership was recorded stay readable by everyone.
+func canViewWorkout(r *http.Request, followStore store.FollowStore, workout *store.Workout) (bool, error) {
+	if workout.UserID == nil {
+		return true, nil
+	}
+
+	viewer := middleware.GetUser(r)
+	if !viewer.IsAnonymous() && viewer.ID == *workout.UserID {
+		return true, nil
+	}
+
+	switch workout.Visibility {
+	case store.VisibilityPublic:
+		return true, nil
+	case store.VisibilityFollowers:
+		if viewer.IsAnonymous() {
+			return false, nil
+		}
+		return followStore.IsFollowing(viewer.ID, *workout.UserID)
+	}
+	return false, nil
+}
+
+// evaluateGoals records any goals the caller's latest write has met, in the
+// current period and in the periods of the workouts written. The write itself
+// already succeeded, so failures are only logged.
+func (wh *WorkoutHandler) evaluateGoals(r *http.Request, workouts ...*store.Workout) {
+	user := middleware.GetUser(r)
+	at := []time.Time{time.Now()}
+	for _, workout := range workouts {
+		at = append(at, workout.PerformedAt)
+	}
+	err := wh.goalStore.EvaluateGoals(user.ID, at...)
+	if err != nil {
+		wh.logger.Printf("ERROR: evaluateGoals: %v", err)
+	}
+}
+
+// actorFrom describes the caller for the revision history.
+func actorFrom(r *http.Request) store.Actor {
+	actor := store.Actor{RequestID: chimiddleware.GetReqID(r.Context())}
+	if user := middleware.GetUser(r); !user.IsAnonymous() {
+		actor.UserID = &user.ID
+	}
+	return actor
+}
+
+// readBodyWeight reads the optional body_weight_kg query parameter used to
+// estimate calories for workouts that have none recorded.
+func readBodyWeight(r *http.Request) (float64, error) {
+	value := r.URL.Query().Get("body_weight_kg")
+	if value == "" {
+		return 0, nil
+	}
+
+	bodyWeightKg, err := strconv.ParseFloat(value, 64)
+	if err != nil || bodyWeightKg <= 0 {
+		return 0, errInvalidBodyWeight
+	}
+	return bodyWeightKg, nil
+}
+
+// readPage reads the optional limit and offset query parameters.
+func readPage(r *http.Request, defaultLimit, maxLimit int) (limit, offset int, err error) {
+	query := r.URL.Query()
+	limit = defaultLimit
+
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative number")
+		}
+	}
+
+	return limit, offset, nil
 }
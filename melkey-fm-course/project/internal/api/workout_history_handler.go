@@ -0,0 +1,235 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/jsondiff"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/units"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// diffIgnoredKeys are regenerated on every write and would drown out the
+// real changes.
+var diffIgnoredKeys = []string{"id", "calories_estimated"}
+
+func (wh *WorkoutHandler) HandleGetWorkoutHistory(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user := middleware.GetUser(r)
+	revisions, err := wh.workoutStore.ListRevisions(workoutID, user.ID)
+	if err != nil {
+		wh.logger.Printf("ERROR: listRevisions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if len(revisions) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"revisions": revisions})
+}
+
+func (wh *WorkoutHandler) HandleGetWorkoutRevision(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	revision, err := strconv.Atoi(chi.URLParam(r, "rev"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	rev, err := wh.workoutStore.GetRevision(workoutID, user.ID, revision)
+	if err != nil {
+		wh.logger.Printf("ERROR: getRevision: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if rev == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	rev.Snapshot, err = snapshotOut(rev.Snapshot, prefs)
+	if err != nil {
+		wh.logger.Printf("ERROR: getRevision: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"revision": rev, "units": prefs})
+}
+
+// HandleDiffWorkoutRevisions compares ?from= and ?to= revisions. to defaults
+// to the latest revision and from to the one before it.
+func (wh *WorkoutHandler) HandleDiffWorkoutRevisions(w http.ResponseWriter, r *http.Request) {
+	wh.diffWorkoutRevisions(w, r, nil)
+}
+
+// HandleDiffWorkoutRevisionsV1 compares the snapshots in the v1
+// representation, so duration changes show up under duration_minutes paths
+// and in minutes.
+func (wh *WorkoutHandler) HandleDiffWorkoutRevisionsV1(w http.ResponseWriter, r *http.Request) {
+	wh.diffWorkoutRevisions(w, r, func(snapshot []byte) ([]byte, error) {
+		return rewriteJSON(snapshot, secondsToMinutes)
+	})
+}
+
+// diffWorkoutRevisions converts both snapshots to the caller's units and
+// passes them through represent, when set, before comparing them.
+func (wh *WorkoutHandler) diffWorkoutRevisions(w http.ResponseWriter, r *http.Request, represent func([]byte) ([]byte, error)) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	revisions, err := wh.workoutStore.ListRevisions(workoutID, user.ID)
+	if err != nil {
+		wh.logger.Printf("ERROR: listRevisions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if len(revisions) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	to := revisions[len(revisions)-1].Revision
+	if value := r.URL.Query().Get("to"); value != "" {
+		to, err = strconv.Atoi(value)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "to must be a revision number"})
+			return
+		}
+	}
+	from := to - 1
+	if value := r.URL.Query().Get("from"); value != "" {
+		from, err = strconv.Atoi(value)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "from must be a revision number"})
+			return
+		}
+	}
+
+	fromRev, err := wh.workoutStore.GetRevision(workoutID, user.ID, from)
+	if err != nil {
+		wh.logger.Printf("ERROR: getRevision: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	toRev, err := wh.workoutStore.GetRevision(workoutID, user.ID, to)
+	if err != nil {
+		wh.logger.Printf("ERROR: getRevision: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if fromRev == nil || toRev == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "revision not found"})
+		return
+	}
+
+	var fromSnapshot, toSnapshot []byte
+	fromSnapshot, err = snapshotOut(fromRev.Snapshot, prefs)
+	if err == nil {
+		toSnapshot, err = snapshotOut(toRev.Snapshot, prefs)
+	}
+	if err == nil && represent != nil {
+		fromSnapshot, err = represent(fromSnapshot)
+		if err == nil {
+			toSnapshot, err = represent(toSnapshot)
+		}
+	}
+	if err != nil {
+		wh.logger.Printf("ERROR: diffRevisions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	changes, err := jsondiff.Diff(fromSnapshot, toSnapshot, diffIgnoredKeys...)
+	if err != nil {
+		wh.logger.Printf("ERROR: diffRevisions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"diff": utils.Envelope{
+		"from":    from,
+		"to":      to,
+		"changes": changes,
+	}, "units": prefs})
+}
+
+// snapshotOut converts a revision's stored workout to the response's units.
+func snapshotOut(snapshot json.RawMessage, p units.Preference) (json.RawMessage, error) {
+	var workout store.Workout
+	err := json.Unmarshal(snapshot, &workout)
+	if err != nil {
+		return nil, err
+	}
+	workoutOut(&workout, p)
+	return json.Marshal(&workout)
+}
+
+func (wh *WorkoutHandler) HandleRevertWorkout(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	revision, err := strconv.Atoi(chi.URLParam(r, "rev"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetUser(r)
+	workout, err := wh.workoutStore.RevertWorkout(workoutID, user.ID, revision, actorFrom(r))
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		wh.logger.Printf("ERROR: revertWorkout: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to revert workout"})
+		return
+	}
+
+	wh.evaluateGoals(r, workout)
+
+	workoutOut(workout, prefs)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout, "units": prefs})
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/calories"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/tracks"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+	"github.com/paulmach/orb/geojson"
+)
+
+const maxTrackBytes = 20 << 20
+
+// HandleUploadTrack creates a workout from a GPX or TCX file sent either as
+// the raw body or as the "file" field of a multipart form.
+func (wh *WorkoutHandler) HandleUploadTrack(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	bodyWeightKg, err := readBodyWeight(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxTrackBytes)
+	var body io.Reader = r.Body
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "multipart upload must include a file field"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	track, err := tracks.Parse(body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			utils.WriteJSON(w, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "track file is too large"})
+		default:
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		}
+		return
+	}
+
+	metrics := track.Metrics()
+	durationSeconds := int(math.Round(metrics.DurationSeconds))
+	exerciseName := track.ExerciseName()
+
+	title := track.Name
+	if title == "" {
+		title = exerciseName
+		if !metrics.StartedAt.IsZero() {
+			title = fmt.Sprintf("%s on %s", exerciseName, metrics.StartedAt.In(user.Location()).Format(time.DateOnly))
+		}
+	}
+
+	workout := &store.Workout{
+		UserID:          &user.ID,
+		Title:           title,
+		DurationSeconds: durationSeconds,
+		PerformedAt:     metrics.StartedAt,
+		Entries: []store.WorkoutEntry{{
+			ExerciseName:    exerciseName,
+			Sets:            1,
+			DurationSeconds: &durationSeconds,
+			Notes:           fmt.Sprintf("%.2f %s", prefs.DistanceOut(metrics.DistanceMeters), prefs.Distance),
+		}},
+	}
+	if track.RecordedCalories > 0 {
+		workout.CaloriesBurned = &track.RecordedCalories
+	}
+
+	err = validateDurations(workout)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	route := &store.WorkoutRoute{
+		Sport:               exerciseName,
+		DistanceMeters:      metrics.DistanceMeters,
+		ElevationGainMeters: metrics.ElevationGainMeters,
+		Geometry:            track.LineString(),
+	}
+	if metrics.PaceSecondsPerKm > 0 {
+		route.PaceSecondsPerKm = &metrics.PaceSecondsPerKm
+	}
+
+	createdWorkout, err := wh.workoutStore.CreateWorkoutWithRoute(workout, route, actorFrom(r))
+	if err != nil {
+		wh.logger.Printf("ERROR: createWorkoutWithRoute: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create workout"})
+		return
+	}
+
+	wh.evaluateGoals(r, createdWorkout)
+
+	calories.Fill(createdWorkout, bodyWeightKg)
+	workoutOut(createdWorkout, prefs)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": createdWorkout, "units": prefs})
+}
+
+// HandleGetWorkoutRoute returns the recorded route as a GeoJSON Feature with
+// the route metrics as properties.
+func (wh *WorkoutHandler) HandleGetWorkoutRoute(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	prefs, err := callerUnits(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	workout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	if err != nil {
+		wh.logger.Printf("ERROR: getWorkoutByID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if workout == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	visible, err := wh.canView(r, workout)
+	if err != nil {
+		wh.logger.Printf("ERROR: isFollowing: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if !visible {
+		http.NotFound(w, r)
+		return
+	}
+
+	route, err := wh.workoutStore.GetWorkoutRoute(workoutID)
+	if err != nil {
+		wh.logger.Printf("ERROR: getWorkoutRoute: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if route == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	feature := geojson.NewFeature(route.Geometry)
+	feature.Properties["workout_id"] = route.WorkoutID
+	feature.Properties["sport"] = route.Sport
+	feature.Properties["distance_meters"] = route.DistanceMeters
+	feature.Properties["elevation_gain_meters"] = route.ElevationGainMeters
+	feature.Properties["pace_seconds_per_km"] = route.PaceSecondsPerKm
+	routeOut(route, prefs)
+	feature.Properties["distance"] = route.Distance
+	feature.Properties["pace_seconds_per_unit"] = route.PaceSecondsPerUnit
+	feature.Properties["units"] = prefs
+
+	js, err := feature.MarshalJSON()
+	if err != nil {
+		wh.logger.Printf("ERROR: marshal route: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(js, '\n'))
+}
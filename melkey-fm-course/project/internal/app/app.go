@@ -1,24 +1,158 @@
 package app
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/api"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/jobs"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/mailer"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/outbox"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/ratelimit"
 	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/webhooks"
 	"github.com/HrushiBorhade/golang/melkey-fm-course/project/migrations"
 )
 
+type Config struct {
+	// TrashRetention is how long deleted workouts stay restorable.
+	TrashRetention time.Duration
+	// SearchBackend is "fulltext" for Postgres text search or "like" for
+	// plain ILIKE matching.
+	SearchBackend string
+	// OutboxRetention is how long delivered outbox events are kept around
+	// for debugging before they are purged.
+	OutboxRetention time.Duration
+	Mail            MailConfig
+	// RateLimit turns the per-user and per-IP request limits on.
+	RateLimit  bool
+	RateLimits RateLimitConfig
+	// TrustProxy takes client addresses from X-Forwarded-For and X-Real-IP,
+	// which is only safe behind a proxy that sets them.
+	TrustProxy bool
+}
+
+// MailConfig picks the mail transport: SMTP when SMTPHost is set, otherwise
+// .eml files written to Dir.
+type MailConfig struct {
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	Dir          string
+}
+
+// RateLimitConfig holds the limit of each route group; see RateLimits.
+type RateLimitConfig struct {
+	API    RateLimitRule
+	Client RateLimitRule
+	Public RateLimitRule
+	Auth   RateLimitRule
+}
+
+var DefaultRateLimits = RateLimitConfig{
+	API:    RateLimitRule{Limit: 120, Window: time.Minute},
+	Client: RateLimitRule{Limit: 600, Window: time.Minute},
+	Public: RateLimitRule{Limit: 60, Window: time.Minute},
+	Auth:   RateLimitRule{Limit: 10, Window: 15 * time.Minute},
+}
+
+// RateLimitRule allows Limit requests per Window. A zero Limit turns the
+// limit off. It is a flag.Value written as "<limit>/<window>", e.g. "120/1m".
+type RateLimitRule struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (rule *RateLimitRule) String() string {
+	return fmt.Sprintf("%d/%s", rule.Limit, rule.Window)
+}
+
+func (rule *RateLimitRule) Set(value string) error {
+	limit, window, ok := strings.Cut(value, "/")
+	if !ok {
+		return errors.New("expected <limit>/<window>, e.g. 120/1m")
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 0 {
+		return errors.New("limit must be a whole number of requests")
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return errors.New("window must be a positive duration, e.g. 1m")
+	}
+	rule.Limit, rule.Window = n, d
+	return nil
+}
+
+func (rule RateLimitRule) limiter() *ratelimit.Limiter {
+	if rule.Limit == 0 {
+		return nil
+	}
+	return ratelimit.New(rule.Limit, rule.Window, rateLimitKeys)
+}
+
+// RateLimits holds the limiter of each route group. They are nil when rate
+// limiting is off.
+type RateLimits struct {
+	// API covers the routes behind Authenticate, per user.
+	API *ratelimit.Limiter
+	// Client covers the same routes per IP, before Authenticate looks up
+	// the token, so guessing tokens is limited like guessing passwords.
+	Client *ratelimit.Limiter
+	// Public covers routes anyone can call, per IP.
+	Public *ratelimit.Limiter
+	// Auth covers registration and login, strict enough to slow down
+	// password guessing, per IP.
+	Auth *ratelimit.Limiter
+}
+
+// rateLimitKeys caps the buckets each limiter keeps.
+const rateLimitKeys = 100_000
+
 type Application struct {
-	Logger         *log.Logger
-	WorkoutHandler *api.WorkoutHandler
-	DB             *sql.DB
+	Config             Config
+	Logger             *log.Logger
+	WorkoutHandler     *api.WorkoutHandler
+	StatsHandler       *api.StatsHandler
+	UserHandler        *api.UserHandler
+	TokenHandler       *api.TokenHandler
+	CalendarHandler    *api.CalendarHandler
+	TrashHandler       *api.TrashHandler
+	TagHandler         *api.TagHandler
+	FollowHandler      *api.FollowHandler
+	CommentHandler     *api.CommentHandler
+	ShareHandler       *api.ShareHandler
+	GoalHandler        *api.GoalHandler
+	MeasurementHandler *api.MeasurementHandler
+	SearchHandler      *api.SearchHandler
+	WebhookHandler     *api.WebhookHandler
+	Middleware         middleware.UserMiddleware
+	RateLimits         RateLimits
+	DB                 *sql.DB
+	// Jobs queues background work for the job pool.
+	Jobs *jobs.Queue
+	// Mailer queues transactional email.
+	Mailer *mailer.Mailer
+
+	workoutStore      store.WorkoutStore
+	webhookDispatcher *webhooks.Dispatcher
+	outboxRelay       *outbox.Relay
+	jobPool           *jobs.Pool
+	mailSender        *mailer.Sender
 }
 
-func NewApplication() (*Application, error) {
+func NewApplication(cfg Config) (*Application, error) {
 	pgDB, err := store.Open()
 	if err != nil {
 		return nil, err
@@ -31,15 +165,122 @@ func NewApplication() (*Application, error) {
 	}
 
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
-	workoutHandler := api.NewWorkoutHandler()
+
+	// stores
+	workoutStore := store.NewPostgresWorkoutStore(pgDB)
+	statsStore := store.NewPostgresStatsStore(pgDB)
+	userStore := store.NewPostgresUserStore(pgDB)
+	tokenStore := store.NewPostgresTokenStore(pgDB)
+	tagStore := store.NewPostgresTagStore(pgDB)
+	followStore := store.NewPostgresFollowStore(pgDB)
+	commentStore := store.NewPostgresCommentStore(pgDB)
+	shareStore := store.NewPostgresShareStore(pgDB)
+	goalStore := store.NewPostgresGoalStore(pgDB)
+	measurementStore := store.NewPostgresMeasurementStore(pgDB)
+	webhookStore := store.NewPostgresWebhookStore(pgDB)
+	outboxStore := store.NewPostgresOutboxStore(pgDB)
+	jobStore := store.NewPostgresJobStore(pgDB)
+	var searchStore store.SearchStore = store.NewPostgresSearchStore(pgDB)
+	if cfg.SearchBackend == "like" {
+		searchStore = store.NewLikeSearchStore(pgDB)
+	}
+
+	// handlers
+	workoutHandler := api.NewWorkoutHandler(workoutStore, followStore, commentStore, goalStore, logger)
+	statsHandler := api.NewStatsHandler(statsStore, logger)
+	userHandler := api.NewUserHandler(userStore, logger)
+	tokenHandler := api.NewTokenHandler(tokenStore, userStore, logger)
+	calendarHandler := api.NewCalendarHandler(workoutStore, tokenStore, userStore, logger)
+	trashHandler := api.NewTrashHandler(workoutStore, cfg.TrashRetention, logger)
+	tagHandler := api.NewTagHandler(tagStore, workoutStore, logger)
+	searchHandler := api.NewSearchHandler(searchStore, logger)
+	followHandler := api.NewFollowHandler(followStore, logger)
+	commentHandler := api.NewCommentHandler(commentStore, workoutStore, followStore, logger)
+	shareHandler := api.NewShareHandler(shareStore, workoutStore, logger)
+	goalHandler := api.NewGoalHandler(goalStore, logger)
+	measurementHandler := api.NewMeasurementHandler(measurementStore, logger)
+	webhookHandler := api.NewWebhookHandler(webhookStore, logger)
+	middlewareHandler := middleware.UserMiddleware{UserStore: userStore}
+
+	// mail
+	var transport mailer.Transport = &mailer.FileTransport{Dir: cfg.Mail.Dir}
+	if cfg.Mail.SMTPHost != "" {
+		transport = &mailer.SMTPTransport{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUsername,
+			Password: cfg.Mail.SMTPPassword,
+		}
+	}
+	mailSender := mailer.NewSender(transport, logger, 1000, 2)
+	mailMailer, err := mailer.New(cfg.Mail.From, mailSender)
+	if err != nil {
+		return nil, err
+	}
+
+	// events
+	webhookDispatcher := webhooks.NewDispatcher(webhookStore, logger)
+	outboxRelay := outbox.NewRelay(outboxStore, logger)
+	for _, event := range store.WebhookEvents {
+		outboxRelay.Subscribe(string(event), webhookDispatcher.Enqueue)
+	}
+
 	app := &Application{
-		Logger:         logger,
-		WorkoutHandler: workoutHandler,
-		DB:             pgDB,
+		Config:             cfg,
+		Logger:             logger,
+		WorkoutHandler:     workoutHandler,
+		StatsHandler:       statsHandler,
+		UserHandler:        userHandler,
+		TokenHandler:       tokenHandler,
+		CalendarHandler:    calendarHandler,
+		TrashHandler:       trashHandler,
+		TagHandler:         tagHandler,
+		FollowHandler:      followHandler,
+		CommentHandler:     commentHandler,
+		ShareHandler:       shareHandler,
+		GoalHandler:        goalHandler,
+		MeasurementHandler: measurementHandler,
+		SearchHandler:      searchHandler,
+		WebhookHandler:     webhookHandler,
+		Middleware:         middlewareHandler,
+		DB:                 pgDB,
+		workoutStore:       workoutStore,
+		webhookDispatcher:  webhookDispatcher,
+		outboxRelay:        outboxRelay,
+		Jobs:               jobs.NewQueue(jobStore),
+		jobPool:            jobs.NewPool(jobStore, logger),
+		Mailer:             mailMailer,
+		mailSender:         mailSender,
 	}
+
+	if cfg.RateLimit {
+		app.RateLimits = RateLimits{
+			API:    cfg.RateLimits.API.limiter(),
+			Client: cfg.RateLimits.Client.limiter(),
+			Public: cfg.RateLimits.Public.limiter(),
+			Auth:   cfg.RateLimits.Auth.limiter(),
+		}
+	}
+
+	// background jobs
+	app.jobPool.Register(jobTrashPurge, app.purgeTrash, jobs.TypeConfig{Concurrency: 1, Timeout: 10 * time.Minute})
+
 	return app, nil
 }
 
+// Start starts running background jobs and sending mail.
+func (app *Application) Start() {
+	app.jobPool.Start()
+	app.mailSender.Start()
+}
+
+// Shutdown waits for running background jobs to finish and queued mail to go
+// out, abandoning both once ctx is done.
+func (app *Application) Shutdown(ctx context.Context) error {
+	// jobs may still queue mail, so they stop first
+	return errors.Join(app.jobPool.Shutdown(ctx), app.mailSender.Shutdown(ctx))
+}
+
 func (app *Application) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Server is up and running ✅\n")
 }
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// outboxPurgeInterval is how often delivered outbox events past the
+// retention are removed.
+const outboxPurgeInterval = time.Hour
+
+// RunOutboxRelay polls the outbox every interval and hands new events to
+// their subscribers until ctx is cancelled. A pass that fills its batch is
+// followed by another one straight away.
+func (app *Application) RunOutboxRelay(ctx context.Context, interval time.Duration) {
+	lastPurge := time.Now()
+
+	poll(ctx, interval, func(ctx context.Context) bool {
+		relayed, err := app.outboxRelay.RelayPending(ctx)
+		if err != nil && ctx.Err() == nil {
+			app.Logger.Printf("ERROR: relayOutbox: %v", err)
+		}
+
+		if time.Since(lastPurge) >= outboxPurgeInterval {
+			lastPurge = time.Now()
+			_, err := app.outboxRelay.Store.PurgeOutbox(time.Now().Add(-app.Config.OutboxRetention))
+			if err != nil {
+				app.Logger.Printf("ERROR: purgeOutbox: %v", err)
+			}
+		}
+
+		return err == nil && relayed == app.outboxRelay.BatchSize
+	})
+}
@@ -0,0 +1,26 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// poll runs pass every interval until ctx is cancelled. A pass that reports
+// more work, typically because it filled its batch, is followed by another
+// one straight away.
+func poll(ctx context.Context, interval time.Duration, pass func(ctx context.Context) (more bool)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if pass(ctx) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
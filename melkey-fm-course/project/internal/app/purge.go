@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+)
+
+const jobTrashPurge = "trash.purge"
+
+// RunTrashPurge queues a purge of expired trash every interval until ctx is
+// cancelled. The job is unique, so instances sharing the database do not
+// purge side by side.
+func (app *Application) RunTrashPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	key := jobTrashPurge
+	for {
+		_, err := app.Jobs.Add(&store.Job{Type: jobTrashPurge, UniqueKey: &key, MaxAttempts: 1})
+		if err != nil {
+			app.Logger.Printf("ERROR: enqueueTrashPurge: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeTrash hard deletes workouts that have been in the trash longer than
+// the configured retention.
+func (app *Application) purgeTrash(ctx context.Context, job *store.Job) error {
+	purged, err := app.workoutStore.PurgeTrash(time.Now().Add(-app.Config.TrashRetention))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		app.Logger.Printf("purged %d workouts from the trash", purged)
+	}
+	return nil
+}
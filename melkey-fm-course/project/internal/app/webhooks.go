@@ -0,0 +1,22 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// RunWebhookDeliveries sends queued webhook deliveries every interval until
+// ctx is cancelled. A pass that fills its batch is followed by another one
+// straight away.
+func (app *Application) RunWebhookDeliveries(ctx context.Context, interval time.Duration) {
+	poll(ctx, interval, func(ctx context.Context) bool {
+		sent, err := app.webhookDispatcher.DeliverDue(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				app.Logger.Printf("ERROR: deliverWebhooks: %v", err)
+			}
+			return false
+		}
+		return sent == app.webhookDispatcher.BatchSize
+	})
+}
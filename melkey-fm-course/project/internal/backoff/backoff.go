@@ -0,0 +1,23 @@
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Exponential returns base doubled for every failed attempt after the first,
+// capped at maxDelay.
+func Exponential(base, maxDelay time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+// Jittered is Exponential plus up to 10% jitter, so that callers recovering
+// from the same outage do not all retry at once.
+func Jittered(base, maxDelay time.Duration, attempts int) time.Duration {
+	delay := Exponential(base, maxDelay, attempts)
+	return delay + rand.N(delay/10+1)
+}
@@ -0,0 +1,43 @@
+package backoff
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExponential(t *testing.T) {
+	tests := []struct {
+		base, maxDelay time.Duration
+		attempts       int
+		want           time.Duration
+	}{
+		{time.Second, time.Minute, 0, time.Second},
+		{time.Second, time.Minute, 1, time.Second},
+		{time.Second, time.Minute, 2, 2 * time.Second},
+		{time.Second, time.Minute, 6, 32 * time.Second},
+		{time.Second, time.Minute, 7, time.Minute},
+		{time.Second, time.Minute, 1000, time.Minute},
+		{time.Hour, time.Minute, 1, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempts), func(t *testing.T) {
+			if got := Exponential(tt.base, tt.maxDelay, tt.attempts); got != tt.want {
+				t.Errorf("Exponential(%v, %v, %d) = %v, want %v", tt.base, tt.maxDelay, tt.attempts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJittered(t *testing.T) {
+	for attempts := 1; attempts <= 10; attempts++ {
+		want := Exponential(time.Second, time.Minute, attempts)
+		for range 100 {
+			got := Jittered(time.Second, time.Minute, attempts)
+			if got < want || got > want+want/10 {
+				t.Fatalf("Jittered(%d) = %v, want between %v and %v", attempts, got, want, want+want/10)
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	utcLayout   = "20060102T150405Z"
+	localLayout = "20060102T150405"
+)
+
+// Event is a single VEVENT. An event with RecurrenceID set overrides that
+// occurrence of the recurring event sharing its UID.
+type Event struct {
+	UID          string
+	Start        time.Time
+	Duration     time.Duration
+	Summary      string
+	Description  string
+	Rule         *Rule
+	RecurrenceID *time.Time
+	Status       string
+	Updated      time.Time
+}
+
+type Calendar struct {
+	Name   string
+	Events []Event
+	// Location, when set to anything but UTC, writes start times as local
+	// times with its IANA name as TZID so clients expand recurring events on
+	// the owner's wall clock across DST changes. The matching VTIMEZONE is
+	// written along with them.
+	Location *time.Location
+}
+
+// WriteTo renders the calendar as an RFC 5545 document with CRLF line endings
+// and lines folded at 75 octets.
+func (c *Calendar) WriteTo(w io.Writer) (int64, error) {
+	cw := &contentWriter{w: bufio.NewWriter(w)}
+
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:-//workouts//calendar//EN")
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		cw.line("X-WR-CALNAME:" + escapeText(c.Name))
+	}
+	if c.local() {
+		cw.line("X-WR-TIMEZONE:" + c.Location.String())
+		c.writeTimezone(cw)
+	}
+
+	now := time.Now().UTC().Format(utcLayout)
+	for _, event := range c.Events {
+		cw.line("BEGIN:VEVENT")
+		cw.line("UID:" + event.UID)
+		if event.Updated.IsZero() {
+			cw.line("DTSTAMP:" + now)
+		} else {
+			cw.line("DTSTAMP:" + event.Updated.UTC().Format(utcLayout))
+		}
+		cw.line("DTSTART" + c.dateTime(event.Start))
+		if event.Duration > 0 {
+			cw.line("DURATION:" + formatDuration(event.Duration))
+		}
+		if event.RecurrenceID != nil {
+			cw.line("RECURRENCE-ID" + c.dateTime(*event.RecurrenceID))
+		}
+		if event.Rule != nil {
+			cw.line("RRULE:" + event.Rule.String())
+		}
+		cw.line("SUMMARY:" + escapeText(event.Summary))
+		if event.Description != "" {
+			cw.line("DESCRIPTION:" + escapeText(event.Description))
+		}
+		if event.Status != "" {
+			cw.line("STATUS:" + event.Status)
+		}
+		cw.line("END:VEVENT")
+	}
+
+	cw.line("END:VCALENDAR")
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+func (c *Calendar) local() bool {
+	return c.Location != nil && c.Location != time.UTC && c.Location.String() != "UTC"
+}
+
+// dateTime formats t as the value of a DTSTART-like property, including the
+// separating colon and any TZID parameter.
+func (c *Calendar) dateTime(t time.Time) string {
+	if c.local() {
+		return ";TZID=" + c.Location.String() + ":" + t.In(c.Location).Format(localLayout)
+	}
+	return ":" + t.UTC().Format(utcLayout)
+}
+
+// formatDuration renders d as an RFC 5545 DURATION, in whole seconds.
+func formatDuration(d time.Duration) string {
+	seconds := int64(d / time.Second)
+	if seconds == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	b.WriteString("PT")
+	if h := seconds / 3600; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+	}
+	if m := seconds / 60 % 60; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if s := seconds % 60; s > 0 {
+		fmt.Fprintf(&b, "%dS", s)
+	}
+	return b.String()
+}
+
+type contentWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+// line writes one content line, folding it so no physical line exceeds 75
+// octets without splitting a UTF-8 sequence.
+func (cw *contentWriter) line(s string) {
+	if cw.err != nil {
+		return
+	}
+
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		cw.write(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// continuation lines lose one octet to the leading space
+		limit = 74
+	}
+	cw.write(s + "\r\n")
+}
+
+func (cw *contentWriter) write(s string) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.WriteString(s)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
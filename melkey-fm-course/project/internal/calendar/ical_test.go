@@ -0,0 +1,416 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// document joins content lines the way WriteTo ends them.
+func document(lines ...string) string {
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func writeCalendar(t *testing.T, c *Calendar) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	n, err := c.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo returned %d, wrote %d bytes", n, buf.Len())
+	}
+	return buf.String()
+}
+
+func TestWriteToUTC(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Calendar{
+		Name: "Ana's runs, rides; more",
+		Events: []Event{
+			{
+				UID:         "workout-1@workouts",
+				Start:       time.Date(2024, 3, 4, 7, 30, 0, 0, newYork),
+				Duration:    time.Hour + 5*time.Minute + 30*time.Second,
+				Summary:     "Tempo run",
+				Description: "5 x 1km\nat 4:10/km, easy back",
+				Rule:        &Rule{Freq: FrequencyWeekly, Interval: 1, ByDay: []time.Weekday{time.Monday}},
+				Updated:     updated,
+			},
+			{
+				UID:     "workout-2@workouts",
+				Start:   time.Date(2024, 3, 5, 18, 0, 0, 0, time.UTC),
+				Summary: `Legs \ core`,
+				Status:  "CANCELLED",
+				Updated: updated,
+			},
+		},
+	}
+
+	want := document(
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//workouts//calendar//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		`X-WR-CALNAME:Ana's runs\, rides\; more`,
+		"BEGIN:VEVENT",
+		"UID:workout-1@workouts",
+		"DTSTAMP:20240301T080000Z",
+		"DTSTART:20240304T123000Z",
+		"DURATION:PT1H5M30S",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO",
+		"SUMMARY:Tempo run",
+		`DESCRIPTION:5 x 1km\nat 4:10/km\, easy back`,
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:workout-2@workouts",
+		"DTSTAMP:20240301T080000Z",
+		"DTSTART:20240305T180000Z",
+		`SUMMARY:Legs \\ core`,
+		"STATUS:CANCELLED",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	)
+
+	for _, loc := range []*time.Location{nil, time.UTC} {
+		c.Location = loc
+		if got := writeCalendar(t, c); got != want {
+			t.Errorf("Location %v: WriteTo =\n%s\nwant\n%s", loc, got, want)
+		}
+	}
+}
+
+func TestWriteToLocal(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	start := time.Date(2024, 3, 25, 7, 0, 0, 0, berlin)
+	moved := time.Date(2024, 4, 1, 7, 0, 0, 0, berlin)
+
+	c := &Calendar{
+		Name:     "Runs",
+		Location: berlin,
+		Events: []Event{
+			{
+				UID:      "workout-1@workouts",
+				Start:    start,
+				Duration: 45 * time.Minute,
+				Summary:  "Easy run",
+				Rule:     &Rule{Freq: FrequencyWeekly, Interval: 1},
+				Updated:  updated,
+			},
+			{
+				UID:          "workout-1@workouts",
+				Start:        moved.Add(90 * time.Minute),
+				Duration:     50 * time.Second,
+				Summary:      "Easy run",
+				RecurrenceID: &moved,
+				Updated:      updated,
+			},
+		},
+	}
+
+	// the override falls after the change to summer time, and is still
+	// written on the local clock
+	want := document(
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//workouts//calendar//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:Runs",
+		"X-WR-TIMEZONE:Europe/Berlin",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Berlin",
+		"BEGIN:STANDARD",
+		"DTSTART:20240101T000000",
+		"TZOFFSETFROM:+0100",
+		"TZOFFSETTO:+0100",
+		"TZNAME:CET",
+		"END:STANDARD",
+		"BEGIN:DAYLIGHT",
+		"DTSTART:20240331T020000",
+		"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU",
+		"TZOFFSETFROM:+0100",
+		"TZOFFSETTO:+0200",
+		"TZNAME:CEST",
+		"END:DAYLIGHT",
+		"BEGIN:STANDARD",
+		"DTSTART:20241027T030000",
+		"RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU",
+		"TZOFFSETFROM:+0200",
+		"TZOFFSETTO:+0100",
+		"TZNAME:CET",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:workout-1@workouts",
+		"DTSTAMP:20240301T080000Z",
+		"DTSTART;TZID=Europe/Berlin:20240325T070000",
+		"DURATION:PT45M",
+		"RRULE:FREQ=WEEKLY",
+		"SUMMARY:Easy run",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:workout-1@workouts",
+		"DTSTAMP:20240301T080000Z",
+		"DTSTART;TZID=Europe/Berlin:20240401T083000",
+		"DURATION:PT50S",
+		"RECURRENCE-ID;TZID=Europe/Berlin:20240401T070000",
+		"SUMMARY:Easy run",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	)
+
+	if got := writeCalendar(t, c); got != want {
+		t.Errorf("WriteTo =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteToTimezones(t *testing.T) {
+	tests := []struct {
+		name  string
+		zone  string
+		start time.Time
+		want  []string
+	}{
+		{
+			name:  "zone without daylight saving time",
+			zone:  "Asia/Tokyo",
+			start: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{
+				"BEGIN:VTIMEZONE",
+				"TZID:Asia/Tokyo",
+				"BEGIN:STANDARD",
+				"DTSTART:20240101T000000",
+				"TZOFFSETFROM:+0900",
+				"TZOFFSETTO:+0900",
+				"TZNAME:JST",
+				"END:STANDARD",
+				"END:VTIMEZONE",
+			},
+		},
+		{
+			name:  "southern hemisphere",
+			zone:  "Australia/Sydney",
+			start: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{
+				"BEGIN:VTIMEZONE",
+				"TZID:Australia/Sydney",
+				"BEGIN:DAYLIGHT",
+				"DTSTART:20240101T000000",
+				"TZOFFSETFROM:+1100",
+				"TZOFFSETTO:+1100",
+				"TZNAME:AEDT",
+				"END:DAYLIGHT",
+				"BEGIN:STANDARD",
+				"DTSTART:20240407T030000",
+				"RRULE:FREQ=YEARLY;BYMONTH=4;BYDAY=1SU",
+				"TZOFFSETFROM:+1100",
+				"TZOFFSETTO:+1000",
+				"TZNAME:AEST",
+				"END:STANDARD",
+				"BEGIN:DAYLIGHT",
+				"DTSTART:20241006T020000",
+				"RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=1SU",
+				"TZOFFSETFROM:+1000",
+				"TZOFFSETTO:+1100",
+				"TZNAME:AEDT",
+				"END:DAYLIGHT",
+				"END:VTIMEZONE",
+			},
+		},
+		{
+			name:  "second sunday",
+			zone:  "America/New_York",
+			start: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+			want: []string{
+				"BEGIN:VTIMEZONE",
+				"TZID:America/New_York",
+				"BEGIN:STANDARD",
+				"DTSTART:20250101T000000",
+				"TZOFFSETFROM:-0500",
+				"TZOFFSETTO:-0500",
+				"TZNAME:EST",
+				"END:STANDARD",
+				"BEGIN:DAYLIGHT",
+				"DTSTART:20250309T020000",
+				"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU",
+				"TZOFFSETFROM:-0500",
+				"TZOFFSETTO:-0400",
+				"TZNAME:EDT",
+				"END:DAYLIGHT",
+				"BEGIN:STANDARD",
+				"DTSTART:20251102T020000",
+				"RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU",
+				"TZOFFSETFROM:-0400",
+				"TZOFFSETTO:-0500",
+				"TZNAME:EST",
+				"END:STANDARD",
+				"END:VTIMEZONE",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(tt.zone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &Calendar{
+				Location: loc,
+				Events:   []Event{{UID: "1", Start: tt.start, Updated: tt.start}},
+			}
+
+			got := writeCalendar(t, c)
+			begin := strings.Index(got, "BEGIN:VTIMEZONE")
+			end := strings.Index(got, "END:VTIMEZONE")
+			if begin < 0 || end < 0 {
+				t.Fatalf("no VTIMEZONE in\n%s", got)
+			}
+			got = got[begin : end+len("END:VTIMEZONE\r\n")]
+			if want := document(tt.want...); got != want {
+				t.Errorf("VTIMEZONE =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteToNoEventsHasNoTimezone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := writeCalendar(t, &Calendar{Location: berlin})
+	if strings.Contains(got, "VTIMEZONE") {
+		t.Errorf("empty calendar has a VTIMEZONE:\n%s", got)
+	}
+}
+
+func TestContentLineFolding(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{
+			name: "75 octets fit",
+			line: strings.Repeat("a", 75),
+			want: []string{strings.Repeat("a", 75)},
+		},
+		{
+			name: "76 octets fold",
+			line: strings.Repeat("a", 76),
+			want: []string{strings.Repeat("a", 75), " a"},
+		},
+		{
+			name: "continuation lines hold 74 octets",
+			line: strings.Repeat("a", 75+74+1),
+			want: []string{strings.Repeat("a", 75), " " + strings.Repeat("a", 74), " a"},
+		},
+		{
+			name: "two octet runes are not split",
+			line: strings.Repeat("a", 74) + "éé",
+			want: []string{strings.Repeat("a", 74), " éé"},
+		},
+		{
+			name: "four octet runes are not split",
+			line: strings.Repeat("a", 72) + "💪💪",
+			want: []string{strings.Repeat("a", 72), " 💪💪"},
+		},
+		{
+			name: "multibyte continuation lines",
+			line: strings.Repeat("ü", 80),
+			want: []string{
+				strings.Repeat("ü", 37),
+				" " + strings.Repeat("ü", 37),
+				" " + strings.Repeat("ü", 6),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			cw := &contentWriter{w: bufio.NewWriter(&buf)}
+			cw.line(tt.line)
+			if err := cw.w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := buf.String(), document(tt.want...); got != want {
+				t.Errorf("line(%q) =\n%q\nwant\n%q", tt.line, got, want)
+			}
+			for _, physical := range tt.want {
+				if len(physical) > 75 || !utf8.ValidString(physical) {
+					t.Errorf("bad expectation %q", physical)
+				}
+			}
+			if unfolded := strings.ReplaceAll(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n ", ""); unfolded != tt.line {
+				t.Errorf("unfolded = %q, want %q", unfolded, tt.line)
+			}
+		})
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Leg day", "Leg day"},
+		{"squats, lunges", `squats\, lunges`},
+		{"5x5; heavy", `5x5\; heavy`},
+		{`C:\plans`, `C:\\plans`},
+		{"line one\nline two", `line one\nline two`},
+		{"line one\r\nline two", `line one\nline two`},
+		{`\,`, `\\\,`},
+		{"Prés: 10:00", "Prés: 10:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := escapeText(tt.in); got != tt.want {
+				t.Errorf("escapeText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{500 * time.Millisecond, "PT0S"},
+		{30 * time.Second, "PT30S"},
+		{45 * time.Minute, "PT45M"},
+		{90 * time.Second, "PT1M30S"},
+		{time.Hour, "PT1H"},
+		{time.Hour + 30*time.Second, "PT1H30S"},
+		{26*time.Hour + 5*time.Minute + 9*time.Second, "PT26H5M9S"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.d.String(), func(t *testing.T) {
+			if got := formatDuration(tt.d); got != tt.want {
+				t.Errorf("formatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	FrequencyDaily  Frequency = "DAILY"
+	FrequencyWeekly Frequency = "WEEKLY"
+)
+
+// maxOccurrences bounds expansion of open ended rules.
+const maxOccurrences = 5000
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Rule is the subset of RFC 5545 RRULE we support: FREQ=DAILY or WEEKLY with
+// INTERVAL, BYDAY (weekly only), COUNT and UNTIL.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []time.Weekday
+	Count    int
+	Until    *time.Time
+}
+
+func ParseRule(value string) (*Rule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return nil, errors.New("recurrence is empty")
+	}
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("recurrence part %q is not KEY=VALUE", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(val))
+			if rule.Freq != FrequencyDaily && rule.Freq != FrequencyWeekly {
+				return nil, errors.New("only FREQ=DAILY and FREQ=WEEKLY are supported")
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, errors.New("INTERVAL must be a positive number")
+			}
+			rule.Interval = n
+		case "BYDAY":
+			for _, code := range strings.Split(val, ",") {
+				day, ok := weekdayCodes[strings.ToUpper(code)]
+				if !ok {
+					return nil, fmt.Errorf("unknown BYDAY value %q", code)
+				}
+				if !slices.Contains(rule.ByDay, day) {
+					rule.ByDay = append(rule.ByDay, day)
+				}
+			}
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, errors.New("COUNT must be a positive number")
+			}
+			rule.Count = n
+		case "UNTIL":
+			t, err := parseUntil(val)
+			if err != nil {
+				return nil, errors.New("UNTIL must be YYYYMMDD or YYYYMMDDTHHMMSSZ")
+			}
+			rule.Until = &t
+		default:
+			return nil, fmt.Errorf("recurrence part %s is not supported", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("recurrence needs a FREQ")
+	}
+	if rule.Freq == FrequencyDaily && len(rule.ByDay) > 0 {
+		return nil, errors.New("BYDAY is only supported with FREQ=WEEKLY")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, errors.New("COUNT and UNTIL cannot both be set")
+	}
+
+	slices.Sort(rule.ByDay)
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("20060102", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// a bare date includes the whole day
+	return t.Add(24*time.Hour - time.Second), nil
+}
+
+// String renders the rule in canonical RRULE form, without the "RRULE:"
+// prefix.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			codes = append(codes, strings.ToUpper(day.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences returns the occurrences of the rule anchored at start that
+// fall in [from, to). Weeks start on Monday, as RFC 5545 defaults WKST to MO.
+// Day arithmetic happens in start's location so occurrences keep their wall
+// clock time across DST changes.
+func (r *Rule) Occurrences(start, from, to time.Time) []time.Time {
+	var out []time.Time
+	r.each(start, func(t time.Time) bool {
+		if !t.Before(to) {
+			return false
+		}
+		if !t.Before(from) {
+			out = append(out, t)
+		}
+		return true
+	})
+	return out
+}
+
+// Includes reports whether t is one of the rule's occurrences.
+func (r *Rule) Includes(start, t time.Time) bool {
+	found := false
+	r.each(start, func(o time.Time) bool {
+		if o.Equal(t) {
+			found = true
+		}
+		return !found && !o.After(t)
+	})
+	return found
+}
+
+func (r *Rule) each(start time.Time, fn func(time.Time) bool) {
+	n := 0
+	emit := func(t time.Time) bool {
+		if t.Before(start) {
+			return true
+		}
+		if r.Until != nil && t.After(*r.Until) {
+			return false
+		}
+		if r.Count > 0 && n >= r.Count {
+			return false
+		}
+		n++
+		return n <= maxOccurrences && fn(t)
+	}
+
+	if r.Freq == FrequencyDaily {
+		for i := 0; ; i++ {
+			if !emit(start.AddDate(0, 0, i*r.Interval)) {
+				return
+			}
+		}
+	}
+
+	days := r.ByDay
+	if len(days) == 0 {
+		days = []time.Weekday{start.Weekday()}
+	}
+
+	// back up to the Monday of start's week
+	offset := (int(start.Weekday()) + 6) % 7
+	weekStart := start.AddDate(0, 0, -offset)
+	for week := 0; ; week++ {
+		monday := weekStart.AddDate(0, 0, week*7*r.Interval)
+		for _, day := range mondayFirst(days) {
+			if !emit(monday.AddDate(0, 0, (int(day)+6)%7)) {
+				return
+			}
+		}
+	}
+}
+
+func mondayFirst(days []time.Weekday) []time.Weekday {
+	out := slices.Clone(days)
+	slices.SortFunc(out, func(a, b time.Weekday) int {
+		return (int(a)+6)%7 - (int(b)+6)%7
+	})
+	return out
+}
@@ -0,0 +1,231 @@
+package calendar
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "FREQ=DAILY", want: "FREQ=DAILY"},
+		{value: "RRULE:FREQ=WEEKLY", want: "FREQ=WEEKLY"},
+		{value: " freq=weekly;byday=fr,mo,fr ", want: "FREQ=WEEKLY;BYDAY=MO,FR"},
+		{value: "FREQ=WEEKLY;BYDAY=SU,MO", want: "FREQ=WEEKLY;BYDAY=SU,MO"},
+		{value: "FREQ=DAILY;INTERVAL=1", want: "FREQ=DAILY"},
+		{value: "FREQ=DAILY;INTERVAL=3;COUNT=10", want: "FREQ=DAILY;INTERVAL=3;COUNT=10"},
+		{value: "FREQ=WEEKLY;UNTIL=20240131T090000Z", want: "FREQ=WEEKLY;UNTIL=20240131T090000Z"},
+		{value: "FREQ=WEEKLY;UNTIL=20240131", want: "FREQ=WEEKLY;UNTIL=20240131T235959Z"},
+		{value: "", wantErr: true},
+		{value: "RRULE:", wantErr: true},
+		{value: "FREQ=MONTHLY", wantErr: true},
+		{value: "INTERVAL=2", wantErr: true},
+		{value: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		{value: "FREQ=DAILY;INTERVAL=x", wantErr: true},
+		{value: "FREQ=DAILY;COUNT=0", wantErr: true},
+		{value: "FREQ=DAILY;BYDAY=MO", wantErr: true},
+		{value: "FREQ=WEEKLY;BYDAY=XX", wantErr: true},
+		{value: "FREQ=WEEKLY;UNTIL=tomorrow", wantErr: true},
+		{value: "FREQ=WEEKLY;COUNT=2;UNTIL=20240131", wantErr: true},
+		{value: "FREQ=WEEKLY;BYMONTH=1", wantErr: true},
+		{value: "FREQ=WEEKLY;COUNT", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			rule, err := ParseRule(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseRule(%q) = %s, want an error", tt.value, rule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRule(%q): %v", tt.value, err)
+			}
+			if got := rule.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleOccurrences(t *testing.T) {
+	// 2024-01-01 is a Monday
+	start := time.Date(2024, 1, 1, 7, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time {
+		return start.AddDate(0, 0, d-1)
+	}
+
+	tests := []struct {
+		rule  string
+		start time.Time
+		from  time.Time
+		to    time.Time
+		want  []time.Time
+	}{
+		{
+			rule: "FREQ=DAILY",
+			from: day(1), to: day(4),
+			want: []time.Time{day(1), day(2), day(3)},
+		},
+		{
+			rule: "FREQ=DAILY;INTERVAL=2",
+			from: day(1), to: day(8),
+			want: []time.Time{day(1), day(3), day(5), day(7)},
+		},
+		{
+			rule: "FREQ=DAILY;COUNT=3",
+			from: day(1), to: day(30),
+			want: []time.Time{day(1), day(2), day(3)},
+		},
+		{
+			// COUNT counts from start, not from the window
+			rule: "FREQ=DAILY;COUNT=3",
+			from: day(3), to: day(30),
+			want: []time.Time{day(3)},
+		},
+		{
+			rule: "FREQ=DAILY;UNTIL=20240103",
+			from: day(1), to: day(30),
+			want: []time.Time{day(1), day(2), day(3)},
+		},
+		{
+			rule: "FREQ=DAILY;UNTIL=20240103T070000Z",
+			from: day(1), to: day(30),
+			want: []time.Time{day(1), day(2)},
+		},
+		{
+			rule: "FREQ=WEEKLY",
+			from: day(1), to: day(22),
+			want: []time.Time{day(1), day(8), day(15)},
+		},
+		{
+			rule: "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			from: day(1), to: day(10),
+			want: []time.Time{day(1), day(3), day(5), day(8)},
+		},
+		{
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH",
+			from: day(1), to: day(22),
+			want: []time.Time{day(2), day(4), day(16), day(18)},
+		},
+		{
+			// Sunday ends the week that started on Monday
+			rule: "FREQ=WEEKLY;BYDAY=SU,MO;COUNT=4",
+			from: day(1), to: day(60),
+			want: []time.Time{day(1), day(7), day(8), day(14)},
+		},
+		{
+			// days before start in its first week are skipped and not counted
+			rule:  "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=3",
+			start: day(3),
+			from:  day(1), to: day(60),
+			want: []time.Time{day(5), day(8), day(12)},
+		},
+		{
+			rule: "FREQ=DAILY",
+			from: day(10), to: day(10),
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := ParseRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRule: %v", err)
+			}
+			ruleStart := tt.start
+			if ruleStart.IsZero() {
+				ruleStart = start
+			}
+			got := rule.Occurrences(ruleStart, tt.from, tt.to)
+			if !slices.EqualFunc(got, tt.want, time.Time.Equal) {
+				t.Errorf("Occurrences = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleOccurrencesKeepWallClockAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	// clocks go forward on Sunday 2024-03-10
+	start := time.Date(2024, 3, 8, 6, 0, 0, 0, newYork)
+	rule, err := ParseRule("FREQ=DAILY;COUNT=4")
+	if err != nil {
+		t.Fatalf("ParseRule: %v", err)
+	}
+
+	got := rule.Occurrences(start, start, start.AddDate(0, 1, 0))
+	if len(got) != 4 {
+		t.Fatalf("got %d occurrences, want 4", len(got))
+	}
+	for _, o := range got {
+		if o.Hour() != 6 || o.Minute() != 0 {
+			t.Errorf("occurrence %v is not at 06:00 local time", o)
+		}
+	}
+	if gap := got[3].Sub(got[2]); gap != 24*time.Hour {
+		t.Errorf("gap after the change = %v, want 24h", gap)
+	}
+	if gap := got[2].Sub(got[1]); gap != 23*time.Hour {
+		t.Errorf("gap across the change = %v, want 23h", gap)
+	}
+}
+
+func TestRuleOccurrencesOpenEndedIsBounded(t *testing.T) {
+	rule, err := ParseRule("FREQ=DAILY")
+	if err != nil {
+		t.Fatalf("ParseRule: %v", err)
+	}
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	got := rule.Occurrences(start, start, start.AddDate(100, 0, 0))
+	if len(got) != maxOccurrences {
+		t.Errorf("got %d occurrences, want %d", len(got), maxOccurrences)
+	}
+}
+
+func TestRuleIncludes(t *testing.T) {
+	start := time.Date(2024, 1, 1, 7, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		rule string
+		t    time.Time
+		want bool
+	}{
+		{"FREQ=DAILY", start, true},
+		{"FREQ=DAILY", start.AddDate(0, 0, 5), true},
+		{"FREQ=DAILY", start.AddDate(0, 0, 5).Add(time.Minute), false},
+		{"FREQ=DAILY", start.AddDate(0, 0, -1), false},
+		{"FREQ=DAILY;INTERVAL=2", start.AddDate(0, 0, 3), false},
+		{"FREQ=DAILY;INTERVAL=2", start.AddDate(0, 0, 4), true},
+		{"FREQ=DAILY;COUNT=3", start.AddDate(0, 0, 2), true},
+		{"FREQ=DAILY;COUNT=3", start.AddDate(0, 0, 3), false},
+		{"FREQ=WEEKLY;BYDAY=TU", start, false},
+		{"FREQ=WEEKLY;BYDAY=TU", start.AddDate(0, 0, 8), true},
+		{"FREQ=WEEKLY;UNTIL=20240114", start.AddDate(0, 0, 7), true},
+		{"FREQ=WEEKLY;UNTIL=20240114", start.AddDate(0, 0, 14), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule+" "+tt.t.Format(time.DateOnly), func(t *testing.T) {
+			rule, err := ParseRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRule: %v", err)
+			}
+			if got := rule.Includes(start, tt.t); got != tt.want {
+				t.Errorf("Includes(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// transition is a change of a zone's UTC offset, or the offset already in
+// effect where a VTIMEZONE starts.
+type transition struct {
+	at   time.Time
+	from int
+	to   int
+	name string
+	dst  bool
+	// rule, when set, repeats the transition every year.
+	rule string
+}
+
+// wallClock is the local time the transition happens at, on the clock that
+// was in effect before it, as RFC 5545 wants for DTSTART in observances.
+func (tr transition) wallClock() time.Time {
+	return tr.at.UTC().Add(time.Duration(tr.from) * time.Second)
+}
+
+// writeTimezone writes the VTIMEZONE that TZID=c.Location refers to, covering
+// the years the events start in and repeating the zone's current yearly
+// changes after that.
+func (c *Calendar) writeTimezone(cw *contentWriter) {
+	first, last, ok := c.years()
+	if !ok {
+		return
+	}
+
+	transitions := zoneTransitions(c.Location, first, last)
+	if !addYearlyRules(transitions) {
+		// the zone follows no yearly pattern we can express; list its
+		// changes for another decade instead
+		transitions = zoneTransitions(c.Location, first, last+10)
+	}
+
+	cw.line("BEGIN:VTIMEZONE")
+	cw.line("TZID:" + c.Location.String())
+	for _, tr := range transitions {
+		kind := "STANDARD"
+		if tr.dst {
+			kind = "DAYLIGHT"
+		}
+		cw.line("BEGIN:" + kind)
+		cw.line("DTSTART:" + tr.wallClock().Format(localLayout))
+		if tr.rule != "" {
+			cw.line("RRULE:" + tr.rule)
+		}
+		cw.line("TZOFFSETFROM:" + formatOffset(tr.from))
+		cw.line("TZOFFSETTO:" + formatOffset(tr.to))
+		cw.line("TZNAME:" + escapeText(tr.name))
+		cw.line("END:" + kind)
+	}
+	cw.line("END:VTIMEZONE")
+}
+
+// years returns the first and last year, in c.Location, that an event starts
+// or overrides an occurrence in.
+func (c *Calendar) years() (first, last int, ok bool) {
+	for _, event := range c.Events {
+		times := []time.Time{event.Start}
+		if event.RecurrenceID != nil {
+			times = append(times, *event.RecurrenceID)
+		}
+		for _, t := range times {
+			year := t.In(c.Location).Year()
+			if !ok || year < first {
+				first = year
+			}
+			if !ok || year > last {
+				last = year
+			}
+			ok = true
+		}
+	}
+	return first, last, ok
+}
+
+// zoneTransitions lists the offset loc has at the start of year first and
+// every change of it until the end of year last.
+func zoneTransitions(loc *time.Location, first, last int) []transition {
+	start := time.Date(first, 1, 1, 0, 0, 0, 0, loc)
+	end := time.Date(last+1, 1, 1, 0, 0, 0, 0, loc)
+
+	name, offset := start.Zone()
+	transitions := []transition{{at: start, from: offset, to: offset, name: name, dst: start.IsDST()}}
+	for t := start; ; {
+		next, ok := nextTransition(t)
+		if !ok || !next.at.Before(end) {
+			return transitions
+		}
+		transitions = append(transitions, next)
+		t = next.at
+	}
+}
+
+// nextTransition returns the first change of offset after t, if the zone has
+// one.
+func nextTransition(t time.Time) (transition, bool) {
+	_, end := t.ZoneBounds()
+	if end.IsZero() {
+		return transition{}, false
+	}
+	_, from := t.Zone()
+	next := end.In(t.Location())
+	name, to := next.Zone()
+	return transition{at: next, from: from, to: to, name: name, dst: next.IsDST()}, true
+}
+
+// addYearlyRules gives the last two transitions a yearly RRULE when the zone
+// keeps repeating them on the same weekday of the same month, so clients can
+// place events after the last one listed. It reports whether the offsets
+// after the last transition follow from transitions.
+func addYearlyRules(transitions []transition) bool {
+	last := transitions[len(transitions)-1]
+	next, ok := nextTransition(last.at)
+	if !ok {
+		return true
+	}
+	if len(transitions) < 3 {
+		return false
+	}
+	prev := transitions[len(transitions)-2]
+	if prev.dst == last.dst {
+		return false
+	}
+	afterNext, ok := nextTransition(next.at)
+	if !ok {
+		return false
+	}
+
+	prevRule, prevOK := yearlyRule(prev, next)
+	lastRule, lastOK := yearlyRule(last, afterNext)
+	if !prevOK || !lastOK {
+		return false
+	}
+	transitions[len(transitions)-2].rule = prevRule
+	transitions[len(transitions)-1].rule = lastRule
+	return true
+}
+
+// yearlyRule describes tr as the nth weekday of its month, or the last one,
+// and checks that the rule predicts next, the same change a year later.
+func yearlyRule(tr, next transition) (string, bool) {
+	if tr.from != next.from || tr.to != next.to || tr.name != next.name {
+		return "", false
+	}
+
+	wall := tr.wallClock()
+	n := (wall.Day()-1)/7 + 1
+	if wall.Day()+7 > daysIn(wall.Year(), wall.Month()) {
+		n = -1
+	}
+
+	day := weekdayInMonth(wall.Year()+1, wall.Month(), wall.Weekday(), n)
+	want := time.Date(wall.Year()+1, wall.Month(), day, wall.Hour(), wall.Minute(), wall.Second(), 0, time.UTC)
+	if !next.wallClock().Equal(want) {
+		return "", false
+	}
+
+	code := strings.ToUpper(wall.Weekday().String()[:2])
+	return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", int(wall.Month()), n, code), true
+}
+
+// weekdayInMonth returns the day of the nth weekday in the month, counting
+// from the end when n is negative.
+func weekdayInMonth(year int, month time.Month, weekday time.Weekday, n int) int {
+	if n < 0 {
+		last := time.Date(year, month, daysIn(year, month), 0, 0, 0, 0, time.UTC)
+		return last.Day() - (7+int(last.Weekday())-int(weekday))%7 + (n+1)*7
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return 1 + (7+int(weekday)-int(first.Weekday()))%7 + (n-1)*7
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// formatOffset renders a UTC offset in seconds as RFC 5545 UTC-OFFSET.
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	s := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+	if seconds%60 != 0 {
+		s += fmt.Sprintf("%02d", seconds%60)
+	}
+	return s
+}
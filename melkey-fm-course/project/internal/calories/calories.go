@@ -0,0 +1,152 @@
+package calories
+
+import (
+	"math"
+	"strings"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+)
+
+// DefaultMET is used for exercises that are not in the MET table. It sits
+// roughly at "general resistance training, vigorous effort".
+const DefaultMET = 5.0
+
+// metTable maps lower-cased exercise names to their metabolic equivalent,
+// based on the Compendium of Physical Activities. Longer names are matched
+// first, so "incline bench press" wins over "bench press".
+var metTable = map[string]float64{
+	"running":             9.8,
+	"run":                 9.8,
+	"jogging":             7.0,
+	"walking":             3.5,
+	"walk":                3.5,
+	"hiking":              6.0,
+	"cycling":             7.5,
+	"bike":                7.5,
+	"spin":                8.5,
+	"rowing":              7.0,
+	"row machine":         7.0,
+	"swimming":            8.0,
+	"elliptical":          5.0,
+	"stair climber":       9.0,
+	"jump rope":           12.3,
+	"skipping":            12.3,
+	"burpee":              8.0,
+	"jumping jack":        7.7,
+	"mountain climber":    8.0,
+	"plank":               3.8,
+	"yoga":                2.5,
+	"stretching":          2.3,
+	"pilates":             3.0,
+	"push up":             3.8,
+	"push-up":             3.8,
+	"pull up":             8.0,
+	"pull-up":             8.0,
+	"chin up":             8.0,
+	"dip":                 5.0,
+	"squat":               5.0,
+	"front squat":         5.0,
+	"deadlift":            6.0,
+	"romanian deadlift":   5.0,
+	"bench press":         5.0,
+	"incline bench press": 5.0,
+	"overhead press":      5.0,
+	"shoulder press":      5.0,
+	"barbell row":         5.0,
+	"lunge":               4.0,
+	"leg press":           5.0,
+	"bicep curl":          3.5,
+	"curl":                3.5,
+	"tricep extension":    3.5,
+	"lateral raise":       3.5,
+	"kettlebell swing":    9.8,
+	"clean":               6.0,
+	"snatch":              6.0,
+	"thruster":            8.0,
+	"crunch":              2.8,
+	"sit up":              2.8,
+	"sit-up":              2.8,
+	"boxing":              7.8,
+	"hiit":                8.0,
+	"circuit":             8.0,
+}
+
+// MET returns the metabolic equivalent for an exercise name, falling back to
+// DefaultMET when nothing in the table matches.
+func MET(exerciseName string) float64 {
+	name := strings.ToLower(strings.TrimSpace(exerciseName))
+	if met, ok := metTable[name]; ok {
+		return met
+	}
+
+	best, bestKey := DefaultMET, ""
+	for key, met := range metTable {
+		if !strings.Contains(name, key) {
+			continue
+		}
+		if len(key) > len(bestKey) || (len(key) == len(bestKey) && key < bestKey) {
+			best, bestKey = met, key
+		}
+	}
+	return best
+}
+
+// Estimate returns the estimated calories for a workout using
+// kcal = MET × body weight (kg) × hours. Timed entries use their own
+// duration, repeated for every round of their group; whatever is left of the
+// workout duration is split across the rep based entries, one share per
+// round.
+func Estimate(workout *store.Workout, bodyWeightKg float64) int {
+	if bodyWeightKg <= 0 {
+		return 0
+	}
+
+	entries := workout.FlatEntries()
+	if len(entries) == 0 {
+		return kcal(DefaultMET, bodyWeightKg, float64(workout.DurationSeconds)/60)
+	}
+
+	timedSeconds := 0
+	untimed := 0
+	for _, entry := range entries {
+		if entry.DurationSeconds != nil {
+			timedSeconds += *entry.DurationSeconds * entry.Rounds
+		} else {
+			untimed += entry.Rounds
+		}
+	}
+
+	remaining := float64(workout.DurationSeconds - timedSeconds)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	total := 0.0
+	for _, entry := range entries {
+		seconds := 0.0
+		if entry.DurationSeconds != nil {
+			seconds = float64(*entry.DurationSeconds * entry.Rounds)
+		} else {
+			seconds = remaining / float64(untimed) * float64(entry.Rounds)
+		}
+		total += MET(entry.ExerciseName) * bodyWeightKg * seconds / 3600
+	}
+
+	return int(math.Round(total))
+}
+
+// Fill sets CaloriesBurned from an estimate when the user did not enter a
+// value, and flags the workout as estimated.
+func Fill(workout *store.Workout, bodyWeightKg float64) {
+	if workout.CaloriesBurned != nil || bodyWeightKg <= 0 {
+		return
+	}
+
+	estimate := Estimate(workout, bodyWeightKg)
+	workout.CaloriesBurned = &estimate
+	workout.CaloriesEstimated = true
+}
+
+func kcal(met, bodyWeightKg, minutes float64) int {
+	return int(math.Round(met * bodyWeightKg * minutes / 60))
+}
@@ -0,0 +1,143 @@
+package calories
+
+import (
+	"testing"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestMET(t *testing.T) {
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{"squat", 5.0},
+		{"  YOGA ", 2.5},
+		{"Barbell Back Squat", 5.0},
+		{"Treadmill Running", 9.8},
+		{"Hammer Curl", 3.5},
+		{"Russian Kettlebell Swing", 9.8},
+		{"Rowing Machine", 7.0},
+		{"Pull-Up", 8.0},
+		{"Zercher carry", DefaultMET},
+		{"", DefaultMET},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MET(tt.name); got != tt.want {
+				t.Errorf("MET(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		name         string
+		workout      store.Workout
+		bodyWeightKg float64
+		want         int
+	}{
+		{
+			name:         "no body weight",
+			workout:      store.Workout{DurationSeconds: 3600},
+			bodyWeightKg: 0,
+			want:         0,
+		},
+		{
+			name:         "no entries uses the default MET",
+			workout:      store.Workout{DurationSeconds: 3600},
+			bodyWeightKg: 80,
+			want:         400,
+		},
+		{
+			name: "timed entry uses its own duration",
+			workout: store.Workout{DurationSeconds: 3600, Entries: []store.WorkoutEntry{
+				{ExerciseName: "Running", Sets: 1, DurationSeconds: intPtr(1800)},
+			}},
+			bodyWeightKg: 70,
+			want:         343,
+		},
+		{
+			name: "timed entries longer than the workout",
+			workout: store.Workout{DurationSeconds: 600, Entries: []store.WorkoutEntry{
+				{ExerciseName: "Running", Sets: 1, DurationSeconds: intPtr(1200)},
+				{ExerciseName: "Squat", Sets: 3, Reps: intPtr(5)},
+			}},
+			bodyWeightKg: 60,
+			want:         196,
+		},
+		{
+			name: "remaining time is split across rep based entries",
+			workout: store.Workout{DurationSeconds: 3600, Entries: []store.WorkoutEntry{
+				{ExerciseName: "Squat", Sets: 3, Reps: intPtr(5)},
+				{ExerciseName: "Plank", Sets: 2, DurationSeconds: intPtr(300)},
+				{ExerciseName: "Bench Press", Sets: 3, Reps: intPtr(5)},
+			}},
+			bodyWeightKg: 80,
+			want:         392,
+		},
+		{
+			name: "group entries count once per round",
+			workout: store.Workout{
+				DurationSeconds: 1800,
+				Entries: []store.WorkoutEntry{
+					{ExerciseName: "Squat", Sets: 3, Reps: intPtr(5), OrderIndex: 0},
+				},
+				Groups: []store.EntryGroup{{
+					Kind:       store.EntryGroupCircuit,
+					Rounds:     3,
+					OrderIndex: 1,
+					Entries: []store.WorkoutEntry{
+						{ExerciseName: "Burpee", Sets: 1, DurationSeconds: intPtr(60)},
+						{ExerciseName: "Push Up", Sets: 1, Reps: intPtr(15)},
+					},
+				}},
+			},
+			bodyWeightKg: 60,
+			want:         135,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Estimate(&tt.workout, tt.bodyWeightKg); got != tt.want {
+				t.Errorf("Estimate = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFill(t *testing.T) {
+	tests := []struct {
+		name          string
+		calories      *int
+		bodyWeightKg  float64
+		wantCalories  *int
+		wantEstimated bool
+	}{
+		{name: "fills a missing value", bodyWeightKg: 80, wantCalories: intPtr(400), wantEstimated: true},
+		{name: "keeps an entered value", calories: intPtr(250), bodyWeightKg: 80, wantCalories: intPtr(250)},
+		{name: "needs a body weight", bodyWeightKg: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workout := &store.Workout{DurationSeconds: 3600, CaloriesBurned: tt.calories}
+			Fill(workout, tt.bodyWeightKg)
+
+			if (workout.CaloriesBurned == nil) != (tt.wantCalories == nil) ||
+				(workout.CaloriesBurned != nil && *workout.CaloriesBurned != *tt.wantCalories) {
+				t.Errorf("CaloriesBurned = %v, want %v", workout.CaloriesBurned, tt.wantCalories)
+			}
+			if workout.CaloriesEstimated != tt.wantEstimated {
+				t.Errorf("CaloriesEstimated = %v, want %v", workout.CaloriesEstimated, tt.wantEstimated)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/backoff"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+)
+
+// Handler runs one job. Returning an error retries the job with backoff until
+// it runs out of attempts; ctx is cancelled once the job's timeout passes or
+// the pool gives up draining.
+type Handler func(ctx context.Context, job *store.Job) error
+
+// ErrPermanent wrapped into a handler's error fails the job without further
+// attempts, e.g. for a payload that will never decode.
+var ErrPermanent = errors.New("permanent job failure")
+
+// Queue adds jobs for a Pool, possibly in another process, to run.
+type Queue struct {
+	store store.JobStore
+}
+
+func NewQueue(jobStore store.JobStore) *Queue {
+	return &Queue{store: jobStore}
+}
+
+// Enqueue queues a job to run as soon as a worker is free.
+func (q *Queue) Enqueue(jobType string, payload any) (*store.Job, error) {
+	return q.Schedule(jobType, payload, time.Time{})
+}
+
+// Schedule queues a job to run at runAt.
+func (q *Queue) Schedule(jobType string, payload any, runAt time.Time) (*store.Job, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &store.Job{Type: jobType, Payload: body, RunAt: runAt}
+	_, err = q.store.EnqueueJob(job)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Add queues a job built by the caller, for control over MaxAttempts and
+// UniqueKey. It reports false when an unfinished job holds the same
+// UniqueKey, in which case nothing is queued.
+func (q *Queue) Add(job *store.Job) (bool, error) {
+	return q.store.EnqueueJob(job)
+}
+
+// TypeConfig tunes how a pool runs one job type.
+type TypeConfig struct {
+	// Concurrency is how many jobs of the type run at once in this process.
+	Concurrency int
+	// Timeout bounds a single run. The job stays locked for a little longer
+	// than this, after which another worker may pick it up again.
+	Timeout time.Duration
+}
+
+const (
+	defaultConcurrency = 1
+	defaultTimeout     = 5 * time.Minute
+
+	// leaseGrace keeps a job locked past its timeout so the worker has time
+	// to record the outcome.
+	leaseGrace = 30 * time.Second
+)
+
+type jobType struct {
+	name    string
+	handler Handler
+	config  TypeConfig
+	jobs    chan *store.Job
+	busy    atomic.Int32
+}
+
+// Pool claims jobs from the queue and runs them on a fixed set of workers per
+// job type, in the manner of hands-on/worker-pools: a dispatcher feeds each
+// type's workers over a channel, but only claims as many jobs as there are
+// idle workers, so nothing sits locked in a buffer.
+type Pool struct {
+	Store  store.JobStore
+	Logger *log.Logger
+	// ID is recorded on claimed jobs to tell workers apart.
+	ID           string
+	PollInterval time.Duration
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// Retention is how long finished jobs are kept before they are purged.
+	Retention time.Duration
+
+	types map[string]*jobType
+
+	stop      context.CancelFunc
+	cancelRun context.CancelFunc
+	done      chan struct{}
+	workers   sync.WaitGroup
+}
+
+func NewPool(jobStore store.JobStore, logger *log.Logger) *Pool {
+	host, _ := os.Hostname()
+	return &Pool{
+		Store:        jobStore,
+		Logger:       logger,
+		ID:           fmt.Sprintf("%s-%d", host, os.Getpid()),
+		PollInterval: time.Second,
+		BaseDelay:    5 * time.Second,
+		MaxDelay:     time.Hour,
+		Retention:    7 * 24 * time.Hour,
+		types:        map[string]*jobType{},
+	}
+}
+
+// Register sets the handler for a job type. It must be called before Start.
+func (p *Pool) Register(name string, handler Handler, config TypeConfig) {
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultConcurrency
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	p.types[name] = &jobType{
+		name:    name,
+		handler: handler,
+		config:  config,
+		jobs:    make(chan *store.Job),
+	}
+}
+
+// Start launches the workers and the dispatcher.
+func (p *Pool) Start() {
+	pollCtx, stop := context.WithCancel(context.Background())
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	p.stop, p.cancelRun = stop, cancelRun
+	p.done = make(chan struct{})
+
+	for _, t := range p.types {
+		for range t.config.Concurrency {
+			p.workers.Add(1)
+			go p.worker(runCtx, t)
+		}
+	}
+	go p.dispatch(pollCtx)
+}
+
+// Shutdown stops claiming jobs and waits for the running ones to finish. If
+// ctx ends first, the running jobs are cancelled and retried later, and
+// ctx's error is returned. It does nothing for a pool that was never started.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	if p.stop == nil {
+		return nil
+	}
+	p.stop()
+	<-p.done
+
+	drained := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		p.cancelRun()
+		return nil
+	case <-ctx.Done():
+		p.cancelRun()
+		<-drained
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) dispatch(ctx context.Context) {
+	defer close(p.done)
+	defer func() {
+		for _, t := range p.types {
+			close(t.jobs)
+		}
+	}()
+
+	ticker := time.NewTicker(p.PollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Now()
+
+	for {
+		for _, t := range p.types {
+			if ctx.Err() != nil {
+				return
+			}
+			p.claim(t)
+		}
+
+		if p.Retention > 0 && time.Since(lastPurge) >= time.Hour {
+			lastPurge = time.Now()
+			_, err := p.Store.PurgeJobs(time.Now().Add(-p.Retention))
+			if err != nil {
+				p.Logger.Printf("ERROR: purgeJobs: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim hands as many due jobs of t to its workers as it has idle ones.
+func (p *Pool) claim(t *jobType) {
+	idle := t.config.Concurrency - int(t.busy.Load())
+	if idle <= 0 {
+		return
+	}
+
+	jobs, err := p.Store.ClaimJobs(t.name, idle, t.config.Timeout+leaseGrace, p.ID)
+	if err != nil {
+		p.Logger.Printf("ERROR: claimJobs %s: %v", t.name, err)
+		return
+	}
+	for i := range jobs {
+		t.busy.Add(1)
+		t.jobs <- &jobs[i]
+	}
+}
+
+func (p *Pool) worker(ctx context.Context, t *jobType) {
+	defer p.workers.Done()
+	for job := range t.jobs {
+		p.run(ctx, t, job)
+		t.busy.Add(-1)
+	}
+}
+
+func (p *Pool) run(ctx context.Context, t *jobType, job *store.Job) {
+	ctx, cancel := context.WithTimeout(ctx, t.config.Timeout)
+	defer cancel()
+
+	err := safeRun(ctx, t.handler, job)
+
+	switch {
+	case err == nil:
+		err = p.Store.CompleteJob(job.ID, job.Attempts)
+	case errors.Is(err, ErrPermanent) || job.Attempts >= job.MaxAttempts:
+		p.Logger.Printf("ERROR: job %d (%s) failed after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+		err = p.Store.FailJob(job.ID, job.Attempts, err.Error())
+	default:
+		err = p.Store.RetryJob(job.ID, job.Attempts, time.Now().Add(p.backoff(job.Attempts)), err.Error())
+	}
+	if err != nil {
+		// the lease runs out and the job is picked up again
+		p.Logger.Printf("ERROR: settleJob %d: %v", job.ID, err)
+	}
+}
+
+// safeRun turns a panicking handler into a failed attempt rather than a
+// crashed process.
+func safeRun(ctx context.Context, handler Handler, job *store.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff doubles BaseDelay per failed attempt up to MaxDelay and adds up to
+// 10% jitter.
+func (p *Pool) backoff(attempts int) time.Duration {
+	return backoff.Jittered(p.BaseDelay, p.MaxDelay, attempts)
+}
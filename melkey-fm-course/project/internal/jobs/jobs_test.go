@@ -0,0 +1,548 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+)
+
+// memoryJobStore follows the semantics of PostgresJobStore in memory: due
+// jobs are claimed in run_at order under a lease, expired leases are claimed
+// again or failed on their last attempt, settling is guarded by the attempt
+// and unique keys only block while a job is unfinished.
+type memoryJobStore struct {
+	mu     sync.Mutex
+	jobs   []*memoryJob
+	nextID int64
+	// skew moves the store's clock forward, to expire leases.
+	skew time.Duration
+}
+
+type memoryJob struct {
+	store.Job
+	lockedUntil time.Time
+	lockedBy    string
+	finishedAt  time.Time
+}
+
+func (s *memoryJobStore) now() time.Time {
+	return time.Now().Add(s.skew)
+}
+
+func (s *memoryJobStore) EnqueueJob(job *store.Job) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job.UniqueKey != nil {
+		for _, j := range s.jobs {
+			unfinished := j.Status == store.JobQueued || j.Status == store.JobRunning
+			if unfinished && j.UniqueKey != nil && *j.UniqueKey == *job.UniqueKey {
+				return false, nil
+			}
+		}
+	}
+
+	if job.Payload == nil {
+		job.Payload = json.RawMessage(`{}`)
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = s.now()
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = 5
+	}
+	s.nextID++
+	job.ID = s.nextID
+	job.Status = store.JobQueued
+	job.CreatedAt = s.now()
+	s.jobs = append(s.jobs, &memoryJob{Job: *job})
+	return true, nil
+}
+
+func (s *memoryJobStore) ClaimJobs(jobType string, limit int, lease time.Duration, workerID string) ([]store.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+
+	var due []*memoryJob
+	for _, j := range s.jobs {
+		if j.Type != jobType {
+			continue
+		}
+		expired := j.Status == store.JobRunning && j.lockedUntil.Before(now)
+		if expired && j.Attempts >= j.MaxAttempts {
+			reason := "lease expired on the last attempt"
+			j.Status, j.LastError, j.finishedAt = store.JobFailed, &reason, now
+			continue
+		}
+		if (j.Status == store.JobQueued && !j.RunAt.After(now)) || expired {
+			due = append(due, j)
+		}
+	}
+	slices.SortFunc(due, func(a, b *memoryJob) int {
+		if c := a.RunAt.Compare(b.RunAt); c != 0 {
+			return c
+		}
+		return int(a.ID - b.ID)
+	})
+
+	claimed := []store.Job{}
+	for _, j := range due[:min(limit, len(due))] {
+		j.Status = store.JobRunning
+		j.Attempts++
+		j.lockedUntil = now.Add(lease)
+		j.lockedBy = workerID
+		claimed = append(claimed, j.Job)
+	}
+	return claimed, nil
+}
+
+// settle applies fn to the job when attempt is still its current run.
+func (s *memoryJobStore) settle(id int64, attempt int, fn func(j *memoryJob)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.ID == id && j.Attempts == attempt && j.Status == store.JobRunning {
+			j.lockedUntil, j.lockedBy = time.Time{}, ""
+			fn(j)
+		}
+	}
+	return nil
+}
+
+func (s *memoryJobStore) CompleteJob(id int64, attempt int) error {
+	return s.settle(id, attempt, func(j *memoryJob) {
+		j.Status, j.finishedAt = store.JobSucceeded, s.now()
+	})
+}
+
+func (s *memoryJobStore) RetryJob(id int64, attempt int, runAt time.Time, reason string) error {
+	return s.settle(id, attempt, func(j *memoryJob) {
+		j.Status, j.RunAt, j.LastError = store.JobQueued, runAt, &reason
+	})
+}
+
+func (s *memoryJobStore) FailJob(id int64, attempt int, reason string) error {
+	return s.settle(id, attempt, func(j *memoryJob) {
+		j.Status, j.LastError, j.finishedAt = store.JobFailed, &reason, s.now()
+	})
+}
+
+func (s *memoryJobStore) PurgeJobs(finishedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.jobs[:0]
+	for _, j := range s.jobs {
+		if j.finishedAt.IsZero() || !j.finishedAt.Before(finishedBefore) {
+			kept = append(kept, j)
+		}
+	}
+	purged := int64(len(s.jobs) - len(kept))
+	s.jobs = kept
+	return purged, nil
+}
+
+func (s *memoryJobStore) get(id int64) store.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.ID == id {
+			return j.Job
+		}
+	}
+	return store.Job{}
+}
+
+func (s *memoryJobStore) count(status store.JobStatus) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, j := range s.jobs {
+		if j.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestPool(jobStore *memoryJobStore) *Pool {
+	p := NewPool(jobStore, log.New(io.Discard, "", 0))
+	p.PollInterval = 5 * time.Millisecond
+	p.BaseDelay = 20 * time.Millisecond
+	p.MaxDelay = time.Second
+	return p
+}
+
+func enqueue(t *testing.T, q *Queue, job *store.Job) *store.Job {
+	t.Helper()
+	added, err := q.Add(job)
+	if err != nil || !added {
+		t.Fatalf("Add = %v, %v", added, err)
+	}
+	return job
+}
+
+// eventually polls cond until it holds or a few seconds have passed.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+func shutdown(t *testing.T, p *Pool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+func TestPoolConcurrencyPerType(t *testing.T) {
+	jobStore := &memoryJobStore{}
+	q := NewQueue(jobStore)
+	p := newTestPool(jobStore)
+
+	release := make(chan struct{})
+	var running, peak atomic.Int32
+	p.Register("slow", func(ctx context.Context, job *store.Job) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		<-release
+		return nil
+	}, TypeConfig{Concurrency: 2})
+
+	var otherRan atomic.Bool
+	p.Register("other", func(ctx context.Context, job *store.Job) error {
+		otherRan.Store(true)
+		return nil
+	}, TypeConfig{})
+
+	for range 5 {
+		enqueue(t, q, &store.Job{Type: "slow"})
+	}
+	other := enqueue(t, q, &store.Job{Type: "other"})
+
+	p.Start()
+	defer shutdown(t, p)
+
+	eventually(t, "two slow jobs to start", func() bool { return running.Load() == 2 })
+	// a busy type does not hold up the others
+	eventually(t, "the other job to finish", func() bool { return jobStore.get(other.ID).Status == store.JobSucceeded })
+
+	// give the dispatcher a few polls to overreach
+	time.Sleep(30 * time.Millisecond)
+	if got := peak.Load(); got != 2 {
+		t.Fatalf("%d slow jobs ran at once, want 2", got)
+	}
+	// jobs are only claimed for idle workers, not parked in a buffer
+	if got := jobStore.count(store.JobRunning); got != 2 {
+		t.Fatalf("%d jobs are claimed, want 2", got)
+	}
+
+	close(release)
+	eventually(t, "every slow job to finish", func() bool { return jobStore.count(store.JobSucceeded) == 6 })
+	if got := peak.Load(); got != 2 {
+		t.Errorf("%d slow jobs ran at once, want 2", got)
+	}
+}
+
+func TestPoolSettlesJobs(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		timeout      time.Duration
+		handler      func(attempt int, ctx context.Context) error
+		wantStatus   store.JobStatus
+		wantAttempts int
+		wantError    string
+	}{
+		{
+			name:         "success",
+			maxAttempts:  3,
+			handler:      func(int, context.Context) error { return nil },
+			wantStatus:   store.JobSucceeded,
+			wantAttempts: 1,
+		},
+		{
+			name:        "retried until it succeeds",
+			maxAttempts: 3,
+			handler: func(attempt int, _ context.Context) error {
+				if attempt < 3 {
+					return errors.New("flaky")
+				}
+				return nil
+			},
+			wantStatus:   store.JobSucceeded,
+			wantAttempts: 3,
+			wantError:    "flaky",
+		},
+		{
+			name:         "failed after MaxAttempts",
+			maxAttempts:  3,
+			handler:      func(int, context.Context) error { return errors.New("boom") },
+			wantStatus:   store.JobFailed,
+			wantAttempts: 3,
+			wantError:    "boom",
+		},
+		{
+			name:         "permanent errors are not retried",
+			maxAttempts:  3,
+			handler:      func(int, context.Context) error { return fmt.Errorf("bad payload: %w", ErrPermanent) },
+			wantStatus:   store.JobFailed,
+			wantAttempts: 1,
+			wantError:    "bad payload: permanent job failure",
+		},
+		{
+			name:        "panics are a failed attempt",
+			maxAttempts: 3,
+			handler: func(attempt int, _ context.Context) error {
+				if attempt == 1 {
+					panic("nil map")
+				}
+				return nil
+			},
+			wantStatus:   store.JobSucceeded,
+			wantAttempts: 2,
+			wantError:    "panic: nil map",
+		},
+		{
+			name:        "runs past the timeout are cancelled and retried",
+			maxAttempts: 3,
+			timeout:     20 * time.Millisecond,
+			handler: func(attempt int, ctx context.Context) error {
+				if attempt == 1 {
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				return nil
+			},
+			wantStatus:   store.JobSucceeded,
+			wantAttempts: 2,
+			wantError:    context.DeadlineExceeded.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobStore := &memoryJobStore{}
+			p := newTestPool(jobStore)
+
+			var mu sync.Mutex
+			var runs []time.Time
+			p.Register("task", func(ctx context.Context, job *store.Job) error {
+				mu.Lock()
+				runs = append(runs, time.Now())
+				mu.Unlock()
+				return tt.handler(job.Attempts, ctx)
+			}, TypeConfig{Timeout: tt.timeout})
+
+			job := enqueue(t, NewQueue(jobStore), &store.Job{Type: "task", MaxAttempts: tt.maxAttempts})
+			p.Start()
+			eventually(t, "the job to finish", func() bool {
+				status := jobStore.get(job.ID).Status
+				return status == store.JobSucceeded || status == store.JobFailed
+			})
+			shutdown(t, p)
+
+			got := jobStore.get(job.ID)
+			if got.Status != tt.wantStatus || got.Attempts != tt.wantAttempts {
+				t.Fatalf("status, attempts = %s, %d, want %s, %d", got.Status, got.Attempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if len(runs) != tt.wantAttempts {
+				t.Errorf("handler ran %d times, want %d", len(runs), tt.wantAttempts)
+			}
+			gotError := ""
+			if got.LastError != nil {
+				gotError = *got.LastError
+			}
+			if gotError != tt.wantError {
+				t.Errorf("last error = %q, want %q", gotError, tt.wantError)
+			}
+
+			// retries wait BaseDelay, doubled per failed attempt
+			for i := 1; i < len(runs); i++ {
+				want := p.BaseDelay << (i - 1)
+				if gap := runs[i].Sub(runs[i-1]); gap < want {
+					t.Errorf("retry %d came after %v, want at least %v", i, gap, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPoolReclaimsExpiredLeases(t *testing.T) {
+	jobStore := &memoryJobStore{}
+	q := NewQueue(jobStore)
+	orphan := enqueue(t, q, &store.Job{Type: "task", MaxAttempts: 3})
+	last := enqueue(t, q, &store.Job{Type: "task", MaxAttempts: 1})
+
+	// a worker that claims both jobs and dies without settling them
+	claimed, _ := jobStore.ClaimJobs("task", 10, time.Minute, "dead-worker")
+	if len(claimed) != 2 {
+		t.Fatalf("claimed %d jobs, want 2", len(claimed))
+	}
+
+	p := newTestPool(jobStore)
+	var ran atomic.Int32
+	p.Register("task", func(ctx context.Context, job *store.Job) error {
+		ran.Add(1)
+		return nil
+	}, TypeConfig{})
+	p.Start()
+	defer shutdown(t, p)
+
+	// nothing is picked up while the lease holds
+	time.Sleep(30 * time.Millisecond)
+	if ran.Load() != 0 {
+		t.Fatal("a leased job was run again before its lease expired")
+	}
+
+	jobStore.mu.Lock()
+	jobStore.skew = 2 * time.Minute
+	jobStore.mu.Unlock()
+
+	eventually(t, "the orphaned job to finish", func() bool { return jobStore.get(orphan.ID).Status == store.JobSucceeded })
+	if got := jobStore.get(orphan.ID).Attempts; got != 2 {
+		t.Errorf("orphaned job attempts = %d, want 2", got)
+	}
+
+	got := jobStore.get(last.ID)
+	if got.Status != store.JobFailed || got.LastError == nil || !strings.Contains(*got.LastError, "lease expired") {
+		t.Errorf("job on its last attempt = %s (%v), want failed for the expired lease", got.Status, got.LastError)
+	}
+	if n := ran.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+
+	// the dead worker's late outcome no longer applies
+	jobStore.FailJob(orphan.ID, 1, "too late")
+	if got := jobStore.get(orphan.ID).Status; got != store.JobSucceeded {
+		t.Errorf("stale attempt changed the job to %s", got)
+	}
+}
+
+func TestQueueUniqueKey(t *testing.T) {
+	jobStore := &memoryJobStore{}
+	q := NewQueue(jobStore)
+	key := "weekly-summary:42"
+
+	first, err := q.Add(&store.Job{Type: "task", UniqueKey: &key})
+	if err != nil || !first {
+		t.Fatalf("first Add = %v, %v", first, err)
+	}
+	again, err := q.Add(&store.Job{Type: "task", UniqueKey: &key})
+	if err != nil || again {
+		t.Fatalf("Add while the first job is queued = %v, %v, want false", again, err)
+	}
+
+	p := newTestPool(jobStore)
+	p.Register("task", func(ctx context.Context, job *store.Job) error { return nil }, TypeConfig{})
+	p.Start()
+	eventually(t, "the job to finish", func() bool { return jobStore.count(store.JobSucceeded) == 1 })
+	shutdown(t, p)
+
+	after, err := q.Add(&store.Job{Type: "task", UniqueKey: &key})
+	if err != nil || !after {
+		t.Fatalf("Add after the first job finished = %v, %v, want true", after, err)
+	}
+}
+
+func TestPoolShutdownDrains(t *testing.T) {
+	jobStore := &memoryJobStore{}
+	q := NewQueue(jobStore)
+	p := newTestPool(jobStore)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	p.Register("task", func(ctx context.Context, job *store.Job) error {
+		close(started)
+		<-release
+		return nil
+	}, TypeConfig{})
+
+	running := enqueue(t, q, &store.Job{Type: "task"})
+	p.Start()
+	<-started
+
+	done := make(chan error)
+	go func() { done <- p.Shutdown(context.Background()) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned %v while a job was running", err)
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	// nothing new is claimed once shutdown has begun
+	late := enqueue(t, q, &store.Job{Type: "task"})
+	time.Sleep(30 * time.Millisecond)
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if got := jobStore.get(running.ID).Status; got != store.JobSucceeded {
+		t.Errorf("running job = %s, want succeeded", got)
+	}
+	if got := jobStore.get(late.ID); got.Status != store.JobQueued || got.Attempts != 0 {
+		t.Errorf("late job = %s after %d attempts, want still queued", got.Status, got.Attempts)
+	}
+}
+
+func TestPoolShutdownDeadlineCancelsRunningJobs(t *testing.T) {
+	jobStore := &memoryJobStore{}
+	p := newTestPool(jobStore)
+
+	started := make(chan struct{})
+	p.Register("task", func(ctx context.Context, job *store.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, TypeConfig{})
+
+	job := enqueue(t, NewQueue(jobStore), &store.Job{Type: "task", MaxAttempts: 3})
+	p.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want context.DeadlineExceeded", err)
+	}
+
+	// the cancelled run is retried later rather than lost
+	got := jobStore.get(job.ID)
+	if got.Status != store.JobQueued || got.Attempts != 1 {
+		t.Errorf("job = %s after %d attempts, want queued for a retry", got.Status, got.Attempts)
+	}
+}
+
+func TestPoolShutdownWithoutStart(t *testing.T) {
+	p := newTestPool(&memoryJobStore{})
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown = %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+type Op string
+
+const (
+	OpAdded   Op = "added"
+	OpRemoved Op = "removed"
+	OpChanged Op = "changed"
+)
+
+type Change struct {
+	Path string `json:"path"`
+	Op   Op     `json:"op"`
+	From any    `json:"from"`
+	To   any    `json:"to"`
+}
+
+// Diff compares two JSON documents leaf by leaf and returns the changes
+// sorted by path. Paths use dots for object keys and [i] for array items,
+// e.g. "entries[2].reps". Object keys listed in ignore are skipped at any
+// depth, which is handy for ids that change on every write.
+func Diff(a, b []byte, ignore ...string) ([]Change, error) {
+	var left, right any
+	err := json.Unmarshal(a, &left)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &right)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]bool, len(ignore))
+	for _, key := range ignore {
+		skip[key] = true
+	}
+
+	from := map[string]any{}
+	to := map[string]any{}
+	flatten("", left, from, skip)
+	flatten("", right, to, skip)
+
+	changes := []Change{}
+	for path, value := range from {
+		other, ok := to[path]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Path: path, Op: OpRemoved, From: value})
+		case !reflect.DeepEqual(value, other):
+			changes = append(changes, Change{Path: path, Op: OpChanged, From: value, To: other})
+		}
+	}
+	for path, value := range to {
+		if _, ok := from[path]; !ok {
+			changes = append(changes, Change{Path: path, Op: OpAdded, To: value})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+func flatten(prefix string, value any, out map[string]any, skip map[string]bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 && prefix != "" {
+			out[prefix] = v
+			return
+		}
+		for key, child := range v {
+			if skip[key] {
+				continue
+			}
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			flatten(path, child, out, skip)
+		}
+	case []any:
+		if len(v) == 0 && prefix != "" {
+			out[prefix] = v
+			return
+		}
+		for i, child := range v {
+			flatten(prefix+"["+strconv.Itoa(i)+"]", child, out, skip)
+		}
+	default:
+		out[prefix] = v
+	}
+}
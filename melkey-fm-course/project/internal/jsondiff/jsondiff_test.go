@@ -0,0 +1,103 @@
+package jsondiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   string
+		ignore []string
+		want   []Change
+	}{
+		{
+			name: "identical",
+			a:    `{"title":"Leg day","entries":[{"reps":5}]}`,
+			b:    `{"entries":[{"reps":5}],"title":"Leg day"}`,
+			want: []Change{},
+		},
+		{
+			name: "changed leaves",
+			a:    `{"title":"Leg day","duration_seconds":3600,"tags":["a"]}`,
+			b:    `{"title":"Legs","duration_seconds":3600,"tags":["b"]}`,
+			want: []Change{
+				{Path: "tags[0]", Op: OpChanged, From: "a", To: "b"},
+				{Path: "title", Op: OpChanged, From: "Leg day", To: "Legs"},
+			},
+		},
+		{
+			name: "added and removed keys",
+			a:    `{"title":"Leg day","description":"heavy"}`,
+			b:    `{"title":"Leg day","calories_burned":450}`,
+			want: []Change{
+				{Path: "calories_burned", Op: OpAdded, To: 450.0},
+				{Path: "description", Op: OpRemoved, From: "heavy"},
+			},
+		},
+		{
+			name: "nested arrays",
+			a:    `{"entries":[{"exercise_name":"Squat","reps":5},{"exercise_name":"Bench","reps":8}]}`,
+			b:    `{"entries":[{"exercise_name":"Squat","reps":6}]}`,
+			want: []Change{
+				{Path: "entries[0].reps", Op: OpChanged, From: 5.0, To: 6.0},
+				{Path: "entries[1].exercise_name", Op: OpRemoved, From: "Bench"},
+				{Path: "entries[1].reps", Op: OpRemoved, From: 8.0},
+			},
+		},
+		{
+			name: "null and type changes",
+			a:    `{"reps":null,"weight":100}`,
+			b:    `{"reps":5,"weight":"100"}`,
+			want: []Change{
+				{Path: "reps", Op: OpChanged, From: nil, To: 5.0},
+				{Path: "weight", Op: OpChanged, From: 100.0, To: "100"},
+			},
+		},
+		{
+			name: "empty containers are leaves",
+			a:    `{"tags":[],"route":{}}`,
+			b:    `{"tags":["a"]}`,
+			want: []Change{
+				{Path: "route", Op: OpRemoved, From: map[string]any{}},
+				{Path: "tags", Op: OpRemoved, From: []any{}},
+				{Path: "tags[0]", Op: OpAdded, To: "a"},
+			},
+		},
+		{
+			name:   "ignored keys at any depth",
+			a:      `{"id":1,"updated_at":"x","entries":[{"id":10,"reps":5}]}`,
+			b:      `{"id":2,"updated_at":"y","entries":[{"id":20,"reps":5}]}`,
+			ignore: []string{"id", "updated_at"},
+			want:   []Change{},
+		},
+		{
+			name: "top level scalars",
+			a:    `1`,
+			b:    `2`,
+			want: []Change{{Path: "", Op: OpChanged, From: 1.0, To: 2.0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diff([]byte(tt.a), []byte(tt.b), tt.ignore...)
+			if err != nil {
+				t.Fatalf("Diff: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffInvalidJSON(t *testing.T) {
+	if _, err := Diff([]byte(`{`), []byte(`{}`)); err == nil {
+		t.Error("invalid left document was accepted")
+	}
+	if _, err := Diff([]byte(`{}`), []byte(`nope`)); err == nil {
+		t.Error("invalid right document was accepted")
+	}
+}
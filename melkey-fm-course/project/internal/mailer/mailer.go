@@ -0,0 +1,169 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net/mail"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Each email is a pair of templates: <name>.txt defines "subject" and "body"
+// as plain text, and <name>.html is the HTML alternative.
+//
+//go:embed templates
+var templateFS embed.FS
+
+const (
+	TemplatePasswordReset = "password_reset"
+	TemplateVerifyEmail   = "verify_email"
+	TemplateWeeklySummary = "weekly_summary"
+)
+
+type PasswordResetData struct {
+	Username  string
+	URL       string
+	ExpiresIn string
+}
+
+type VerifyEmailData struct {
+	Username string
+	URL      string
+}
+
+type WeeklySummaryData struct {
+	Username        string
+	From            time.Time
+	To              time.Time
+	Workouts        int
+	DurationMinutes int
+	CaloriesBurned  int
+	// Streak counts the weeks in a row with at least one workout.
+	Streak int
+}
+
+// Message is a rendered email ready for a Transport.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Transport delivers a message. Errors are treated as temporary and retried
+// by the Sender unless they are marked Permanent.
+type Transport interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Permanent marks err as a failure that retrying cannot fix, such as a
+// malformed message or a recipient the server refuses, so the Sender gives up
+// on the message straight away.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err, or any error it wraps, was marked
+// Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+type permanentError struct {
+	error
+}
+
+func (e permanentError) Unwrap() error {
+	return e.error
+}
+
+// Mailer renders emails from the embedded templates and hands them to a
+// Sender, so callers never wait on delivery.
+type Mailer struct {
+	from   string
+	sender *Sender
+	text   map[string]*texttemplate.Template
+	html   map[string]*htmltemplate.Template
+}
+
+// New parses every embedded template up front, so a broken template stops
+// the application from starting rather than failing the first send.
+func New(from string, sender *Sender) (*Mailer, error) {
+	m := &Mailer{
+		from:   from,
+		sender: sender,
+		text:   map[string]*texttemplate.Template{},
+		html:   map[string]*htmltemplate.Template{},
+	}
+
+	for _, name := range []string{TemplatePasswordReset, TemplateVerifyEmail, TemplateWeeklySummary} {
+		text, err := texttemplate.ParseFS(templateFS, "templates/"+name+".txt")
+		if err != nil {
+			return nil, fmt.Errorf("mailer: %w", err)
+		}
+		html, err := htmltemplate.ParseFS(templateFS, "templates/"+name+".html")
+		if err != nil {
+			return nil, fmt.Errorf("mailer: %w", err)
+		}
+		m.text[name] = text
+		m.html[name] = html
+	}
+
+	return m, nil
+}
+
+// Send renders the named template with data and queues the email to to.
+// Rendering errors and a full queue are returned straight away; delivery
+// failures are only logged by the Sender.
+func (m *Mailer) Send(to, name string, data any) error {
+	_, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("mailer: to: %w", err)
+	}
+
+	msg, err := m.Render(to, name, data)
+	if err != nil {
+		return err
+	}
+	return m.sender.Enqueue(msg)
+}
+
+// Render builds the message without sending it.
+func (m *Mailer) Render(to, name string, data any) (*Message, error) {
+	text, ok := m.text[name]
+	if !ok {
+		return nil, fmt.Errorf("mailer: unknown template %q", name)
+	}
+
+	var subject, body, html bytes.Buffer
+	err := text.ExecuteTemplate(&subject, "subject", data)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: %s subject: %w", name, err)
+	}
+	err = text.ExecuteTemplate(&body, "body", data)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: %s body: %w", name, err)
+	}
+	err = m.html[name].Execute(&html, data)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: %s html: %w", name, err)
+	}
+
+	return &Message{
+		From:    m.from,
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimLeft(body.String(), "\n"),
+		HTML:    html.String(),
+	}, nil
+}
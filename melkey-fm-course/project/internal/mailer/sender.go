@@ -0,0 +1,120 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned by Enqueue when the send queue has no room left.
+var ErrQueueFull = errors.New("mailer: send queue is full")
+
+// Sender delivers queued messages on a few background workers, retrying
+// failed sends with backoff unless the error is permanent. The queue is
+// bounded so a broken transport cannot grow memory without limit; Enqueue
+// never blocks.
+type Sender struct {
+	transport Transport
+	logger    *log.Logger
+	queue     chan *Message
+	// MaxAttempts and BaseDelay control retries: the wait doubles after
+	// every failed attempt.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	// SendTimeout bounds a single attempt.
+	SendTimeout time.Duration
+
+	workers   int
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func NewSender(transport Transport, logger *log.Logger, queueSize, workers int) *Sender {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Sender{
+		transport:   transport,
+		logger:      logger,
+		queue:       make(chan *Message, queueSize),
+		MaxAttempts: 5,
+		BaseDelay:   2 * time.Second,
+		SendTimeout: 30 * time.Second,
+		workers:     workers,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// Start launches the workers.
+func (s *Sender) Start() {
+	for range max(s.workers, 1) {
+		s.wg.Add(1)
+		go s.worker()
+	}
+}
+
+// Enqueue queues msg for delivery.
+func (s *Sender) Enqueue(msg *Message) error {
+	select {
+	case s.queue <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Shutdown stops accepting messages and waits for the queue to drain. When
+// ctx ends first, pending retries are abandoned and ctx's error is returned.
+// Enqueue must not be called once Shutdown has started.
+func (s *Sender) Shutdown(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.queue) })
+
+	drained := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-drained
+		return ctx.Err()
+	}
+}
+
+func (s *Sender) worker() {
+	defer s.wg.Done()
+	for msg := range s.queue {
+		err := s.send(msg)
+		if err != nil {
+			s.logger.Printf("ERROR: sending %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}
+}
+
+func (s *Sender) send(msg *Message) error {
+	delay := s.BaseDelay
+	var err error
+	for attempt := 1; attempt <= s.MaxAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(s.ctx, s.SendTimeout)
+		err = s.transport.Send(ctx, msg)
+		cancel()
+		if err == nil || IsPermanent(err) || attempt == s.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-s.ctx.Done():
+			return errors.Join(err, s.ctx.Err())
+		}
+		delay *= 2
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyTransport fails with the errors it is given, in order, and then hands
+// messages to a MemoryTransport.
+type flakyTransport struct {
+	MemoryTransport
+
+	mu       sync.Mutex
+	errs     []error
+	attempts int
+}
+
+func (t *flakyTransport) Send(ctx context.Context, msg *Message) error {
+	t.mu.Lock()
+	t.attempts++
+	if len(t.errs) > 0 {
+		err := t.errs[0]
+		t.errs = t.errs[1:]
+		t.mu.Unlock()
+		return err
+	}
+	t.mu.Unlock()
+	return t.MemoryTransport.Send(ctx, msg)
+}
+
+func TestIsPermanent(t *testing.T) {
+	base := errors.New("550 mailbox unavailable")
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"plain", base, false},
+		{"permanent", Permanent(base), true},
+		{"wrapped permanent", fmt.Errorf("sending: %w", Permanent(base)), true},
+		{"header injection", errHeaderInjection, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPermanent(tt.err); got != tt.want {
+				t.Errorf("IsPermanent(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+
+	if Permanent(nil) != nil {
+		t.Error("Permanent(nil) is not nil")
+	}
+	if !errors.Is(Permanent(base), base) {
+		t.Error("Permanent hides the error it wraps")
+	}
+}
+
+func TestSenderRetries(t *testing.T) {
+	temporary := errors.New("connection reset")
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantAttempts int
+		wantSent     bool
+	}{
+		{name: "first attempt succeeds", wantAttempts: 1, wantSent: true},
+		{name: "temporary failures are retried", errs: []error{temporary, temporary}, wantAttempts: 3, wantSent: true},
+		{name: "gives up after MaxAttempts", errs: []error{temporary, temporary, temporary, temporary}, wantAttempts: 3},
+		{name: "permanent failures are not retried", errs: []error{Permanent(temporary)}, wantAttempts: 1},
+		{name: "permanent failure after a retry", errs: []error{temporary, Permanent(temporary)}, wantAttempts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &flakyTransport{errs: tt.errs}
+			sender := NewSender(transport, log.New(io.Discard, "", 0), 10, 1)
+			sender.MaxAttempts = 3
+			sender.BaseDelay = time.Millisecond
+			sender.Start()
+
+			msg := &Message{From: "app@example.com", To: "user@example.com", Subject: "Hi", Text: "Hello"}
+			if err := sender.Enqueue(msg); err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+			if err := sender.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Shutdown: %v", err)
+			}
+
+			if transport.attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", transport.attempts, tt.wantAttempts)
+			}
+			sent := transport.Messages()
+			if tt.wantSent && (len(sent) != 1 || sent[0] != *msg) {
+				t.Errorf("sent = %+v, want the message", sent)
+			}
+			if !tt.wantSent && len(sent) != 0 {
+				t.Errorf("sent = %+v, want nothing", sent)
+			}
+		})
+	}
+}
+
+func TestSenderQueueIsBounded(t *testing.T) {
+	sender := NewSender(&MemoryTransport{}, log.New(io.Discard, "", 0), 1, 1)
+
+	msg := &Message{To: "user@example.com"}
+	if err := sender.Enqueue(msg); err != nil {
+		t.Fatalf("first Enqueue: %v", err)
+	}
+	if err := sender.Enqueue(msg); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("second Enqueue = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestSenderShutdownAbandonsRetries(t *testing.T) {
+	transport := &flakyTransport{errs: []error{errors.New("try again")}}
+	sender := NewSender(transport, log.New(io.Discard, "", 0), 10, 1)
+	sender.BaseDelay = time.Hour
+	sender.Start()
+
+	if err := sender.Enqueue(&Message{To: "user@example.com"}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sender.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want context.DeadlineExceeded", err)
+	}
+	if got := len(transport.Messages()); got != 0 {
+		t.Errorf("%d messages sent after the retry was abandoned", got)
+	}
+}
@@ -0,0 +1,106 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+)
+
+// SMTPTransport sends through an SMTP server. The connection is upgraded
+// with STARTTLS when the server offers it, and credentials are only sent
+// over TLS or to localhost.
+type SMTPTransport struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// Send holds one connection for the whole exchange and closes it once ctx is
+// done, which unblocks whatever command is in flight. Replies in the 5xx
+// range, such as an unknown recipient, are permanent.
+func (t *SMTPTransport) Send(ctx context.Context, msg *Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return Permanent(err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(t.Host, strconv.Itoa(t.Port)))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	err = t.send(conn, from.Address, to.Address, body)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return Permanent(err)
+	}
+	return err
+}
+
+// send follows smtp.SendMail over an established connection.
+func (t *SMTPTransport) send(conn net.Conn, from, to string, body []byte) error {
+	c, err := smtp.NewClient(conn, t.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: t.Host})
+		if err != nil {
+			return err
+		}
+	}
+	if t.Username != "" {
+		err = c.Auth(smtp.PlainAuth("", t.Username, t.Password, t.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(from)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(to)
+	if err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"br", "br"},
+		{"gzip, br", "br"},
+		{"GZIP", "gzip"},
+		{"gzip;q=1.0, br;q=0.5", "gzip"},
+		{"gzip;q=0.5, br;q=0.5", "br"},
+		{"br;q=0, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.5, gzip", "gzip"},
+		{"*, br;q=0", "gzip"},
+		{"deflate, gzip ; q=0.8", "gzip"},
+		{"gzip;q=abc", "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := negotiateEncoding(tt.header); got != tt.want {
+				t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"title":"Leg day"}`, 100)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		method         string
+		handler        http.HandlerFunc
+		wantEncoding   string
+		wantBody       string
+		wantStatus     int
+	}{
+		{
+			name:           "large JSON is gzipped",
+			acceptEncoding: "gzip",
+			handler:        writeBody("application/json", large),
+			wantEncoding:   "gzip",
+			wantBody:       large,
+		},
+		{
+			name:           "large JSON prefers brotli",
+			acceptEncoding: "gzip, br",
+			handler:        writeBody("application/json", large),
+			wantEncoding:   "br",
+			wantBody:       large,
+		},
+		{
+			name:           "small bodies are sent as is",
+			acceptEncoding: "gzip",
+			handler:        writeBody("application/json", `{"ok":true}`),
+			wantBody:       `{"ok":true}`,
+		},
+		{
+			name:           "images are not compressed",
+			acceptEncoding: "gzip",
+			handler:        writeBody("image/png", large),
+			wantBody:       large,
+		},
+		{
+			name:           "clients that accept neither get plain bodies",
+			acceptEncoding: "",
+			handler:        writeBody("application/json", large),
+			wantBody:       large,
+		},
+		{
+			name:           "HEAD is passed through",
+			acceptEncoding: "gzip",
+			method:         http.MethodHead,
+			handler:        writeBody("application/json", large),
+			wantBody:       large,
+		},
+		{
+			name:           "existing Content-Encoding is left alone",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "identity")
+				writeBody("application/json", large)(w, r)
+			},
+			wantEncoding: "identity",
+			wantBody:     large,
+		},
+		{
+			name:           "not modified has no body to compress",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotModified)
+			},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:           "status is kept",
+			acceptEncoding: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, large)
+			},
+			wantEncoding: "gzip",
+			wantBody:     large,
+			wantStatus:   http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+
+			Compress(1024)(tt.handler).ServeHTTP(rec, req)
+
+			wantStatus := tt.wantStatus
+			if wantStatus == 0 {
+				wantStatus = http.StatusOK
+			}
+			if rec.Code != wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, wantStatus)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+			if got := decode(t, tt.wantEncoding, rec.Body.Bytes()); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCompressFlushCommitsEarly(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "first")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, " second")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	Compress(1024)(http.HandlerFunc(handler)).ServeHTTP(rec, req)
+
+	if !rec.Flushed {
+		t.Fatal("Flush did not reach the underlying writer")
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip for a flushed response", got)
+	}
+	if got := decode(t, "gzip", rec.Body.Bytes()); got != "first second" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestCompressEventStreamIsNotCompressed(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	Compress(1024)(http.HandlerFunc(handler)).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Fatalf("Content-Encoding = %q, want none", got)
+	}
+	if got := rec.Body.String(); got != "data: 1\n\n" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func writeBody(contentType, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		// several writes, so the size threshold is crossed part way through
+		for chunk := range chunks(body, 100) {
+			io.WriteString(w, chunk)
+		}
+	}
+}
+
+func chunks(s string, size int) func(func(string) bool) {
+	return func(yield func(string) bool) {
+		for len(s) > 0 {
+			n := min(size, len(s))
+			if !yield(s[:n]) {
+				return
+			}
+			s = s[n:]
+		}
+	}
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+		r = gr
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return string(body)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", encoding, err)
+	}
+	return string(out)
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/utils"
+)
+
+// Version is a major version of the API. A new one is only cut when a
+// representation changes in a way old clients would misread.
+type Version int
+
+const (
+	V1 Version = 1
+	// V2 reports durations in seconds instead of minutes.
+	V2 Version = 2
+
+	LatestVersion = V2
+)
+
+const versionContextKey = contextKey("version")
+
+// mediaTypeVendor is the vendor tree clients name a version in, as in
+// application/vnd.workouts.v2+json.
+const mediaTypeVendor = "application/vnd.workouts."
+
+func SetVersion(r *http.Request, v Version) *http.Request {
+	ctx := context.WithValue(r.Context(), versionContextKey, v)
+	return r.WithContext(ctx)
+}
+
+// GetVersion returns the version the request was negotiated to, V1 when it
+// never went through PathVersion or NegotiateVersion.
+func GetVersion(r *http.Request) Version {
+	v, ok := r.Context().Value(versionContextKey).(Version)
+	if !ok {
+		return V1
+	}
+	return v
+}
+
+// PathVersion pins every request under a /v<n> prefix to that version. An
+// Accept header asking for another version is refused with 406 rather than
+// silently ignored.
+func PathVersion(v Version) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			accepted, ok, err := acceptedVersion(r.Header.Get("Accept"))
+			if err != nil {
+				utils.WriteJSON(w, http.StatusNotAcceptable, utils.Envelope{"error": err.Error()})
+				return
+			}
+			if ok && accepted != v {
+				utils.WriteJSON(w, http.StatusNotAcceptable, utils.Envelope{
+					"error": fmt.Sprintf("this path serves version %d; use /v%d for version %d", v, accepted, accepted),
+				})
+				return
+			}
+
+			w.Header().Set("API-Version", strconv.Itoa(int(v)))
+			next.ServeHTTP(w, SetVersion(r, v))
+		})
+	}
+}
+
+// NegotiateVersion picks the version for paths without a /v<n> prefix from
+// the Accept header, either as application/vnd.workouts.v2+json or as a
+// version parameter on application/json. Without either it falls back to V1,
+// which is what those paths served before versions existed.
+func NegotiateVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		v, ok, err := acceptedVersion(r.Header.Get("Accept"))
+		if err != nil {
+			utils.WriteJSON(w, http.StatusNotAcceptable, utils.Envelope{"error": err.Error()})
+			return
+		}
+		if !ok {
+			v = V1
+		}
+
+		w.Header().Set("API-Version", strconv.Itoa(int(v)))
+		next.ServeHTTP(w, SetVersion(r, v))
+	})
+}
+
+// acceptedVersion reads the version named in an Accept header. ok is false
+// when the header names none; a version that does not exist is an error.
+func acceptedVersion(header string) (v Version, ok bool, err error) {
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var value string
+		switch {
+		case strings.HasPrefix(mediaType, mediaTypeVendor) && strings.HasSuffix(mediaType, "+json"):
+			value = strings.TrimSuffix(strings.TrimPrefix(mediaType, mediaTypeVendor), "+json")
+			value = strings.TrimPrefix(value, "v")
+		case mediaType == "application/json" && params["version"] != "":
+			value = params["version"]
+		default:
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < int(V1) || n > int(LatestVersion) {
+			return 0, false, fmt.Errorf("unsupported API version %q, the latest is %d", value, LatestVersion)
+		}
+		return Version(n), true, nil
+	}
+	return 0, false, nil
+}
+
+// Versions serves each request with the handler for its version: handlers
+// are given in order from V1 up, and a version without its own handler gets
+// the newest one before it. Only representations that changed need a new
+// handler.
+func Versions(handlers ...http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		i := min(int(GetVersion(r)), len(handlers)) - 1
+		handlers[i](w, r)
+	}
+}
+
+// Deprecated marks every response as coming from a deprecated path, with the
+// Deprecation (RFC 9745) and Sunset (RFC 8594) headers and a Link to the same
+// resource under the /v<n> prefix of the request's version. It goes after
+// NegotiateVersion.
+func Deprecated(deprecatedAt, sunset time.Time) func(http.Handler) http.Handler {
+	deprecation := "@" + strconv.FormatInt(deprecatedAt.Unix(), 10)
+	sunsetAt := sunset.UTC().Format(http.TimeFormat)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Deprecation", deprecation)
+			h.Set("Sunset", sunsetAt)
+			h.Add("Link", fmt.Sprintf(`</v%d%s>; rel="successor-version"`, GetVersion(r), r.URL.Path))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/backoff"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+)
+
+// Subscriber handles one event. Events are delivered at least once, so a
+// subscriber must cope with seeing the same EventID again, and returning an
+// error has the event redelivered to every subscriber of its topic later.
+type Subscriber func(ctx context.Context, event store.OutboxEvent) error
+
+// Relay moves events from the outbox table to the in-process subscribers of
+// their topic.
+type Relay struct {
+	Store  store.OutboxStore
+	Logger *log.Logger
+	// BatchSize caps how many events one pass locks.
+	BatchSize int
+	// MaxDelay caps the backoff between retries of a failing event.
+	MaxDelay time.Duration
+
+	mu          sync.RWMutex
+	subscribers map[string][]Subscriber
+}
+
+func NewRelay(outboxStore store.OutboxStore, logger *log.Logger) *Relay {
+	return &Relay{
+		Store:       outboxStore,
+		Logger:      logger,
+		BatchSize:   100,
+		MaxDelay:    10 * time.Minute,
+		subscribers: map[string][]Subscriber{},
+	}
+}
+
+// Subscribe registers fn for every event published on topic.
+func (r *Relay) Subscribe(topic string, fn Subscriber) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers[topic] = append(r.subscribers[topic], fn)
+}
+
+// RelayPending dispatches the events that are due and returns how many were
+// handled, successfully or not.
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	return r.Store.ProcessOutbox(r.BatchSize, func(event store.OutboxEvent) error {
+		return r.dispatch(ctx, event)
+	}, r.backoff)
+}
+
+func (r *Relay) dispatch(ctx context.Context, event store.OutboxEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	subscribers := r.subscribers[event.Topic]
+	r.mu.RUnlock()
+
+	for i, fn := range subscribers {
+		err := fn(ctx, event)
+		if err != nil {
+			r.Logger.Printf("ERROR: outbox event %d (%s) subscriber %d: %v", event.ID, event.Topic, i, err)
+			return fmt.Errorf("subscriber %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// backoff doubles from one second per failed attempt up to MaxDelay.
+func (r *Relay) backoff(attempts int) time.Duration {
+	return backoff.Exponential(time.Second, r.MaxDelay, attempts)
+}
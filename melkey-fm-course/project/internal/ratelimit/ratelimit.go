@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket per key: every key may spend Limit requests at
+// once, and its bucket refills at Limit per Window. Unlike the ticker in
+// hands-on/rate-limiting it needs no goroutine per key; a bucket is just a
+// token count topped up from the time elapsed since it was last used.
+//
+// Buckets are kept in least recently used order. Any bucket idle for a full
+// Window has refilled completely, so it is dropped without changing any
+// outcome. On top of that at most maxKeys buckets are kept, evicting the least
+// recently used one first, so memory stays bounded even under a flood of
+// distinct keys.
+type Limiter struct {
+	Limit  int
+	Window time.Duration
+
+	maxKeys int
+	rate    float64 // tokens per second
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List // front is the most recently used
+	now     func() time.Time
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// Result describes one decision along with the state of the key's bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until a request would be allowed again. It is
+	// zero when Allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+func New(limit int, window time.Duration, maxKeys int) *Limiter {
+	return &Limiter{
+		Limit:   limit,
+		Window:  window,
+		maxKeys: maxKeys,
+		rate:    float64(limit) / window.Seconds(),
+		buckets: map[string]*list.Element{},
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// Allow spends a token from key's bucket if it has one.
+func (l *Limiter) Allow(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.evictIdle(now)
+
+	var b *bucket
+	if elem, ok := l.buckets[key]; ok {
+		b = elem.Value.(*bucket)
+		elapsed := now.Sub(b.last).Seconds()
+		b.tokens = math.Min(float64(l.Limit), b.tokens+elapsed*l.rate)
+		b.last = now
+		l.lru.MoveToFront(elem)
+	} else {
+		if l.maxKeys > 0 && l.lru.Len() >= l.maxKeys {
+			l.remove(l.lru.Back())
+		}
+		b = &bucket{key: key, tokens: float64(l.Limit), last: now}
+		l.buckets[key] = l.lru.PushFront(b)
+	}
+
+	res := Result{Limit: l.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.duration(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = l.duration(float64(l.Limit) - b.tokens)
+	return res
+}
+
+// Len returns the number of buckets currently kept.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lru.Len()
+}
+
+func (l *Limiter) evictIdle(now time.Time) {
+	for elem := l.lru.Back(); elem != nil; elem = l.lru.Back() {
+		if now.Sub(elem.Value.(*bucket).last) < l.Window {
+			return
+		}
+		l.remove(elem)
+	}
+}
+
+func (l *Limiter) remove(elem *list.Element) {
+	delete(l.buckets, elem.Value.(*bucket).key)
+	l.lru.Remove(elem)
+}
+
+// duration is how long refilling the given number of tokens takes.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
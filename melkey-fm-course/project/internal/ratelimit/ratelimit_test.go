@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock stands in for time.Now so refills can be stepped through exactly.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestLimiter(limit int, window time.Duration, maxKeys int) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l := New(limit, window, maxKeys)
+	l.now = clock.now
+	return l, clock
+}
+
+func TestLimiterAllow(t *testing.T) {
+	type step struct {
+		advance    time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}
+
+	tests := []struct {
+		name   string
+		limit  int
+		window time.Duration
+		steps  []step
+	}{
+		{
+			name:   "burst up to the limit then refuse",
+			limit:  3,
+			window: 3 * time.Second,
+			steps: []step{
+				{allowed: true, remaining: 2, reset: time.Second},
+				{allowed: true, remaining: 1, reset: 2 * time.Second},
+				{allowed: true, remaining: 0, reset: 3 * time.Second},
+				{allowed: false, remaining: 0, retryAfter: time.Second, reset: 3 * time.Second},
+			},
+		},
+		{
+			name:   "tokens refill with elapsed time",
+			limit:  2,
+			window: 2 * time.Second,
+			steps: []step{
+				{allowed: true, remaining: 1, reset: time.Second},
+				{allowed: true, remaining: 0, reset: 2 * time.Second},
+				{advance: 500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond, reset: 1500 * time.Millisecond},
+				{advance: 500 * time.Millisecond, allowed: true, remaining: 0, reset: 2 * time.Second},
+			},
+		},
+		{
+			name:   "refill is capped at the limit",
+			limit:  2,
+			window: time.Minute,
+			steps: []step{
+				{allowed: true, remaining: 1, reset: 30 * time.Second},
+				{advance: 59 * time.Second, allowed: true, remaining: 1, reset: 30 * time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, clock := newTestLimiter(tt.limit, tt.window, 0)
+			for i, s := range tt.steps {
+				clock.advance(s.advance)
+				res := l.Allow("k")
+				if res.Allowed != s.allowed || res.Remaining != s.remaining || res.RetryAfter != s.retryAfter || res.Reset != s.reset {
+					t.Fatalf("step %d: got %+v, want allowed=%v remaining=%d retryAfter=%v reset=%v",
+						i, res, s.allowed, s.remaining, s.retryAfter, s.reset)
+				}
+				if res.Limit != tt.limit {
+					t.Fatalf("step %d: Limit = %d, want %d", i, res.Limit, tt.limit)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterKeysAreIndependent(t *testing.T) {
+	l, _ := newTestLimiter(1, time.Minute, 0)
+
+	if !l.Allow("a").Allowed {
+		t.Fatal("first request for a was refused")
+	}
+	if l.Allow("a").Allowed {
+		t.Fatal("second request for a was allowed")
+	}
+	if !l.Allow("b").Allowed {
+		t.Fatal("b was refused because of a")
+	}
+}
+
+func TestLimiterEvictsIdleBuckets(t *testing.T) {
+	l, clock := newTestLimiter(5, time.Minute, 0)
+
+	l.Allow("a")
+	clock.advance(30 * time.Second)
+	l.Allow("b")
+	if got := l.Len(); got != 2 {
+		t.Fatalf("Len = %d, want 2", got)
+	}
+
+	// a has now been idle for a full window, b for only half of one
+	clock.advance(30 * time.Second)
+	l.Allow("c")
+	if got := l.Len(); got != 2 {
+		t.Fatalf("Len after a went idle = %d, want 2", got)
+	}
+}
+
+func TestLimiterMaxKeysEvictsLeastRecentlyUsed(t *testing.T) {
+	l, clock := newTestLimiter(1, time.Hour, 2)
+
+	l.Allow("a")
+	clock.advance(time.Second)
+	l.Allow("b")
+	clock.advance(time.Second)
+	// a is refused but becomes the most recently used
+	if l.Allow("a").Allowed {
+		t.Fatal("a was allowed twice")
+	}
+	clock.advance(time.Second)
+	l.Allow("c")
+
+	if got := l.Len(); got != 2 {
+		t.Fatalf("Len = %d, want 2", got)
+	}
+	// c evicted b rather than a, so a is still empty
+	if l.Allow("a").Allowed {
+		t.Fatal("a lost its bucket although c should have evicted b")
+	}
+	if !l.Allow("b").Allowed {
+		t.Fatal("b kept its bucket although it was least recently used")
+	}
+}
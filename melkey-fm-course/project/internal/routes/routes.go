@@ -1,14 +1,143 @@
 package routes
 
 import (
+	"net/http"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/api"
 	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/app"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/middleware"
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// compressMinSize is roughly where gzip and brotli start to pay for their
+// own headers and the CPU spent.
+const compressMinSize = 1024
+
+var (
+	legacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	legacySunset       = legacyDeprecatedAt.AddDate(0, 6, 0)
 )
 
 func SetUpRoutes(app *app.Application) *chi.Mux {
 	r := chi.NewRouter()
+	r.Use(chimiddleware.RequestID)
+	if app.Config.TrustProxy {
+		r.Use(chimiddleware.RealIP)
+	}
+	r.Use(middleware.Compress(compressMinSize))
+
 	r.Get("/health", app.HealthCheck)
-	r.Get("/workouts/{id}", app.WorkoutHandler.HandleGetWorkoutByID)
-	r.Post("/workouts", app.WorkoutHandler.HandleCreatetWorkout)
+
+	// links handed out to people and calendar apps stay where they are
+	// across versions
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RateLimit(app.RateLimits.Public, middleware.ByIP))
+
+		r.Get("/calendar/{token}.ics", app.CalendarHandler.HandleGetCalendarFeed)
+		r.Get("/s/{token}", app.ShareHandler.HandleGetSharedWorkout)
+	})
+
+	r.Route("/v1", func(r chi.Router) {
+		r.Use(middleware.PathVersion(middleware.V1))
+		apiRoutes(r, app)
+	})
+	r.Route("/v2", func(r chi.Router) {
+		r.Use(middleware.PathVersion(middleware.V2))
+		apiRoutes(r, app)
+	})
+
+	// the unversioned paths predate /v1 and stay as aliases until the sunset
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.NegotiateVersion)
+		r.Use(middleware.Deprecated(legacyDeprecatedAt, legacySunset))
+		apiRoutes(r, app)
+	})
 	return r
 }
+
+// apiRoutes registers the versioned API. Every version shares one route
+// table; middleware.Versions picks the handler for endpoints whose
+// representation changed.
+func apiRoutes(r chi.Router, app *app.Application) {
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RateLimit(app.RateLimits.Client, middleware.ByIP))
+		r.Use(app.Middleware.Authenticate)
+		r.Use(middleware.RateLimit(app.RateLimits.API, middleware.ByUser))
+
+		r.Get("/workouts/export.csv", app.Middleware.RequireUser(app.WorkoutHandler.HandleExportWorkoutsCSV))
+		r.Post("/workouts/import", app.Middleware.RequireUser(app.WorkoutHandler.HandleImportWorkouts))
+		r.Post("/workouts/tracks", app.Middleware.RequireUser(durationsV1(app.WorkoutHandler.HandleUploadTrack)))
+		r.Get("/workouts", app.Middleware.RequireUser(durationsV1(app.WorkoutHandler.HandleListWorkouts)))
+		r.Get("/workouts/{id}", durationsV1(app.WorkoutHandler.HandleGetWorkoutByID))
+		r.Get("/workouts/{id}/route", app.WorkoutHandler.HandleGetWorkoutRoute)
+		r.Post("/workouts", app.Middleware.RequireUser(durationsV1(app.WorkoutHandler.HandleCreatetWorkout)))
+		r.Put("/workouts/{id}", app.Middleware.RequireUser(durationsV1(app.WorkoutHandler.HandleUpdateWorkoutByID)))
+		r.Delete("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandleDeleteWorkoutByID))
+		r.Post("/workouts/{id}/restore", app.Middleware.RequireUser(durationsV1(app.TrashHandler.HandleRestoreWorkout)))
+		r.Get("/workouts/{id}/history", app.Middleware.RequireUser(app.WorkoutHandler.HandleGetWorkoutHistory))
+		r.Get("/workouts/{id}/history/diff", app.Middleware.RequireUser(middleware.Versions(app.WorkoutHandler.HandleDiffWorkoutRevisionsV1, app.WorkoutHandler.HandleDiffWorkoutRevisions)))
+		r.Get("/workouts/{id}/history/{rev}", app.Middleware.RequireUser(durationsV1(app.WorkoutHandler.HandleGetWorkoutRevision)))
+		r.Post("/workouts/{id}/revert/{rev}", app.Middleware.RequireUser(durationsV1(app.WorkoutHandler.HandleRevertWorkout)))
+		r.Get("/workouts/{id}/comments", app.CommentHandler.HandleListComments)
+		r.Post("/workouts/{id}/comments", app.Middleware.RequireUser(app.CommentHandler.HandleCreateComment))
+		r.Put("/workouts/{id}/reaction", app.Middleware.RequireUser(app.CommentHandler.HandleSetReaction))
+		r.Delete("/workouts/{id}/reaction", app.Middleware.RequireUser(app.CommentHandler.HandleDeleteReaction))
+		r.Patch("/comments/{id}", app.Middleware.RequireUser(app.CommentHandler.HandleUpdateComment))
+		r.Delete("/comments/{id}", app.Middleware.RequireUser(app.CommentHandler.HandleDeleteComment))
+		r.Post("/workouts/{id}/share", app.Middleware.RequireUser(app.ShareHandler.HandleCreateShareLink))
+		r.Get("/share-links", app.Middleware.RequireUser(app.ShareHandler.HandleListShareLinks))
+		r.Delete("/share-links/{id}", app.Middleware.RequireUser(app.ShareHandler.HandleRevokeShareLink))
+		r.Put("/workouts/{id}/tags", app.Middleware.RequireUser(durationsV1(app.TagHandler.HandleSetWorkoutTags)))
+		r.Get("/trash", app.Middleware.RequireUser(durationsV1(app.TrashHandler.HandleGetTrash)))
+
+		r.Get("/tags", app.Middleware.RequireUser(app.TagHandler.HandleListTags))
+		r.Post("/tags", app.Middleware.RequireUser(app.TagHandler.HandleCreateTag))
+		r.Patch("/tags/{id}", app.Middleware.RequireUser(app.TagHandler.HandleRenameTag))
+		r.Delete("/tags/{id}", app.Middleware.RequireUser(app.TagHandler.HandleDeleteTag))
+		r.Get("/goals", app.Middleware.RequireUser(durationsV1(app.GoalHandler.HandleListGoals)))
+		r.Post("/goals", app.Middleware.RequireUser(durationsV1(app.GoalHandler.HandleCreateGoal)))
+		r.Delete("/goals/{id}", app.Middleware.RequireUser(app.GoalHandler.HandleDeleteGoal))
+		r.Get("/measurements", app.Middleware.RequireUser(app.MeasurementHandler.HandleListMeasurements))
+		r.Post("/measurements", app.Middleware.RequireUser(app.MeasurementHandler.HandleCreateMeasurement))
+		r.Get("/measurements/strength", app.Middleware.RequireUser(app.MeasurementHandler.HandleGetRelativeStrength))
+		r.Get("/measurements/{id}", app.Middleware.RequireUser(app.MeasurementHandler.HandleGetMeasurement))
+		r.Put("/measurements/{id}", app.Middleware.RequireUser(app.MeasurementHandler.HandleUpdateMeasurement))
+		r.Delete("/measurements/{id}", app.Middleware.RequireUser(app.MeasurementHandler.HandleDeleteMeasurement))
+		r.Get("/search", app.Middleware.RequireUser(app.SearchHandler.HandleSearch))
+		r.Get("/stats", app.Middleware.RequireUser(durationsV1(app.StatsHandler.HandleGetStats)))
+		r.Get("/webhooks", app.Middleware.RequireUser(app.WebhookHandler.HandleListWebhooks))
+		r.Post("/webhooks", app.Middleware.RequireUser(app.WebhookHandler.HandleCreateWebhook))
+		r.Delete("/webhooks/{id}", app.Middleware.RequireUser(app.WebhookHandler.HandleDeleteWebhook))
+		r.Get("/webhooks/{id}/deliveries", app.Middleware.RequireUser(app.WebhookHandler.HandleListWebhookDeliveries))
+		r.Post("/webhook-deliveries/{id}/retry", app.Middleware.RequireUser(app.WebhookHandler.HandleRetryWebhookDelivery))
+
+		r.Patch("/users/me", app.Middleware.RequireUser(app.UserHandler.HandleUpdateMe))
+		r.Post("/users/{id}/follow", app.Middleware.RequireUser(app.FollowHandler.HandleFollowUser))
+		r.Delete("/users/{id}/follow", app.Middleware.RequireUser(app.FollowHandler.HandleUnfollowUser))
+		r.Get("/followers", app.Middleware.RequireUser(app.FollowHandler.HandleListFollowers))
+		r.Delete("/followers/{id}", app.Middleware.RequireUser(app.FollowHandler.HandleRemoveFollower))
+		r.Get("/following", app.Middleware.RequireUser(app.FollowHandler.HandleListFollowing))
+		r.Get("/follow-requests", app.Middleware.RequireUser(app.FollowHandler.HandleListFollowRequests))
+		r.Post("/follow-requests/{id}/accept", app.Middleware.RequireUser(app.FollowHandler.HandleAcceptFollowRequest))
+		r.Delete("/follow-requests/{id}", app.Middleware.RequireUser(app.FollowHandler.HandleRemoveFollower))
+		r.Get("/feed", app.Middleware.RequireUser(durationsV1(app.FollowHandler.HandleGetFeed)))
+
+		r.Post("/calendar/token", app.Middleware.RequireUser(app.CalendarHandler.HandleCreateCalendarToken))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RateLimit(app.RateLimits.Auth, middleware.ByIP))
+
+		r.Post("/users", app.UserHandler.HandleRegisterUser)
+		r.Post("/tokens/authentication", app.TokenHandler.HandleCreateToken)
+	})
+}
+
+// durationsV1 serves h unchanged to v2 and with durations in minutes to v1.
+// v1 responses are buffered in memory to be rewritten, so streaming handlers
+// such as the CSV export must not be wrapped.
+func durationsV1(h http.HandlerFunc) http.HandlerFunc {
+	return middleware.Versions(api.DurationMinutes(h), h)
+}
@@ -0,0 +1,252 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrInvalidParent = errors.New("parent_id must be a top level comment on the same workout")
+
+// ReactionKinds are the reactions a user can leave on a workout.
+var ReactionKinds = []string{"kudos", "🔥", "💪", "👏", "🎉", "❤️"}
+
+type Comment struct {
+	ID        int64     `json:"id"`
+	WorkoutID int64     `json:"workout_id"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	ParentID  *int64    `json:"parent_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Replies   []Comment `json:"replies,omitempty"`
+}
+
+// Engagement summarises the comments and reactions on a workout.
+type Engagement struct {
+	CommentCount int            `json:"comment_count"`
+	Reactions    map[string]int `json:"reactions"`
+	// ViewerReaction is the caller's own reaction, if any.
+	ViewerReaction string `json:"viewer_reaction,omitempty"`
+}
+
+type PostgresCommentStore struct {
+	db *sql.DB
+}
+
+func NewPostgresCommentStore(db *sql.DB) *PostgresCommentStore {
+	return &PostgresCommentStore{db: db}
+}
+
+type CommentStore interface {
+	CreateComment(comment *Comment) error
+	GetComment(id int64) (*Comment, error)
+	ListComments(workoutID int64) ([]Comment, error)
+	UpdateComment(comment *Comment) error
+	DeleteComment(id int64) error
+	SetReaction(workoutID, userID int64, kind string) error
+	DeleteReaction(workoutID, userID int64) error
+	GetEngagement(workoutID, viewerID int64) (*Engagement, error)
+}
+
+// CreateComment returns ErrInvalidParent when ParentID is not a top level
+// comment on the same workout.
+func (pg *PostgresCommentStore) CreateComment(comment *Comment) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if comment.ParentID != nil {
+		var valid bool
+		query := `
+    SELECT EXISTS (
+      SELECT 1 FROM comments
+      WHERE id = $1 AND workout_id = $2 AND parent_id IS NULL
+    )
+    `
+		err = tx.QueryRow(query, *comment.ParentID, comment.WorkoutID).Scan(&valid)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return ErrInvalidParent
+		}
+	}
+
+	query := `
+  WITH inserted AS (
+    INSERT INTO comments (workout_id, user_id, parent_id, body)
+    VALUES ($1, $2, $3, $4)
+    RETURNING id, user_id, created_at, updated_at
+  )
+  SELECT i.id, u.username, i.created_at, i.updated_at
+  FROM inserted i
+  JOIN users u ON u.id = i.user_id
+  `
+	err = tx.QueryRow(query, comment.WorkoutID, comment.UserID, comment.ParentID, comment.Body).Scan(&comment.ID, &comment.Username, &comment.CreatedAt, &comment.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresCommentStore) GetComment(id int64) (*Comment, error) {
+	comment := &Comment{}
+	query := `
+  SELECT c.id, c.workout_id, c.user_id, u.username, c.parent_id, c.body, c.created_at, c.updated_at
+  FROM comments c
+  JOIN users u ON u.id = c.user_id
+  WHERE c.id = $1
+  `
+	err := pg.db.QueryRow(query, id).Scan(&comment.ID, &comment.WorkoutID, &comment.UserID, &comment.Username, &comment.ParentID, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+// ListComments returns the top level comments oldest first, each with its
+// replies nested beneath it.
+func (pg *PostgresCommentStore) ListComments(workoutID int64) ([]Comment, error) {
+	query := `
+  SELECT c.id, c.workout_id, c.user_id, u.username, c.parent_id, c.body, c.created_at, c.updated_at
+  FROM comments c
+  JOIN users u ON u.id = c.user_id
+  WHERE c.workout_id = $1
+  ORDER BY c.created_at, c.id
+  `
+
+	rows, err := pg.db.Query(query, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	replies := map[int64][]Comment{}
+	for rows.Next() {
+		var comment Comment
+		err = rows.Scan(&comment.ID, &comment.WorkoutID, &comment.UserID, &comment.Username, &comment.ParentID, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if comment.ParentID != nil {
+			replies[*comment.ParentID] = append(replies[*comment.ParentID], comment)
+			continue
+		}
+		comments = append(comments, comment)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range comments {
+		comments[i].Replies = replies[comments[i].ID]
+	}
+	return comments, nil
+}
+
+// UpdateComment changes the body of a comment. It returns sql.ErrNoRows when
+// the comment does not exist.
+func (pg *PostgresCommentStore) UpdateComment(comment *Comment) error {
+	query := `
+  UPDATE comments
+  SET body = $1, updated_at = CURRENT_TIMESTAMP
+  WHERE id = $2
+  RETURNING updated_at
+  `
+	return pg.db.QueryRow(query, comment.Body, comment.ID).Scan(&comment.UpdatedAt)
+}
+
+// DeleteComment removes a comment together with its replies. It returns
+// sql.ErrNoRows when the comment does not exist.
+func (pg *PostgresCommentStore) DeleteComment(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM comments WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetReaction replaces any earlier reaction by the same user.
+func (pg *PostgresCommentStore) SetReaction(workoutID, userID int64, kind string) error {
+	query := `
+  INSERT INTO reactions (workout_id, user_id, kind)
+  VALUES ($1, $2, $3)
+  ON CONFLICT (workout_id, user_id) DO UPDATE SET kind = EXCLUDED.kind, created_at = CURRENT_TIMESTAMP
+  `
+	_, err := pg.db.Exec(query, workoutID, userID, kind)
+	return err
+}
+
+// DeleteReaction returns sql.ErrNoRows when the user had not reacted.
+func (pg *PostgresCommentStore) DeleteReaction(workoutID, userID int64) error {
+	result, err := pg.db.Exec(`DELETE FROM reactions WHERE workout_id = $1 AND user_id = $2`, workoutID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetEngagement counts the comments and reactions on a workout. viewerID 0
+// means an anonymous viewer.
+func (pg *PostgresCommentStore) GetEngagement(workoutID, viewerID int64) (*Engagement, error) {
+	engagement := &Engagement{Reactions: map[string]int{}}
+
+	err := pg.db.QueryRow(`SELECT COUNT(*) FROM comments WHERE workout_id = $1`, workoutID).Scan(&engagement.CommentCount)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+  SELECT kind, COUNT(*), COALESCE(BOOL_OR(user_id = $2), false)
+  FROM reactions
+  WHERE workout_id = $1
+  GROUP BY kind
+  `
+	rows, err := pg.db.Query(query, workoutID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind string
+		var count int
+		var mine bool
+		err = rows.Scan(&kind, &count, &mine)
+		if err != nil {
+			return nil, err
+		}
+		engagement.Reactions[kind] = count
+		if mine {
+			engagement.ViewerReaction = kind
+		}
+	}
+
+	return engagement, rows.Err()
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+type EntryGroupKind string
+
+const (
+	EntryGroupSuperset EntryGroupKind = "superset"
+	EntryGroupCircuit  EntryGroupKind = "circuit"
+	EntryGroupGiantSet EntryGroupKind = "giant_set"
+)
+
+func (k EntryGroupKind) Valid() bool {
+	switch k {
+	case EntryGroupSuperset, EntryGroupCircuit, EntryGroupGiantSet:
+		return true
+	}
+	return false
+}
+
+// EntryGroup is a superset, circuit or giant set: its entries are done back
+// to back, Rounds times over, with RestSeconds of rest between rounds.
+type EntryGroup struct {
+	ID          int            `json:"id"`
+	Kind        EntryGroupKind `json:"kind"`
+	Rounds      int            `json:"rounds"`
+	RestSeconds *int           `json:"rest_seconds"`
+	// OrderIndex places the group among the workout's ungrouped entries.
+	// The OrderIndex of its own entries only orders them within the group.
+	OrderIndex int            `json:"order_index"`
+	Entries    []WorkoutEntry `json:"entries"`
+}
+
+// UnmarshalJSON defaults an omitted "rounds" to 1, so that an explicit 0 can
+// be told apart and rejected.
+func (g *EntryGroup) UnmarshalJSON(data []byte) error {
+	type plain EntryGroup
+	aux := plain{Rounds: 1}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	*g = EntryGroup(aux)
+	return nil
+}
+
+// FlatEntry is an entry together with the number of rounds it is performed
+// for, which is 1 outside a group.
+type FlatEntry struct {
+	*WorkoutEntry
+	Rounds int
+}
+
+// FlatEntries lists the ungrouped entries and the entries of every group in
+// the order they are performed, for callers that treat a workout as a single
+// list of exercises.
+func (w *Workout) FlatEntries() []FlatEntry {
+	type item struct {
+		orderIndex int
+		entries    []FlatEntry
+	}
+
+	items := make([]item, 0, len(w.Entries)+len(w.Groups))
+	for i := range w.Entries {
+		items = append(items, item{w.Entries[i].OrderIndex, []FlatEntry{{&w.Entries[i], 1}}})
+	}
+	for i := range w.Groups {
+		group := &w.Groups[i]
+		rounds := max(group.Rounds, 1)
+		entries := make([]FlatEntry, 0, len(group.Entries))
+		for j := range group.Entries {
+			entries = append(entries, FlatEntry{&group.Entries[j], rounds})
+		}
+		items = append(items, item{group.OrderIndex, entries})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].orderIndex < items[j].orderIndex })
+
+	var flat []FlatEntry
+	for _, it := range items {
+		flat = append(flat, it.entries...)
+	}
+	return flat
+}
+
+func insertGroups(tx *sql.Tx, workout *Workout) error {
+	for i := range workout.Groups {
+		group := &workout.Groups[i]
+		if group.Rounds == 0 {
+			group.Rounds = 1
+		}
+
+		query := `
+  INSERT INTO workout_entry_groups (workout_id, kind, rounds, rest_seconds, order_index)
+  VALUES ($1, $2, $3, $4, $5)
+  RETURNING id
+  `
+		err := tx.QueryRow(query, workout.ID, string(group.Kind), group.Rounds, group.RestSeconds, group.OrderIndex).Scan(&group.ID)
+		if err != nil {
+			return err
+		}
+
+		groupID := int64(group.ID)
+		for j := range group.Entries {
+			err = insertEntry(tx, workout.ID, &groupID, &group.Entries[j])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteEmptyGroups removes groups left without entries once a workout's live
+// entries are replaced. Groups of trashed entries stay with them.
+func deleteEmptyGroups(tx *sql.Tx, workoutID int) error {
+	query := `
+  DELETE FROM workout_entry_groups g
+  WHERE g.workout_id = $1
+    AND NOT EXISTS (SELECT 1 FROM workout_entries we WHERE we.group_id = g.id)
+  `
+	_, err := tx.Exec(query, workoutID)
+	return err
+}
+
+// loadGroups returns the groups holding a workout's entries that share
+// deletedAt, without their entries.
+func loadGroups(q querier, workoutID int, deletedAt *time.Time) ([]EntryGroup, error) {
+	query := `
+  SELECT id, kind, rounds, rest_seconds, order_index
+  FROM workout_entry_groups
+  WHERE workout_id = $1 AND id IN (
+    SELECT group_id FROM workout_entries
+    WHERE workout_id = $1 AND deleted_at IS NOT DISTINCT FROM $2
+  )
+  ORDER BY order_index
+  `
+	rows, err := q.Query(query, workoutID, deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []EntryGroup{}
+	for rows.Next() {
+		var group EntryGroup
+		err = rows.Scan(&group.ID, &group.Kind, &group.Rounds, &group.RestSeconds, &group.OrderIndex)
+		if err != nil {
+			return nil, err
+		}
+		group.Entries = []WorkoutEntry{}
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
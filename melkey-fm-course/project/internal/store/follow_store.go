@@ -0,0 +1,235 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type FollowStatus string
+
+const (
+	FollowPending  FollowStatus = "pending"
+	FollowAccepted FollowStatus = "accepted"
+)
+
+type Follow struct {
+	FollowerID int64        `json:"follower_id"`
+	FolloweeID int64        `json:"followee_id"`
+	Status     FollowStatus `json:"status"`
+	CreatedAt  time.Time    `json:"created_at"`
+	AcceptedAt *time.Time   `json:"accepted_at"`
+}
+
+// FollowUser is one side of a follow, as shown in follower and following
+// lists.
+type FollowUser struct {
+	UserID    int64        `json:"user_id"`
+	Username  string       `json:"username"`
+	Status    FollowStatus `json:"status"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type FeedItem struct {
+	*Workout
+	Username string `json:"username"`
+}
+
+// FeedCursor points just past the last feed item a client has seen.
+type FeedCursor struct {
+	PerformedAt time.Time
+	WorkoutID   int
+}
+
+type PostgresFollowStore struct {
+	db *sql.DB
+}
+
+func NewPostgresFollowStore(db *sql.DB) *PostgresFollowStore {
+	return &PostgresFollowStore{db: db}
+}
+
+type FollowStore interface {
+	Follow(followerID, followeeID int64) (*Follow, error)
+	Unfollow(followerID, followeeID int64) error
+	AcceptFollow(followerID, followeeID int64) error
+	ListFollowers(userID int64, status FollowStatus) ([]FollowUser, error)
+	ListFollowing(userID int64) ([]FollowUser, error)
+	IsFollowing(followerID, followeeID int64) (bool, error)
+	GetFeed(userID int64, after *FeedCursor, limit int) ([]FeedItem, error)
+}
+
+// Follow starts following a user, or asks to when their profile is private.
+// Following someone twice returns the existing follow. It returns
+// sql.ErrNoRows when the followee does not exist.
+func (pg *PostgresFollowStore) Follow(followerID, followeeID int64) (*Follow, error) {
+	follow := &Follow{}
+	query := `
+  INSERT INTO follows (follower_id, followee_id, status, accepted_at)
+  SELECT $1, u.id,
+    CASE WHEN u.is_private THEN 'pending' ELSE 'accepted' END,
+    CASE WHEN u.is_private THEN NULL ELSE CURRENT_TIMESTAMP END
+  FROM users u
+  WHERE u.id = $2
+  ON CONFLICT (follower_id, followee_id) DO UPDATE SET status = follows.status
+  RETURNING follower_id, followee_id, status, created_at, accepted_at
+  `
+	err := pg.db.QueryRow(query, followerID, followeeID).Scan(&follow.FollowerID, &follow.FolloweeID, &follow.Status, &follow.CreatedAt, &follow.AcceptedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return follow, nil
+}
+
+// Unfollow also withdraws a pending request. It returns sql.ErrNoRows when
+// there was nothing to remove.
+func (pg *PostgresFollowStore) Unfollow(followerID, followeeID int64) error {
+	result, err := pg.db.Exec(`DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2`, followerID, followeeID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AcceptFollow approves a pending request. It returns sql.ErrNoRows when
+// there is no such request.
+func (pg *PostgresFollowStore) AcceptFollow(followerID, followeeID int64) error {
+	query := `
+  UPDATE follows
+  SET status = 'accepted', accepted_at = CURRENT_TIMESTAMP
+  WHERE follower_id = $1 AND followee_id = $2 AND status = 'pending'
+  `
+	result, err := pg.db.Exec(query, followerID, followeeID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListFollowers returns the users following userID with the given status,
+// so pending follows double as the list of requests to review.
+func (pg *PostgresFollowStore) ListFollowers(userID int64, status FollowStatus) ([]FollowUser, error) {
+	query := `
+  SELECT u.id, u.username, f.status, f.created_at
+  FROM follows f
+  JOIN users u ON u.id = f.follower_id
+  WHERE f.followee_id = $1 AND f.status = $2
+  ORDER BY f.created_at DESC
+  `
+	return pg.listFollowUsers(query, userID, string(status))
+}
+
+// ListFollowing includes requests still waiting for approval.
+func (pg *PostgresFollowStore) ListFollowing(userID int64) ([]FollowUser, error) {
+	query := `
+  SELECT u.id, u.username, f.status, f.created_at
+  FROM follows f
+  JOIN users u ON u.id = f.followee_id
+  WHERE f.follower_id = $1
+  ORDER BY f.created_at DESC
+  `
+	return pg.listFollowUsers(query, userID)
+}
+
+func (pg *PostgresFollowStore) listFollowUsers(query string, args ...any) ([]FollowUser, error) {
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []FollowUser{}
+	for rows.Next() {
+		var user FollowUser
+		err = rows.Scan(&user.UserID, &user.Username, &user.Status, &user.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// IsFollowing only counts accepted follows.
+func (pg *PostgresFollowStore) IsFollowing(followerID, followeeID int64) (bool, error) {
+	var following bool
+	query := `
+  SELECT EXISTS (
+    SELECT 1 FROM follows
+    WHERE follower_id = $1 AND followee_id = $2 AND status = 'accepted'
+  )
+  `
+	err := pg.db.QueryRow(query, followerID, followeeID).Scan(&following)
+	return following, err
+}
+
+// GetFeed returns completed workouts of the users userID follows, newest
+// first and without entries. Only public and followers-only workouts are
+// included. Pass the cursor of the last item to fetch the next page.
+func (pg *PostgresFollowStore) GetFeed(userID int64, after *FeedCursor, limit int) ([]FeedItem, error) {
+	var afterTime *time.Time
+	var afterID *int
+	if after != nil {
+		afterTime = &after.PerformedAt
+		afterID = &after.WorkoutID
+	}
+
+	query := `
+  SELECT w.id, w.user_id, u.username, w.title, COALESCE(w.description, ''), w.duration_seconds, w.calories_burned,
+    w.visibility, w.performed_at, w.created_at,
+    (SELECT COALESCE(json_agg(t.name ORDER BY lower(t.name)), '[]')
+     FROM tags t JOIN workout_tags wt ON wt.tag_id = t.id
+     WHERE wt.workout_id = w.id)
+  FROM follows f
+  JOIN workouts w ON w.user_id = f.followee_id
+  JOIN users u ON u.id = w.user_id
+  WHERE f.follower_id = $1 AND f.status = 'accepted'
+    AND w.deleted_at IS NULL AND w.scheduled_at IS NULL
+    AND w.visibility IN ('public', 'followers')
+    AND ($2::timestamptz IS NULL OR (w.performed_at, w.id) < ($2::timestamptz, $3::bigint))
+  ORDER BY w.performed_at DESC, w.id DESC
+  LIMIT $4
+  `
+
+	rows, err := pg.db.Query(query, userID, afterTime, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FeedItem{}
+	for rows.Next() {
+		item := FeedItem{Workout: &Workout{}}
+		var tagNames []byte
+		err = rows.Scan(&item.ID, &item.UserID, &item.Username, &item.Title, &item.Description, &item.DurationSeconds, &item.CaloriesBurned,
+			&item.Visibility, &item.PerformedAt, &item.CreatedAt, &tagNames)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(tagNames, &item.Tags)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
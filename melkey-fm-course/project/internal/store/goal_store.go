@@ -0,0 +1,360 @@
+package store
+
+import (
+	"database/sql"
+	"math"
+	"time"
+)
+
+type GoalKind string
+
+const (
+	GoalWorkouts        GoalKind = "workouts"
+	GoalCalories        GoalKind = "calories"
+	GoalDurationSeconds GoalKind = "duration_seconds"
+	// GoalLift is met once the exercise has been done with at least Target kg.
+	GoalLift GoalKind = "lift"
+)
+
+func (k GoalKind) Valid() bool {
+	switch k {
+	case GoalWorkouts, GoalCalories, GoalDurationSeconds, GoalLift:
+		return true
+	}
+	return false
+}
+
+type Goal struct {
+	ID           int64       `json:"id"`
+	UserID       int64       `json:"-"`
+	Kind         GoalKind    `json:"kind"`
+	Period       StatsPeriod `json:"period,omitempty"`
+	Target       float64     `json:"target"`
+	ExerciseName *string     `json:"exercise_name,omitempty"`
+	// Timezone is the owner's profile timezone, which decides where weeks
+	// and months start.
+	Timezone   string     `json:"timezone"`
+	AchievedAt *time.Time `json:"achieved_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type GoalAchievement struct {
+	PeriodStart time.Time `json:"period_start"`
+	AchievedAt  time.Time `json:"achieved_at"`
+}
+
+type GoalProgress struct {
+	Goal
+	Current float64 `json:"current"`
+	Percent float64 `json:"percent"`
+	Met     bool    `json:"met"`
+	// PeriodStart and PeriodEnd bound the current period of periodic goals.
+	PeriodStart *time.Time `json:"period_start,omitempty"`
+	PeriodEnd   *time.Time `json:"period_end,omitempty"`
+	// Streak counts consecutive periods meeting the goal, up to the current
+	// one. An unfinished current period does not break it.
+	Streak       int               `json:"streak"`
+	Achievements []GoalAchievement `json:"achievements"`
+}
+
+type PostgresGoalStore struct {
+	db *sql.DB
+}
+
+func NewPostgresGoalStore(db *sql.DB) *PostgresGoalStore {
+	return &PostgresGoalStore{db: db}
+}
+
+type GoalStore interface {
+	CreateGoal(goal *Goal) error
+	DeleteGoal(id, userID int64) error
+	ListGoalProgress(userID int64, now time.Time) ([]GoalProgress, error)
+	EvaluateGoals(userID int64, at ...time.Time) error
+}
+
+func (pg *PostgresGoalStore) CreateGoal(goal *Goal) error {
+	var period *string
+	if goal.Period != "" {
+		p := string(goal.Period)
+		period = &p
+	}
+
+	query := `
+  INSERT INTO goals (user_id, kind, period, target, exercise_name)
+  VALUES ($1, $2, $3, $4, $5)
+  RETURNING id, created_at, (SELECT timezone FROM users WHERE id = $1)
+  `
+	return pg.db.QueryRow(query, goal.UserID, string(goal.Kind), period, goal.Target, goal.ExerciseName).Scan(&goal.ID, &goal.CreatedAt, &goal.Timezone)
+}
+
+// DeleteGoal returns sql.ErrNoRows when the user has no such goal.
+func (pg *PostgresGoalStore) DeleteGoal(id, userID int64) error {
+	result, err := pg.db.Exec(`DELETE FROM goals WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListGoalProgress measures every goal of the user against their workouts
+// as of now.
+func (pg *PostgresGoalStore) ListGoalProgress(userID int64, now time.Time) ([]GoalProgress, error) {
+	goals, err := pg.listGoals(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := make([]GoalProgress, 0, len(goals))
+	for _, goal := range goals {
+		p, err := pg.measure(goal, now)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Achievements, err = pg.listAchievements(goal.ID)
+		if err != nil {
+			return nil, err
+		}
+		progress = append(progress, *p)
+	}
+
+	return progress, nil
+}
+
+// EvaluateGoals records an achievement for every period containing one of
+// at in which the user meets a goal and that has not been recorded yet. It is
+// meant to run after each write to the user's workouts with the time of the
+// write and the performed_at of the workouts written, so a workout backdated
+// into an earlier week or month is credited to it. Earlier achievements are
+// kept even if the workouts behind them later change.
+func (pg *PostgresGoalStore) EvaluateGoals(userID int64, at ...time.Time) error {
+	goals, err := pg.listGoals(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, goal := range goals {
+		if goal.Kind == GoalLift {
+			p, err := pg.measureLift(goal)
+			if err != nil {
+				return err
+			}
+			if !p.Met {
+				continue
+			}
+			_, err = pg.db.Exec(`UPDATE goals SET achieved_at = $2 WHERE id = $1 AND achieved_at IS NULL`, goal.ID, p.AchievedAt)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		totals, err := pg.periodTotals(goal)
+		if err != nil {
+			return err
+		}
+
+		loc := Location(goal.Timezone)
+		for _, t := range at {
+			start := periodStart(t.In(loc), goal.Period)
+			if totals[start.UTC()] < goal.Target {
+				continue
+			}
+			_, err = pg.db.Exec(`INSERT INTO goal_achievements (goal_id, period_start) VALUES ($1, $2) ON CONFLICT DO NOTHING`, goal.ID, start)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (pg *PostgresGoalStore) listGoals(userID int64) ([]Goal, error) {
+	query := `
+  SELECT g.id, g.user_id, g.kind, COALESCE(g.period, ''), g.target, g.exercise_name, u.timezone, g.achieved_at, g.created_at
+  FROM goals g
+  JOIN users u ON u.id = g.user_id
+  WHERE g.user_id = $1
+  ORDER BY g.id
+  `
+
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	goals := []Goal{}
+	for rows.Next() {
+		var goal Goal
+		err = rows.Scan(&goal.ID, &goal.UserID, &goal.Kind, &goal.Period, &goal.Target, &goal.ExerciseName, &goal.Timezone, &goal.AchievedAt, &goal.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, goal)
+	}
+
+	return goals, rows.Err()
+}
+
+func (pg *PostgresGoalStore) listAchievements(goalID int64) ([]GoalAchievement, error) {
+	query := `
+  SELECT period_start, achieved_at
+  FROM goal_achievements
+  WHERE goal_id = $1
+  ORDER BY period_start DESC
+  `
+
+	rows, err := pg.db.Query(query, goalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	achievements := []GoalAchievement{}
+	for rows.Next() {
+		var a GoalAchievement
+		err = rows.Scan(&a.PeriodStart, &a.AchievedAt)
+		if err != nil {
+			return nil, err
+		}
+		achievements = append(achievements, a)
+	}
+
+	return achievements, rows.Err()
+}
+
+func (pg *PostgresGoalStore) measure(goal Goal, now time.Time) (*GoalProgress, error) {
+	if goal.Kind == GoalLift {
+		return pg.measureLift(goal)
+	}
+	return pg.measurePeriodic(goal, now)
+}
+
+func (pg *PostgresGoalStore) measureLift(goal Goal) (*GoalProgress, error) {
+	p := &GoalProgress{Goal: goal}
+
+	var best sql.NullFloat64
+	var firstMet sql.NullTime
+	query := `
+  SELECT MAX(we.weight), MIN(w.performed_at) FILTER (WHERE we.weight >= $3)
+  FROM workout_entries we
+  JOIN workouts w ON w.id = we.workout_id
+  WHERE w.user_id = $1 AND w.deleted_at IS NULL AND w.scheduled_at IS NULL
+    AND we.deleted_at IS NULL AND lower(we.exercise_name) = lower($2)
+  `
+	err := pg.db.QueryRow(query, goal.UserID, goal.ExerciseName, goal.Target).Scan(&best, &firstMet)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Current = best.Float64
+	p.Met = firstMet.Valid
+	if p.Met && p.AchievedAt == nil {
+		p.AchievedAt = &firstMet.Time
+	}
+	p.Percent = percentOf(p.Current, goal.Target)
+	return p, nil
+}
+
+func (pg *PostgresGoalStore) measurePeriodic(goal Goal, now time.Time) (*GoalProgress, error) {
+	p := &GoalProgress{Goal: goal}
+
+	totals, err := pg.periodTotals(goal)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := Location(goal.Timezone)
+	start := periodStart(now.In(loc), goal.Period)
+	end := shiftPeriod(start, goal.Period, 1)
+	p.PeriodStart = &start
+	p.PeriodEnd = &end
+	p.Current = totals[start.UTC()]
+	p.Met = p.Current >= goal.Target
+	p.Percent = percentOf(p.Current, goal.Target)
+
+	bucket := start
+	if !p.Met {
+		bucket = shiftPeriod(start, goal.Period, -1)
+	}
+	for totals[bucket.UTC()] >= goal.Target {
+		p.Streak++
+		bucket = shiftPeriod(bucket, goal.Period, -1)
+	}
+
+	return p, nil
+}
+
+// periodTotals sums the goal's measure over every period the user has
+// workouts in, keyed by the period's start in UTC.
+func (pg *PostgresGoalStore) periodTotals(goal Goal) (map[time.Time]float64, error) {
+	var column string
+	switch goal.Kind {
+	case GoalWorkouts:
+		column = "COUNT(*)"
+	case GoalCalories:
+		column = "COALESCE(SUM(calories_burned), 0)"
+	default:
+		column = "SUM(duration_seconds)::float8"
+	}
+
+	// column is one of the fixed expressions above, never user input
+	query := `
+  SELECT date_trunc($2, performed_at AT TIME ZONE $3) AT TIME ZONE $3 AS bucket, ` + column + `
+  FROM workouts
+  WHERE user_id = $1 AND deleted_at IS NULL AND scheduled_at IS NULL
+  GROUP BY 1
+  ORDER BY 1 DESC
+  `
+
+	rows, err := pg.db.Query(query, goal.UserID, string(goal.Period), Location(goal.Timezone).String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := map[time.Time]float64{}
+	for rows.Next() {
+		var bucket time.Time
+		var total float64
+		err = rows.Scan(&bucket, &total)
+		if err != nil {
+			return nil, err
+		}
+		totals[bucket.UTC()] = total
+	}
+	return totals, rows.Err()
+}
+
+// periodStart returns midnight on the Monday of t's week, or on the first of
+// its month, in t's location.
+func periodStart(t time.Time, period StatsPeriod) time.Time {
+	year, month, day := t.Date()
+	if period == StatsPeriodMonth {
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	}
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location())
+}
+
+func shiftPeriod(start time.Time, period StatsPeriod, n int) time.Time {
+	if period == StatsPeriodMonth {
+		return start.AddDate(0, n, 0)
+	}
+	return start.AddDate(0, 0, 7*n)
+}
+
+func percentOf(current, target float64) float64 {
+	percent := math.Min(current/target*100, 100)
+	return math.Round(percent*10) / 10
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	// JobFailed is final: the job ran out of attempts.
+	JobFailed JobStatus = "failed"
+)
+
+// Job is a unit of background work. Attempts counts the runs started so far,
+// including the current one while the job is running.
+type Job struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      JobStatus       `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	// UniqueKey, when set, keeps a second job with the same key from being
+	// queued while the first is unfinished.
+	UniqueKey *string   `json:"unique_key,omitempty"`
+	LastError *string   `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PostgresJobStore struct {
+	db *sql.DB
+}
+
+func NewPostgresJobStore(db *sql.DB) *PostgresJobStore {
+	return &PostgresJobStore{db: db}
+}
+
+type JobStore interface {
+	// EnqueueJob reports false when an unfinished job already holds the
+	// job's UniqueKey.
+	EnqueueJob(job *Job) (bool, error)
+	// ClaimJobs marks up to limit due jobs of jobType as running for lease
+	// and returns them. Jobs whose lease ran out are claimed again, or failed
+	// if that was their last attempt.
+	ClaimJobs(jobType string, limit int, lease time.Duration, workerID string) ([]Job, error)
+	// CompleteJob, RetryJob and FailJob settle the given attempt of a job.
+	// They do nothing when the attempt has since been claimed again.
+	CompleteJob(id int64, attempt int) error
+	RetryJob(id int64, attempt int, runAt time.Time, reason string) error
+	FailJob(id int64, attempt int, reason string) error
+	PurgeJobs(finishedBefore time.Time) (int64, error)
+}
+
+func (pg *PostgresJobStore) EnqueueJob(job *Job) (bool, error) {
+	if job.Payload == nil {
+		job.Payload = json.RawMessage(`{}`)
+	}
+	var runAt *time.Time
+	if !job.RunAt.IsZero() {
+		runAt = &job.RunAt
+	}
+	var maxAttempts *int
+	if job.MaxAttempts > 0 {
+		maxAttempts = &job.MaxAttempts
+	}
+
+	query := `
+  INSERT INTO jobs (type, payload, run_at, max_attempts, unique_key)
+  VALUES ($1, $2, COALESCE($3, CURRENT_TIMESTAMP), COALESCE($4, 5), $5)
+  ON CONFLICT (unique_key) WHERE status IN ('queued', 'running') DO NOTHING
+  RETURNING id, status, run_at, max_attempts, created_at
+  `
+	err := pg.db.QueryRow(query, job.Type, []byte(job.Payload), runAt, maxAttempts, job.UniqueKey).Scan(&job.ID, &job.Status, &job.RunAt, &job.MaxAttempts, &job.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (pg *PostgresJobStore) ClaimJobs(jobType string, limit int, lease time.Duration, workerID string) ([]Job, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+  UPDATE jobs
+  SET status = 'failed', last_error = 'lease expired on the last attempt', finished_at = CURRENT_TIMESTAMP,
+    locked_until = NULL, locked_by = NULL
+  WHERE type = $1 AND status = 'running' AND locked_until < CURRENT_TIMESTAMP AND attempts >= max_attempts
+  `
+	_, err = tx.Exec(query, jobType)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+  UPDATE jobs
+  SET status = 'running', attempts = attempts + 1,
+    locked_until = CURRENT_TIMESTAMP + make_interval(secs => $3), locked_by = $4
+  WHERE id IN (
+    SELECT id FROM jobs
+    WHERE type = $1 AND (
+      (status = 'queued' AND run_at <= CURRENT_TIMESTAMP)
+      OR (status = 'running' AND locked_until < CURRENT_TIMESTAMP)
+    )
+    ORDER BY run_at, id
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+  )
+  RETURNING id, type, payload, status, attempts, max_attempts, run_at, unique_key, last_error, created_at
+  `
+	rows, err := tx.Query(query, jobType, limit, lease.Seconds(), workerID)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []Job{}
+	for rows.Next() {
+		var job Job
+		err = rows.Scan(&job.ID, &job.Type, &job.Payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt, &job.UniqueKey, &job.LastError, &job.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, tx.Commit()
+}
+
+func (pg *PostgresJobStore) CompleteJob(id int64, attempt int) error {
+	query := `
+  UPDATE jobs
+  SET status = 'succeeded', finished_at = CURRENT_TIMESTAMP, locked_until = NULL, locked_by = NULL
+  WHERE id = $1 AND attempts = $2 AND status = 'running'
+  `
+	_, err := pg.db.Exec(query, id, attempt)
+	return err
+}
+
+func (pg *PostgresJobStore) RetryJob(id int64, attempt int, runAt time.Time, reason string) error {
+	query := `
+  UPDATE jobs
+  SET status = 'queued', run_at = $3, last_error = $4, locked_until = NULL, locked_by = NULL
+  WHERE id = $1 AND attempts = $2 AND status = 'running'
+  `
+	_, err := pg.db.Exec(query, id, attempt, runAt, reason)
+	return err
+}
+
+func (pg *PostgresJobStore) FailJob(id int64, attempt int, reason string) error {
+	query := `
+  UPDATE jobs
+  SET status = 'failed', last_error = $3, finished_at = CURRENT_TIMESTAMP, locked_until = NULL, locked_by = NULL
+  WHERE id = $1 AND attempts = $2 AND status = 'running'
+  `
+	_, err := pg.db.Exec(query, id, attempt, reason)
+	return err
+}
+
+// PurgeJobs removes succeeded and failed jobs that finished before
+// finishedBefore.
+func (pg *PostgresJobStore) PurgeJobs(finishedBefore time.Time) (int64, error) {
+	result, err := pg.db.Exec(`DELETE FROM jobs WHERE finished_at < $1`, finishedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
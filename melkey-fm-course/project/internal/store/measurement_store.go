@@ -0,0 +1,272 @@
+package store
+
+import (
+	"database/sql"
+	"math"
+	"time"
+)
+
+type MeasurementKind string
+
+const (
+	MeasurementBodyWeight MeasurementKind = "body_weight"
+	MeasurementBodyFat    MeasurementKind = "body_fat"
+)
+
+// MeasurementKinds maps every kind to the unit its values are stored in.
+var MeasurementKinds = map[MeasurementKind]string{
+	MeasurementBodyWeight: "kg",
+	MeasurementBodyFat:    "%",
+	"neck":                "cm",
+	"chest":               "cm",
+	"waist":               "cm",
+	"hips":                "cm",
+	"arm":                 "cm",
+	"forearm":             "cm",
+	"thigh":               "cm",
+	"calf":                "cm",
+}
+
+type Measurement struct {
+	ID         int64           `json:"id"`
+	UserID     int64           `json:"-"`
+	Kind       MeasurementKind `json:"kind"`
+	Value      float64         `json:"value"`
+	Unit       string          `json:"unit"`
+	Notes      string          `json:"notes"`
+	MeasuredAt time.Time       `json:"measured_at"`
+	// MovingAverage is the mean of this and the preceding values of the same
+	// kind within the listed range, set by ListMeasurements.
+	MovingAverage *float64 `json:"moving_average,omitempty"`
+}
+
+type MeasurementFilter struct {
+	UserID int64
+	Kind   MeasurementKind
+	From   *time.Time
+	To     *time.Time
+	// Window is how many measurements the moving average spans.
+	Window int
+}
+
+// RelativeStrength compares the heaviest weight lifted for an exercise with
+// the lifter's body weight.
+type RelativeStrength struct {
+	ExerciseName string    `json:"exercise_name"`
+	MaxWeight    float64   `json:"max_weight"`
+	LiftedAt     time.Time `json:"lifted_at"`
+	// BodyWeight is the closest body_weight measurement to LiftedAt,
+	// preferring the last one taken before it.
+	BodyWeight        *float64 `json:"body_weight"`
+	Ratio             *float64 `json:"ratio"`
+	CurrentBodyWeight *float64 `json:"current_body_weight"`
+	CurrentRatio      *float64 `json:"current_ratio"`
+}
+
+type PostgresMeasurementStore struct {
+	db *sql.DB
+}
+
+func NewPostgresMeasurementStore(db *sql.DB) *PostgresMeasurementStore {
+	return &PostgresMeasurementStore{db: db}
+}
+
+type MeasurementStore interface {
+	CreateMeasurement(m *Measurement) error
+	GetMeasurement(id, userID int64) (*Measurement, error)
+	ListMeasurements(filter MeasurementFilter) ([]Measurement, error)
+	UpdateMeasurement(m *Measurement) error
+	DeleteMeasurement(id, userID int64) error
+	GetRelativeStrength(userID int64, exerciseName string) ([]RelativeStrength, error)
+}
+
+func (pg *PostgresMeasurementStore) CreateMeasurement(m *Measurement) error {
+	var measuredAt *time.Time
+	if !m.MeasuredAt.IsZero() {
+		measuredAt = &m.MeasuredAt
+	}
+
+	query := `
+  INSERT INTO measurements (user_id, kind, value, notes, measured_at)
+  VALUES ($1, $2, $3, $4, COALESCE($5, CURRENT_TIMESTAMP))
+  RETURNING id, measured_at
+  `
+	err := pg.db.QueryRow(query, m.UserID, string(m.Kind), m.Value, m.Notes, measuredAt).Scan(&m.ID, &m.MeasuredAt)
+	if err != nil {
+		return err
+	}
+
+	m.Unit = MeasurementKinds[m.Kind]
+	return nil
+}
+
+func (pg *PostgresMeasurementStore) GetMeasurement(id, userID int64) (*Measurement, error) {
+	m := &Measurement{}
+	query := `
+  SELECT id, user_id, kind, value, COALESCE(notes, ''), measured_at
+  FROM measurements
+  WHERE id = $1 AND user_id = $2
+  `
+	err := pg.db.QueryRow(query, id, userID).Scan(&m.ID, &m.UserID, &m.Kind, &m.Value, &m.Notes, &m.MeasuredAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m.Unit = MeasurementKinds[m.Kind]
+	return m, nil
+}
+
+// ListMeasurements returns measurements oldest first, each with a trailing
+// moving average over the previous Window values of its kind. The average is
+// taken over the whole series before From and To are applied, so the first
+// rows in range still average in the values just before it.
+func (pg *PostgresMeasurementStore) ListMeasurements(filter MeasurementFilter) ([]Measurement, error) {
+	window := filter.Window
+	if window < 1 {
+		window = 1
+	}
+
+	query := `
+  SELECT id, user_id, kind, value, notes, measured_at, moving_average
+  FROM (
+    SELECT id, user_id, kind, value, COALESCE(notes, '') AS notes, measured_at,
+      ROUND(AVG(value) OVER (
+        PARTITION BY kind ORDER BY measured_at, id
+        ROWS BETWEEN $5::int PRECEDING AND CURRENT ROW
+      )::numeric, 2)::double precision AS moving_average
+    FROM measurements
+    WHERE user_id = $1
+      AND ($2 = '' OR kind = $2)
+      AND ($4::timestamptz IS NULL OR measured_at < $4)
+  ) m
+  WHERE $3::timestamptz IS NULL OR measured_at >= $3
+  ORDER BY measured_at, id
+  `
+
+	rows, err := pg.db.Query(query, filter.UserID, string(filter.Kind), filter.From, filter.To, window-1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	measurements := []Measurement{}
+	for rows.Next() {
+		var m Measurement
+		var avg float64
+		err = rows.Scan(&m.ID, &m.UserID, &m.Kind, &m.Value, &m.Notes, &m.MeasuredAt, &avg)
+		if err != nil {
+			return nil, err
+		}
+		m.Unit = MeasurementKinds[m.Kind]
+		m.MovingAverage = &avg
+		measurements = append(measurements, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return measurements, nil
+}
+
+// UpdateMeasurement returns sql.ErrNoRows when the user has no such
+// measurement.
+func (pg *PostgresMeasurementStore) UpdateMeasurement(m *Measurement) error {
+	query := `
+  UPDATE measurements
+  SET kind = $1, value = $2, notes = $3, measured_at = $4
+  WHERE id = $5 AND user_id = $6
+  `
+	result, err := pg.db.Exec(query, string(m.Kind), m.Value, m.Notes, m.MeasuredAt, m.ID, m.UserID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	m.Unit = MeasurementKinds[m.Kind]
+	return nil
+}
+
+// DeleteMeasurement returns sql.ErrNoRows when the user has no such
+// measurement.
+func (pg *PostgresMeasurementStore) DeleteMeasurement(id, userID int64) error {
+	result, err := pg.db.Exec(`DELETE FROM measurements WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetRelativeStrength returns one row per exercise the user has logged a
+// weight for, optionally limited to exerciseName.
+func (pg *PostgresMeasurementStore) GetRelativeStrength(userID int64, exerciseName string) ([]RelativeStrength, error) {
+	query := `
+  WITH best AS (
+    SELECT DISTINCT ON (lower(we.exercise_name)) we.exercise_name, we.weight, w.performed_at
+    FROM workout_entries we
+    JOIN workouts w ON w.id = we.workout_id
+    WHERE w.user_id = $1 AND w.deleted_at IS NULL AND w.scheduled_at IS NULL
+      AND we.deleted_at IS NULL AND we.weight > 0
+      AND ($2 = '' OR lower(we.exercise_name) = lower($2))
+    ORDER BY lower(we.exercise_name), we.weight DESC, w.performed_at
+  ),
+  body_weights AS (
+    SELECT value, measured_at
+    FROM measurements
+    WHERE user_id = $1 AND kind = 'body_weight'
+  )
+  SELECT b.exercise_name, b.weight, b.performed_at,
+    COALESCE(
+      (SELECT value FROM body_weights WHERE measured_at <= b.performed_at ORDER BY measured_at DESC LIMIT 1),
+      (SELECT value FROM body_weights WHERE measured_at > b.performed_at ORDER BY measured_at LIMIT 1)
+    ),
+    (SELECT value FROM body_weights ORDER BY measured_at DESC LIMIT 1)
+  FROM best b
+  ORDER BY lower(b.exercise_name)
+  `
+
+	rows, err := pg.db.Query(query, userID, exerciseName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []RelativeStrength{}
+	for rows.Next() {
+		var rs RelativeStrength
+		err = rows.Scan(&rs.ExerciseName, &rs.MaxWeight, &rs.LiftedAt, &rs.BodyWeight, &rs.CurrentBodyWeight)
+		if err != nil {
+			return nil, err
+		}
+		rs.Ratio = ratio(rs.MaxWeight, rs.BodyWeight)
+		rs.CurrentRatio = ratio(rs.MaxWeight, rs.CurrentBodyWeight)
+		results = append(results, rs)
+	}
+
+	return results, rows.Err()
+}
+
+func ratio(weight float64, bodyWeight *float64) *float64 {
+	if bodyWeight == nil || *bodyWeight <= 0 {
+		return nil
+	}
+	r := math.Round(weight / *bodyWeight * 100) / 100
+	return &r
+}
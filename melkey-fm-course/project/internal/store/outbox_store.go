@@ -0,0 +1,170 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Topics of the events published by workout mutations.
+const (
+	TopicWorkoutCreated = "workout.created"
+	TopicWorkoutUpdated = "workout.updated"
+	TopicWorkoutDeleted = "workout.deleted"
+)
+
+// OutboxEvent is a domain event written in the same transaction as the
+// change it describes. EventID stays the same across redeliveries so
+// subscribers can discard duplicates.
+type OutboxEvent struct {
+	ID          int64
+	EventID     string
+	Topic       string
+	AggregateID int64
+	Payload     json.RawMessage
+	Attempts    int
+	CreatedAt   time.Time
+}
+
+// WorkoutEventPayload is the payload of the workout topics.
+type WorkoutEventPayload struct {
+	Action    RevisionAction `json:"action"`
+	ActorID   *int64         `json:"actor_id"`
+	RequestID string         `json:"request_id,omitempty"`
+	Workout   *Workout       `json:"workout"`
+}
+
+type PostgresOutboxStore struct {
+	db *sql.DB
+}
+
+func NewPostgresOutboxStore(db *sql.DB) *PostgresOutboxStore {
+	return &PostgresOutboxStore{db: db}
+}
+
+type OutboxStore interface {
+	// ProcessOutbox locks up to limit undelivered events that are due and
+	// passes each to fn, oldest first. Events fn accepts are marked
+	// delivered; the others are retried after retryAfter(attempts). The row
+	// locks are held until the batch is done, so concurrent relays skip
+	// these events, and a relay that dies leaves them to be sent again.
+	ProcessOutbox(limit int, fn func(OutboxEvent) error, retryAfter func(attempts int) time.Duration) (int, error)
+	PurgeOutbox(deliveredBefore time.Time) (int64, error)
+}
+
+func (pg *PostgresOutboxStore) ProcessOutbox(limit int, fn func(OutboxEvent) error, retryAfter func(attempts int) time.Duration) (int, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+  SELECT id, event_id, topic, aggregate_id, payload, attempts, created_at
+  FROM outbox
+  WHERE delivered_at IS NULL AND available_at <= CURRENT_TIMESTAMP
+  ORDER BY id
+  LIMIT $1
+  FOR UPDATE SKIP LOCKED
+  `
+	rows, err := tx.Query(query, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var event OutboxEvent
+		err = rows.Scan(&event.ID, &event.EventID, &event.Topic, &event.AggregateID, &event.Payload, &event.Attempts, &event.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		fnErr := fn(event)
+		if fnErr == nil {
+			_, err = tx.Exec(`UPDATE outbox SET delivered_at = CURRENT_TIMESTAMP, attempts = attempts + 1 WHERE id = $1`, event.ID)
+		} else {
+			query := `
+  UPDATE outbox
+  SET attempts = attempts + 1, last_error = $2, available_at = CURRENT_TIMESTAMP + make_interval(secs => $3)
+  WHERE id = $1
+  `
+			_, err = tx.Exec(query, event.ID, fnErr.Error(), retryAfter(event.Attempts+1).Seconds())
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(events), tx.Commit()
+}
+
+// PurgeOutbox removes events delivered before deliveredBefore.
+func (pg *PostgresOutboxStore) PurgeOutbox(deliveredBefore time.Time) (int64, error) {
+	result, err := pg.db.Exec(`DELETE FROM outbox WHERE delivered_at < $1`, deliveredBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// workoutTopic maps a revision to the event it publishes. A restored workout
+// is announced as created again, since it was last seen deleted.
+func workoutTopic(action RevisionAction) string {
+	switch action {
+	case RevisionCreate, RevisionRestore:
+		return TopicWorkoutCreated
+	case RevisionDelete:
+		return TopicWorkoutDeleted
+	}
+	return TopicWorkoutUpdated
+}
+
+// publishWorkoutEvent writes the event for a workout mutation to the outbox.
+// It runs inside the mutation's transaction, so the event exists exactly when
+// the change was committed.
+func publishWorkoutEvent(tx *sql.Tx, workout *Workout, action RevisionAction, actor Actor) error {
+	eventID, err := newEventID()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(WorkoutEventPayload{
+		Action:    action,
+		ActorID:   actor.UserID,
+		RequestID: actor.RequestID,
+		Workout:   workout,
+	})
+	if err != nil {
+		return err
+	}
+
+	query := `
+  INSERT INTO outbox (event_id, topic, aggregate_id, payload)
+  VALUES ($1, $2, $3, $4)
+  `
+	_, err = tx.Exec(query, eventID, workoutTopic(action), workout.ID, payload)
+	return err
+}
+
+// newEventID returns a random version 4 UUID.
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
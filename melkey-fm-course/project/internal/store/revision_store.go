@@ -0,0 +1,226 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type RevisionAction string
+
+const (
+	RevisionCreate  RevisionAction = "create"
+	RevisionUpdate  RevisionAction = "update"
+	RevisionDelete  RevisionAction = "delete"
+	RevisionRestore RevisionAction = "restore"
+	RevisionRevert  RevisionAction = "revert"
+)
+
+// Actor identifies who performed a mutation. UserID is nil for system work
+// such as imports run outside a request.
+type Actor struct {
+	UserID    *int64
+	RequestID string
+}
+
+type WorkoutRevision struct {
+	ID        int64           `json:"id"`
+	WorkoutID int64           `json:"workout_id"`
+	Revision  int             `json:"revision"`
+	Action    RevisionAction  `json:"action"`
+	Snapshot  json.RawMessage `json:"snapshot,omitempty"`
+	ActorID   *int64          `json:"actor_id"`
+	RequestID string          `json:"request_id"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// recordRevision snapshots the workout as it is inside tx, so it must run
+// after the mutation it describes. It also publishes the matching workout
+// event to the outbox.
+func recordRevision(tx *sql.Tx, workoutID int64, action RevisionAction, actor Actor) error {
+	workout, err := getWorkout(tx, workoutID, true)
+	if err != nil {
+		return err
+	}
+	if workout == nil {
+		return sql.ErrNoRows
+	}
+
+	snapshot, err := json.Marshal(workout)
+	if err != nil {
+		return err
+	}
+
+	query := `
+  INSERT INTO workout_revisions (workout_id, owner_id, revision, action, snapshot, actor_id, request_id)
+  SELECT $1, $2, COALESCE(MAX(revision), 0) + 1, $3, $4, $5, NULLIF($6, '')
+  FROM workout_revisions
+  WHERE workout_id = $1
+  `
+	_, err = tx.Exec(query, workoutID, workout.UserID, action, snapshot, actor.UserID, actor.RequestID)
+	if err != nil {
+		return err
+	}
+
+	return publishWorkoutEvent(tx, workout, action, actor)
+}
+
+// ListRevisions returns a workout's history oldest first, without snapshots.
+func (pg *PostgresWorkoutStore) ListRevisions(workoutID, ownerID int64) ([]WorkoutRevision, error) {
+	query := `
+  SELECT id, workout_id, revision, action, actor_id, COALESCE(request_id, ''), created_at
+  FROM workout_revisions
+  WHERE workout_id = $1 AND owner_id = $2
+  ORDER BY revision
+  `
+
+	rows, err := pg.db.Query(query, workoutID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []WorkoutRevision{}
+	for rows.Next() {
+		var rev WorkoutRevision
+		err = rows.Scan(&rev.ID, &rev.WorkoutID, &rev.Revision, &rev.Action, &rev.ActorID, &rev.RequestID, &rev.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (pg *PostgresWorkoutStore) GetRevision(workoutID, ownerID int64, revision int) (*WorkoutRevision, error) {
+	return getRevision(pg.db, workoutID, ownerID, revision)
+}
+
+func getRevision(q querier, workoutID, ownerID int64, revision int) (*WorkoutRevision, error) {
+	rev := &WorkoutRevision{}
+	query := `
+  SELECT id, workout_id, revision, action, snapshot, actor_id, COALESCE(request_id, ''), created_at
+  FROM workout_revisions
+  WHERE workout_id = $1 AND owner_id = $2 AND revision = $3
+  `
+	err := q.QueryRow(query, workoutID, ownerID, revision).Scan(&rev.ID, &rev.WorkoutID, &rev.Revision, &rev.Action, &rev.Snapshot, &rev.ActorID, &rev.RequestID, &rev.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rev.Snapshot, err = upgradeSnapshot(rev.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// legacySnapshot is the part of a snapshot taken while durations were stored
+// in minutes.
+type legacySnapshot struct {
+	DurationMinutes *int `json:"duration_minutes"`
+	Entries         []struct {
+		DurationMinutes *int `json:"duration_minutes"`
+	} `json:"entries"`
+	Groups []struct {
+		Entries []struct {
+			DurationMinutes *int `json:"duration_minutes"`
+		} `json:"entries"`
+	} `json:"groups"`
+}
+
+// upgradeSnapshot converts a snapshot taken in minutes to seconds. Revisions
+// are immutable, so old ones are converted whenever they are read instead of
+// once by a migration.
+func upgradeSnapshot(snapshot json.RawMessage) (json.RawMessage, error) {
+	var legacy legacySnapshot
+	err := json.Unmarshal(snapshot, &legacy)
+	if err != nil {
+		return nil, err
+	}
+	if legacy.DurationMinutes == nil {
+		return snapshot, nil
+	}
+
+	var workout Workout
+	err = json.Unmarshal(snapshot, &workout)
+	if err != nil {
+		return nil, err
+	}
+
+	seconds := func(minutes *int) *int {
+		if minutes == nil {
+			return nil
+		}
+		s := *minutes * 60
+		return &s
+	}
+	workout.DurationSeconds = *seconds(legacy.DurationMinutes)
+	for i, entry := range legacy.Entries {
+		workout.Entries[i].DurationSeconds = seconds(entry.DurationMinutes)
+	}
+	for i, group := range legacy.Groups {
+		for j, entry := range group.Entries {
+			workout.Groups[i].Entries[j].DurationSeconds = seconds(entry.DurationMinutes)
+		}
+	}
+	return json.Marshal(&workout)
+}
+
+// RevertWorkout restores the fields and entries captured in an earlier
+// revision onto the live workout, recording the result as a new revision.
+// It returns sql.ErrNoRows when the revision or the live workout is missing.
+func (pg *PostgresWorkoutStore) RevertWorkout(workoutID, ownerID int64, revision int, actor Actor) (*Workout, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var locked int64
+	err = tx.QueryRow(`SELECT id FROM workouts WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE`, workoutID, ownerID).Scan(&locked)
+	if err != nil {
+		return nil, err
+	}
+
+	rev, err := getRevision(tx, workoutID, ownerID, revision)
+	if err != nil {
+		return nil, err
+	}
+	if rev == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	workout := &Workout{}
+	err = json.Unmarshal(rev.Snapshot, workout)
+	if err != nil {
+		return nil, err
+	}
+	workout.ID = int(workoutID)
+
+	err = updateWorkout(tx, workout)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordRevision(tx, workoutID, RevisionRevert, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	reverted, err := getWorkout(tx, workoutID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return reverted, nil
+}
@@ -0,0 +1,265 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Matched terms are wrapped in these private use characters while the
+// snippet is built, then turned into <mark> once the text has been escaped.
+const (
+	highlightStart = ""
+	highlightStop  = ""
+)
+
+type SearchSnippet struct {
+	Field string `json:"field"`
+	// Text is HTML escaped with matches wrapped in <mark></mark>.
+	Text string `json:"text"`
+}
+
+type SearchHit struct {
+	WorkoutID int             `json:"workout_id"`
+	Title     string          `json:"title"`
+	CreatedAt time.Time       `json:"created_at"`
+	Rank      float64         `json:"rank"`
+	Snippets  []SearchSnippet `json:"snippets"`
+}
+
+type SearchQuery struct {
+	UserID int64
+	Text   string
+	Limit  int
+	Offset int
+}
+
+// SearchStore finds a user's live workouts by their title, description and
+// entry notes, best match first.
+type SearchStore interface {
+	SearchWorkouts(query SearchQuery) ([]SearchHit, error)
+}
+
+type PostgresSearchStore struct {
+	db *sql.DB
+}
+
+func NewPostgresSearchStore(db *sql.DB) *PostgresSearchStore {
+	return &PostgresSearchStore{db: db}
+}
+
+// SearchWorkouts uses the search_vector columns, so the text accepts web
+// search syntax such as quoted phrases, "or" and a leading "-".
+func (pg *PostgresSearchStore) SearchWorkouts(q SearchQuery) ([]SearchHit, error) {
+	options := fmt.Sprintf(`StartSel=%s, StopSel=%s, MaxWords=20, MinWords=5, MaxFragments=2, FragmentDelimiter=" ... "`, highlightStart, highlightStop)
+
+	query := `
+  WITH q AS (
+    SELECT websearch_to_tsquery('english', $2) AS query
+  ),
+  entry_hits AS (
+    SELECT we.workout_id, MAX(ts_rank(we.search_vector, q.query)) AS rank,
+      string_agg(we.notes, ' ... ' ORDER BY we.order_index) AS notes
+    FROM workout_entries we, q
+    WHERE we.deleted_at IS NULL AND we.search_vector @@ q.query
+    GROUP BY we.workout_id
+  )
+  SELECT w.id, w.title, w.created_at,
+    ts_rank(w.search_vector, q.query) + COALESCE(e.rank, 0) AS rank,
+    CASE WHEN to_tsvector('english', w.title) @@ q.query
+      THEN ts_headline('english', w.title, q.query, $5) ELSE '' END,
+    CASE WHEN to_tsvector('english', COALESCE(w.description, '')) @@ q.query
+      THEN ts_headline('english', w.description, q.query, $5) ELSE '' END,
+    COALESCE(ts_headline('english', e.notes, q.query, $5), '')
+  FROM workouts w
+  CROSS JOIN q
+  LEFT JOIN entry_hits e ON e.workout_id = w.id
+  WHERE w.user_id = $1 AND w.deleted_at IS NULL
+    AND (w.search_vector @@ q.query OR e.workout_id IS NOT NULL)
+  ORDER BY rank DESC, w.created_at DESC
+  LIMIT $3 OFFSET $4
+  `
+
+	rows, err := pg.db.Query(query, q.UserID, q.Text, q.Limit, q.Offset, options)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []SearchHit{}
+	for rows.Next() {
+		var hit SearchHit
+		var title, description, notes string
+		err = rows.Scan(&hit.WorkoutID, &hit.Title, &hit.CreatedAt, &hit.Rank, &title, &description, &notes)
+		if err != nil {
+			return nil, err
+		}
+		hit.Snippets = snippets(title, description, notes)
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
+
+// LikeSearchStore matches with plain ILIKE instead of the search_vector
+// columns, for when the english text search configuration does not suit the
+// data. It is still Postgres only. Every word of the text must appear, case
+// insensitively, somewhere in the workout; ranking and paging happen in the
+// database and only the snippets of the returned page are built here.
+type LikeSearchStore struct {
+	db *sql.DB
+}
+
+func NewLikeSearchStore(db *sql.DB) *LikeSearchStore {
+	return &LikeSearchStore{db: db}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (ls *LikeSearchStore) SearchWorkouts(q SearchQuery) ([]SearchHit, error) {
+	terms := strings.Fields(strings.ToLower(q.Text))
+	if len(terms) == 0 {
+		return []SearchHit{}, nil
+	}
+
+	patterns := make([]string, 0, len(terms))
+	for _, term := range terms {
+		patterns = append(patterns, "%"+likeEscaper.Replace(term)+"%")
+	}
+
+	// the rank counts term occurrences, weighted like the A, B and C weights
+	// of the search_vector columns
+	query := `
+  WITH candidates AS (
+    SELECT w.id, w.title, COALESCE(w.description, '') AS description, w.created_at,
+      COALESCE((
+        SELECT string_agg(we.notes, ' ... ' ORDER BY we.order_index)
+        FROM workout_entries we
+        WHERE we.workout_id = w.id AND we.deleted_at IS NULL AND COALESCE(we.notes, '') <> ''
+      ), '') AS notes
+    FROM workouts w
+    WHERE w.user_id = $1 AND w.deleted_at IS NULL
+  )
+  SELECT c.id, c.title, c.description, c.created_at, c.notes,
+    (SELECT SUM(
+        3 * (length(lower(c.title)) - length(replace(lower(c.title), term, ''))) / length(term)
+      + 2 * (length(lower(c.description)) - length(replace(lower(c.description), term, ''))) / length(term)
+      + (length(lower(c.notes)) - length(replace(lower(c.notes), term, ''))) / length(term)
+    ) FROM unnest($2::text[]) AS t(term))::float8 AS rank
+  FROM candidates c
+  WHERE (c.title || ' ' || c.description || ' ' || c.notes) ILIKE ALL ($3::text[])
+  ORDER BY rank DESC, c.created_at DESC
+  LIMIT $4 OFFSET $5
+  `
+
+	rows, err := ls.db.Query(query, q.UserID, terms, patterns, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []SearchHit{}
+	for rows.Next() {
+		var hit SearchHit
+		var description, notes string
+		err = rows.Scan(&hit.WorkoutID, &hit.Title, &description, &hit.CreatedAt, &notes, &hit.Rank)
+		if err != nil {
+			return nil, err
+		}
+		hit.Snippets = snippets(markTerms(hit.Title, terms), markTerms(description, terms), markTerms(notes, terms))
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
+
+// snippetContext is how many characters markTerms keeps on either side of
+// the first match.
+const snippetContext = 60
+
+// markTerms wraps every occurrence of the terms in highlight markers and
+// trims long text to a window around the first one. It returns "" when no
+// term occurs.
+func markTerms(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	// lowercasing can change byte lengths outside ASCII, in which case
+	// offsets into lower do not line up with text
+	if len(lower) != len(text) {
+		return ""
+	}
+
+	marked := make([]bool, len(text))
+	first := -1
+	for _, term := range terms {
+		for i := 0; ; {
+			j := strings.Index(lower[i:], term)
+			if j < 0 {
+				break
+			}
+			for k := i + j; k < i+j+len(term); k++ {
+				marked[k] = true
+			}
+			if first < 0 || i+j < first {
+				first = i + j
+			}
+			i += j + len(term)
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	start, end := 0, len(text)
+	if start < first-snippetContext {
+		start = first - snippetContext
+		for start < len(text) && !utf8.RuneStart(text[start]) {
+			start++
+		}
+	}
+	if end > first+2*snippetContext {
+		end = first + 2*snippetContext
+		for end > start && !utf8.RuneStart(text[end]) {
+			end--
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	for i := start; i < end; i++ {
+		if marked[i] && (i == start || !marked[i-1]) {
+			b.WriteString(highlightStart)
+		}
+		b.WriteByte(text[i])
+		if marked[i] && (i+1 == end || !marked[i+1]) {
+			b.WriteString(highlightStop)
+		}
+	}
+	if end < len(text) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+// snippets turns the highlighted title, description and notes into escaped
+// snippets, skipping fields without a match.
+func snippets(title, description, notes string) []SearchSnippet {
+	out := []SearchSnippet{}
+	for _, field := range []struct {
+		name string
+		text string
+	}{{"title", title}, {"description", description}, {"notes", notes}} {
+		if !strings.Contains(field.text, highlightStart) {
+			continue
+		}
+		text := html.EscapeString(field.text)
+		text = strings.ReplaceAll(text, highlightStart, "<mark>")
+		text = strings.ReplaceAll(text, highlightStop, "</mark>")
+		out = append(out, SearchSnippet{Field: field.name, Text: text})
+	}
+	return out
+}
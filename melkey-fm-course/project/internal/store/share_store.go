@@ -0,0 +1,172 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/tokens"
+)
+
+// ShareLink grants read-only access to one workout to anyone holding the
+// token. Only the hash is stored, so the token is shown once on creation.
+type ShareLink struct {
+	ID           int64      `json:"id"`
+	WorkoutID    int64      `json:"workout_id"`
+	UserID       int64      `json:"-"`
+	Token        string     `json:"token,omitempty"`
+	Hash         []byte     `json:"-"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	ViewCount    int        `json:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// SharedWorkout is what a share link resolves to.
+type SharedWorkout struct {
+	Workout  *Workout
+	Username string
+	// Timezone is the owner's, so the page shows the time they trained at.
+	Timezone string
+	Link     *ShareLink
+}
+
+type PostgresShareStore struct {
+	db *sql.DB
+}
+
+func NewPostgresShareStore(db *sql.DB) *PostgresShareStore {
+	return &PostgresShareStore{db: db}
+}
+
+type ShareStore interface {
+	CreateShareLink(workoutID, userID int64, expiresAt *time.Time) (*ShareLink, error)
+	GetSharedWorkout(token string) (*SharedWorkout, error)
+	ListShareLinks(userID int64) ([]ShareLink, error)
+	RevokeShareLink(id, userID int64) error
+}
+
+func (pg *PostgresShareStore) CreateShareLink(workoutID, userID int64, expiresAt *time.Time) (*ShareLink, error) {
+	token, err := tokens.GenerateToken(userID, 0, tokens.ScopeShare)
+	if err != nil {
+		return nil, err
+	}
+
+	link := &ShareLink{
+		WorkoutID: workoutID,
+		UserID:    userID,
+		Token:     token.Plaintext,
+		Hash:      token.Hash,
+		ExpiresAt: expiresAt,
+	}
+
+	query := `
+  INSERT INTO share_links (workout_id, user_id, hash, expires_at)
+  VALUES ($1, $2, $3, $4)
+  RETURNING id, created_at
+  `
+	err = pg.db.QueryRow(query, link.WorkoutID, link.UserID, link.Hash, link.ExpiresAt).Scan(&link.ID, &link.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+// GetSharedWorkout resolves a token that is neither revoked nor expired and
+// counts the view. It returns nil when the token is not usable or the
+// workout is in the trash.
+func (pg *PostgresShareStore) GetSharedWorkout(token string) (*SharedWorkout, error) {
+	hash := sha256.Sum256([]byte(token))
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	link := &ShareLink{}
+	query := `
+  UPDATE share_links
+  SET view_count = view_count + 1, last_viewed_at = CURRENT_TIMESTAMP
+  WHERE hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+  RETURNING id, workout_id, user_id, expires_at, view_count, last_viewed_at, created_at
+  `
+	err = tx.QueryRow(query, hash[:]).Scan(&link.ID, &link.WorkoutID, &link.UserID, &link.ExpiresAt, &link.ViewCount, &link.LastViewedAt, &link.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	workout, err := getWorkout(tx, link.WorkoutID, false)
+	if err != nil {
+		return nil, err
+	}
+	if workout == nil {
+		return nil, nil
+	}
+
+	shared := &SharedWorkout{Workout: workout, Link: link}
+	err = tx.QueryRow(`SELECT username, timezone FROM users WHERE id = $1`, link.UserID).Scan(&shared.Username, &shared.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return shared, tx.Commit()
+}
+
+// ListShareLinks returns the user's links that still work, newest first.
+func (pg *PostgresShareStore) ListShareLinks(userID int64) ([]ShareLink, error) {
+	query := `
+  SELECT s.id, s.workout_id, s.expires_at, s.view_count, s.last_viewed_at, s.created_at
+  FROM share_links s
+  JOIN workouts w ON w.id = s.workout_id
+  WHERE s.user_id = $1 AND s.revoked_at IS NULL
+    AND (s.expires_at IS NULL OR s.expires_at > CURRENT_TIMESTAMP)
+    AND w.deleted_at IS NULL
+  ORDER BY s.created_at DESC
+  `
+
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []ShareLink{}
+	for rows.Next() {
+		link := ShareLink{UserID: userID}
+		err = rows.Scan(&link.ID, &link.WorkoutID, &link.ExpiresAt, &link.ViewCount, &link.LastViewedAt, &link.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+// RevokeShareLink returns sql.ErrNoRows when the user has no such link that
+// is still active.
+func (pg *PostgresShareStore) RevokeShareLink(id, userID int64) error {
+	query := `
+  UPDATE share_links
+  SET revoked_at = CURRENT_TIMESTAMP
+  WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+  `
+	result, err := pg.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+type StatsPeriod string
+
+const (
+	StatsPeriodDay   StatsPeriod = "day"
+	StatsPeriodWeek  StatsPeriod = "week"
+	StatsPeriodMonth StatsPeriod = "month"
+)
+
+func (p StatsPeriod) Valid() bool {
+	switch p {
+	case StatsPeriodDay, StatsPeriodWeek, StatsPeriodMonth:
+		return true
+	}
+	return false
+}
+
+type StatsFilter struct {
+	UserID   int64
+	Period   StatsPeriod
+	Location *time.Location
+	From     *time.Time
+	To       *time.Time
+}
+
+type PeriodStats struct {
+	PeriodStart     time.Time `json:"period_start"`
+	Sessions        int       `json:"sessions"`
+	DurationSeconds int       `json:"total_duration_seconds"`
+	CaloriesBurned  int       `json:"total_calories_burned"`
+	Sets            int       `json:"total_sets"`
+	Tonnage         float64   `json:"tonnage"`
+	TonnageChange   *float64  `json:"tonnage_change"`
+}
+
+type ExerciseTrendPoint struct {
+	PeriodStart      time.Time `json:"period_start"`
+	Sets             int       `json:"sets"`
+	Reps             int       `json:"reps"`
+	MaxWeight        *float64  `json:"max_weight"`
+	Tonnage          float64   `json:"tonnage"`
+	TonnageMovingAvg float64   `json:"tonnage_moving_avg"`
+	TonnageChange    *float64  `json:"tonnage_change"`
+}
+
+type ExerciseTrend struct {
+	ExerciseName string               `json:"exercise_name"`
+	Points       []ExerciseTrendPoint `json:"points"`
+}
+
+type TrainingStats struct {
+	Period          StatsPeriod     `json:"period"`
+	Timezone        string          `json:"timezone"`
+	TotalSessions   int             `json:"total_sessions"`
+	SessionsPerWeek float64         `json:"sessions_per_week"`
+	Periods         []PeriodStats   `json:"periods"`
+	Exercises       []ExerciseTrend `json:"exercises"`
+}
+
+type PostgresStatsStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStatsStore(db *sql.DB) *PostgresStatsStore {
+	return &PostgresStatsStore{db: db}
+}
+
+type StatsStore interface {
+	GetTrainingStats(filter StatsFilter) (*TrainingStats, error)
+}
+
+func (pg *PostgresStatsStore) GetTrainingStats(filter StatsFilter) (*TrainingStats, error) {
+	if filter.Location == nil {
+		filter.Location = time.UTC
+	}
+
+	stats := &TrainingStats{
+		Period:    filter.Period,
+		Timezone:  filter.Location.String(),
+		Periods:   []PeriodStats{},
+		Exercises: []ExerciseTrend{},
+	}
+
+	args := []interface{}{string(filter.Period), filter.Location.String(), filter.UserID, filter.From, filter.To}
+
+	periods, err := pg.periodStats(args)
+	if err != nil {
+		return nil, err
+	}
+	stats.Periods = periods
+
+	exercises, err := pg.exerciseTrends(args)
+	if err != nil {
+		return nil, err
+	}
+	stats.Exercises = exercises
+
+	var first, last sql.NullTime
+	query := `
+  SELECT COUNT(*), MIN(w.performed_at), MAX(w.performed_at)
+  FROM workouts w
+  WHERE w.scheduled_at IS NULL AND w.deleted_at IS NULL
+    AND w.user_id = $1
+    AND ($2::timestamptz IS NULL OR w.performed_at >= $2)
+    AND ($3::timestamptz IS NULL OR w.performed_at < $3)
+  `
+	err = pg.db.QueryRow(query, filter.UserID, filter.From, filter.To).Scan(&stats.TotalSessions, &first, &last)
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.TotalSessions > 0 {
+		weeks := last.Time.Sub(first.Time).Hours() / (24 * 7)
+		if weeks < 1 {
+			weeks = 1
+		}
+		stats.SessionsPerWeek = float64(stats.TotalSessions) / weeks
+	}
+
+	for i := range stats.Periods {
+		stats.Periods[i].PeriodStart = stats.Periods[i].PeriodStart.In(filter.Location)
+	}
+	for i := range stats.Exercises {
+		for j := range stats.Exercises[i].Points {
+			point := &stats.Exercises[i].Points[j]
+			point.PeriodStart = point.PeriodStart.In(filter.Location)
+		}
+	}
+
+	return stats, nil
+}
+
+// periodStats expects args in the order period, timezone, user id, from, to.
+func (pg *PostgresStatsStore) periodStats(args []interface{}) ([]PeriodStats, error) {
+	query := `
+  WITH entry_totals AS (
+    SELECT we.workout_id,
+      SUM(v.sets * COALESCE(g.rounds, 1)) AS total_sets,
+      SUM(v.tonnage * COALESCE(g.rounds, 1)) AS tonnage
+    FROM workout_entries we
+    JOIN workout_entry_volume v ON v.entry_id = we.id
+    LEFT JOIN workout_entry_groups g ON g.id = we.group_id
+    WHERE we.deleted_at IS NULL
+    GROUP BY we.workout_id
+  ),
+  buckets AS (
+    SELECT date_trunc($1, w.performed_at AT TIME ZONE $2) AS bucket,
+      COUNT(*) AS sessions,
+      SUM(w.duration_seconds)::bigint AS duration_seconds,
+      COALESCE(SUM(w.calories_burned), 0) AS calories_burned,
+      COALESCE(SUM(et.total_sets), 0) AS total_sets,
+      COALESCE(SUM(et.tonnage), 0) AS tonnage
+    FROM workouts w
+    LEFT JOIN entry_totals et ON et.workout_id = w.id
+    WHERE w.scheduled_at IS NULL AND w.deleted_at IS NULL
+      AND w.user_id = $3
+      AND ($4::timestamptz IS NULL OR w.performed_at >= $4)
+      AND ($5::timestamptz IS NULL OR w.performed_at < $5)
+    GROUP BY 1
+  )
+  SELECT bucket AT TIME ZONE $2, sessions, duration_seconds, calories_burned, total_sets, tonnage,
+    tonnage - LAG(tonnage) OVER (ORDER BY bucket)
+  FROM buckets
+  ORDER BY bucket
+  `
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := []PeriodStats{}
+	for rows.Next() {
+		var p PeriodStats
+		var change sql.NullFloat64
+		err = rows.Scan(&p.PeriodStart, &p.Sessions, &p.DurationSeconds, &p.CaloriesBurned, &p.Sets, &p.Tonnage, &change)
+		if err != nil {
+			return nil, err
+		}
+		if change.Valid {
+			p.TonnageChange = &change.Float64
+		}
+		periods = append(periods, p)
+	}
+
+	return periods, rows.Err()
+}
+
+// exerciseTrends expects args in the order period, timezone, user id, from, to.
+// The moving average covers the current and two preceding periods.
+func (pg *PostgresStatsStore) exerciseTrends(args []interface{}) ([]ExerciseTrend, error) {
+	query := `
+  WITH points AS (
+    SELECT we.exercise_name,
+      date_trunc($1, w.performed_at AT TIME ZONE $2) AS bucket,
+      SUM(v.sets * COALESCE(g.rounds, 1)) AS sets,
+      SUM(v.reps * COALESCE(g.rounds, 1)) AS reps,
+      MAX(we.weight) AS max_weight,
+      SUM(v.tonnage * COALESCE(g.rounds, 1)) AS tonnage
+    FROM workout_entries we
+    JOIN workouts w ON w.id = we.workout_id AND we.deleted_at IS NULL
+    JOIN workout_entry_volume v ON v.entry_id = we.id
+    LEFT JOIN workout_entry_groups g ON g.id = we.group_id
+    WHERE w.scheduled_at IS NULL AND w.deleted_at IS NULL
+      AND w.user_id = $3
+      AND ($4::timestamptz IS NULL OR w.performed_at >= $4)
+      AND ($5::timestamptz IS NULL OR w.performed_at < $5)
+    GROUP BY 1, 2
+  )
+  SELECT exercise_name, bucket AT TIME ZONE $2, sets, reps, max_weight, tonnage,
+    AVG(tonnage) OVER (
+      PARTITION BY exercise_name ORDER BY bucket
+      ROWS BETWEEN 2 PRECEDING AND CURRENT ROW
+    ),
+    tonnage - LAG(tonnage) OVER (PARTITION BY exercise_name ORDER BY bucket)
+  FROM points
+  ORDER BY exercise_name, bucket
+  `
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trends := []ExerciseTrend{}
+	for rows.Next() {
+		var name string
+		var p ExerciseTrendPoint
+		var maxWeight, change sql.NullFloat64
+		err = rows.Scan(&name, &p.PeriodStart, &p.Sets, &p.Reps, &maxWeight, &p.Tonnage, &p.TonnageMovingAvg, &change)
+		if err != nil {
+			return nil, err
+		}
+		if maxWeight.Valid {
+			p.MaxWeight = &maxWeight.Float64
+		}
+		if change.Valid {
+			p.TonnageChange = &change.Float64
+		}
+
+		if len(trends) == 0 || trends[len(trends)-1].ExerciseName != name {
+			trends = append(trends, ExerciseTrend{ExerciseName: name})
+		}
+		last := &trends[len(trends)-1]
+		last.Points = append(last.Points, p)
+	}
+
+	return trends, rows.Err()
+}
@@ -0,0 +1,255 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+const maxTagLength = 50
+
+var (
+	ErrInvalidTag = errors.New("tag names must be between 1 and 50 characters")
+	ErrTagExists  = errors.New("a tag with that name already exists")
+)
+
+type Tag struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	WorkoutCount int       `json:"workout_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// NormalizeTagName trims and collapses whitespace. Tags are unique per user
+// regardless of case, but keep the casing they were first created with.
+func NormalizeTagName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || len([]rune(name)) > maxTagLength {
+		return "", ErrInvalidTag
+	}
+	return name, nil
+}
+
+type PostgresTagStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTagStore(db *sql.DB) *PostgresTagStore {
+	return &PostgresTagStore{db: db}
+}
+
+type TagStore interface {
+	ListTags(userID int64) ([]Tag, error)
+	CreateTag(userID int64, name string) (*Tag, error)
+	RenameTag(id, userID int64, name string) (*Tag, error)
+	DeleteTag(id, userID int64) error
+}
+
+// ListTags returns the user's tags with the number of live workouts using
+// each one.
+func (pg *PostgresTagStore) ListTags(userID int64) ([]Tag, error) {
+	query := `
+  SELECT t.id, t.name, COUNT(w.id), t.created_at
+  FROM tags t
+  LEFT JOIN workout_tags wt ON wt.tag_id = t.id
+  LEFT JOIN workouts w ON w.id = wt.workout_id AND w.deleted_at IS NULL
+  WHERE t.user_id = $1
+  GROUP BY t.id
+  ORDER BY lower(t.name)
+  `
+
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		err = rows.Scan(&tag.ID, &tag.Name, &tag.WorkoutCount, &tag.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// CreateTag returns the existing tag when the user already has one with the
+// same name.
+func (pg *PostgresTagStore) CreateTag(userID int64, name string) (*Tag, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids, err := upsertTags(tx, userID, []string{name})
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &Tag{}
+	query := `
+  SELECT t.id, t.name, (SELECT COUNT(*) FROM workout_tags wt JOIN workouts w ON w.id = wt.workout_id
+    WHERE wt.tag_id = t.id AND w.deleted_at IS NULL), t.created_at
+  FROM tags t
+  WHERE t.id = $1
+  `
+	err = tx.QueryRow(query, ids[0]).Scan(&tag.ID, &tag.Name, &tag.WorkoutCount, &tag.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return tag, tx.Commit()
+}
+
+// RenameTag returns sql.ErrNoRows when the tag does not belong to the user
+// and ErrTagExists when the new name is taken by another of their tags.
+func (pg *PostgresTagStore) RenameTag(id, userID int64, name string) (*Tag, error) {
+	name, err := NormalizeTagName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &Tag{}
+	query := `
+  WITH touched AS (
+    UPDATE workouts SET updated_at = CURRENT_TIMESTAMP
+    WHERE user_id = $3 AND id IN (SELECT workout_id FROM workout_tags WHERE tag_id = $2)
+  ), renamed AS (
+    UPDATE tags
+    SET name = $1
+    WHERE id = $2 AND user_id = $3
+    RETURNING id, name, created_at
+  )
+  SELECT r.id, r.name, (SELECT COUNT(*) FROM workout_tags wt JOIN workouts w ON w.id = wt.workout_id
+    WHERE wt.tag_id = r.id AND w.deleted_at IS NULL), r.created_at
+  FROM renamed r
+  `
+	err = pg.db.QueryRow(query, name, id, userID).Scan(&tag.ID, &tag.Name, &tag.WorkoutCount, &tag.CreatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return nil, ErrTagExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// DeleteTag removes the tag from every workout. It returns sql.ErrNoRows when
+// the tag does not belong to the user.
+func (pg *PostgresTagStore) DeleteTag(id, userID int64) error {
+	// the workouts lose the tag, so they count as changed
+	query := `
+  WITH touched AS (
+    UPDATE workouts SET updated_at = CURRENT_TIMESTAMP
+    WHERE user_id = $2 AND id IN (SELECT workout_id FROM workout_tags WHERE tag_id = $1)
+  )
+  DELETE FROM tags
+  WHERE id = $1 AND user_id = $2
+  `
+	result, err := pg.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// upsertTags makes sure the user has a tag for every name and returns their
+// ids in the same order.
+func upsertTags(tx *sql.Tx, userID int64, names []string) ([]int64, error) {
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		name, err := NormalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+
+		var id int64
+		query := `
+    INSERT INTO tags (user_id, name)
+    VALUES ($1, $2)
+    ON CONFLICT (user_id, lower(name)) DO UPDATE SET name = tags.name
+    RETURNING id
+    `
+		err = tx.QueryRow(query, userID, name).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// replaceWorkoutTags sets a workout's tags to exactly names, creating any
+// tags the owner does not have yet.
+func replaceWorkoutTags(tx *sql.Tx, workout *Workout) error {
+	if workout.UserID == nil {
+		if len(workout.Tags) > 0 {
+			return errors.New("workouts without an owner cannot be tagged")
+		}
+		return nil
+	}
+
+	ids, err := upsertTags(tx, *workout.UserID, workout.Tags)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM workout_tags WHERE workout_id = $1`, workout.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		_, err = tx.Exec(`INSERT INTO workout_tags (workout_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, workout.ID, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadWorkoutTags(q querier, workoutID int64) ([]string, error) {
+	query := `
+  SELECT t.name
+  FROM tags t
+  JOIN workout_tags wt ON wt.tag_id = t.id
+  WHERE wt.workout_id = $1
+  ORDER BY lower(t.name)
+  `
+
+	rows, err := q.Query(query, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+
+	return tags, rows.Err()
+}
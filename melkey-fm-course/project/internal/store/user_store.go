@@ -0,0 +1,219 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/units"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type password struct {
+	plainText *string
+	hash      []byte
+}
+
+func (p *password) Set(plaintextPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintextPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	p.plainText = &plaintextPassword
+	p.hash = hash
+	return nil
+}
+
+func (p *password) Matches(plaintextPassword string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintextPassword))
+	if err != nil {
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+type User struct {
+	ID           int64    `json:"id"`
+	Username     string   `json:"username"`
+	Email        string   `json:"email"`
+	PasswordHash password `json:"-"`
+	Bio          string   `json:"bio"`
+	// IsPrivate makes new followers wait for approval.
+	IsPrivate bool `json:"is_private"`
+	// Units is how weights and distances are shown to and read from this
+	// user. They are always stored in kilograms and metres.
+	Units units.Preference `json:"units"`
+	// Timezone is an IANA name. Days, weeks and months are counted in it.
+	Timezone  string    `json:"timezone"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var AnonymousUser = &User{}
+
+// Location is the user's timezone, or UTC when it is unset or unknown.
+func (u *User) Location() *time.Location {
+	return Location(u.Timezone)
+}
+
+// Location loads a stored timezone name, falling back to UTC.
+func Location(timezone string) *time.Location {
+	if timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
+
+type PostgresUserStore struct {
+	db *sql.DB
+}
+
+func NewPostgresUserStore(db *sql.DB) *PostgresUserStore {
+	return &PostgresUserStore{db: db}
+}
+
+type UserStore interface {
+	CreateUser(*User) error
+	GetUserByUsername(username string) (*User, error)
+	GetUserToken(scope, tokenPlaintext string) (*User, error)
+	UpdateUser(*User) error
+}
+
+func (s *PostgresUserStore) CreateUser(user *User) error {
+	query := `
+  INSERT INTO users (username, email, password_hash, bio, is_private, weight_unit, distance_unit, weight_increment, timezone)
+  VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, ''), 'kg'), COALESCE(NULLIF($7, ''), 'km'), $8, COALESCE(NULLIF($9, ''), 'UTC'))
+  RETURNING id, timezone, created_at, updated_at
+  `
+
+	err := s.db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.Bio, user.IsPrivate,
+		string(user.Units.Weight), string(user.Units.Distance), user.Units.WeightIncrement, user.Timezone).Scan(&user.ID, &user.Timezone, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
+	user := &User{
+		PasswordHash: password{},
+	}
+
+	query := `
+  SELECT id, username, email, password_hash, COALESCE(bio, ''), is_private,
+    weight_unit, distance_unit, weight_increment, timezone, created_at, updated_at
+  FROM users
+  WHERE username = $1
+  `
+
+	err := s.db.QueryRow(query, username).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.PasswordHash.hash,
+		&user.Bio,
+		&user.IsPrivate,
+		&user.Units.Weight,
+		&user.Units.Distance,
+		&user.Units.WeightIncrement,
+		&user.Timezone,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *PostgresUserStore) GetUserToken(scope, plaintextPassword string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(plaintextPassword))
+
+	query := `
+  SELECT u.id, u.username, u.email, u.password_hash, COALESCE(u.bio, ''), u.is_private,
+    u.weight_unit, u.distance_unit, u.weight_increment, u.timezone, u.created_at, u.updated_at
+  FROM users u
+  INNER JOIN tokens t ON t.user_id = u.id
+  WHERE t.hash = $1 AND t.scope = $2 AND t.expiry > $3
+  `
+
+	user := &User{
+		PasswordHash: password{},
+	}
+
+	err := s.db.QueryRow(query, tokenHash[:], scope, time.Now()).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.PasswordHash.hash,
+		&user.Bio,
+		&user.IsPrivate,
+		&user.Units.Weight,
+		&user.Units.Distance,
+		&user.Units.WeightIncrement,
+		&user.Timezone,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UpdateUser saves the profile fields of an existing user. Making a profile
+// public accepts every follow request still waiting on it.
+func (s *PostgresUserStore) UpdateUser(user *User) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+  UPDATE users
+  SET bio = $1, is_private = $2, weight_unit = $3, distance_unit = $4, weight_increment = $5,
+    timezone = $6, updated_at = CURRENT_TIMESTAMP
+  WHERE id = $7
+  RETURNING updated_at
+  `
+	err = tx.QueryRow(query, user.Bio, user.IsPrivate, string(user.Units.Weight), string(user.Units.Distance), user.Units.WeightIncrement,
+		user.Timezone, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if !user.IsPrivate {
+		_, err = tx.Exec(`UPDATE follows SET status = 'accepted', accepted_at = CURRENT_TIMESTAMP WHERE followee_id = $1 AND status = 'pending'`, user.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
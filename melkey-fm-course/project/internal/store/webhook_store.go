@@ -0,0 +1,338 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+type WebhookEvent string
+
+const (
+	WebhookWorkoutCreated WebhookEvent = TopicWorkoutCreated
+	WebhookWorkoutUpdated WebhookEvent = TopicWorkoutUpdated
+	WebhookWorkoutDeleted WebhookEvent = TopicWorkoutDeleted
+)
+
+var WebhookEvents = []WebhookEvent{WebhookWorkoutCreated, WebhookWorkoutUpdated, WebhookWorkoutDeleted}
+
+func (e WebhookEvent) Valid() bool {
+	switch e {
+	case WebhookWorkoutCreated, WebhookWorkoutUpdated, WebhookWorkoutDeleted:
+		return true
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookPending   WebhookDeliveryStatus = "pending"
+	WebhookSucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDead marks a delivery that ran out of attempts. It stays in the
+	// log until it is retried by hand.
+	WebhookDead WebhookDeliveryStatus = "dead"
+)
+
+func (s WebhookDeliveryStatus) Valid() bool {
+	switch s {
+	case WebhookPending, WebhookSucceeded, WebhookDead:
+		return true
+	}
+	return false
+}
+
+// WebhookEndpoint receives the events it subscribes to for its owner's
+// workouts. Secret is only returned when the endpoint is created.
+type WebhookEndpoint struct {
+	ID        int64          `json:"id"`
+	UserID    int64          `json:"-"`
+	URL       string         `json:"url"`
+	Secret    string         `json:"secret,omitempty"`
+	Events    []WebhookEvent `json:"events"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// WebhookEventPayload is the body of every delivery.
+type WebhookEventPayload struct {
+	ID        string       `json:"id"`
+	Type      WebhookEvent `json:"type"`
+	CreatedAt time.Time    `json:"created_at"`
+	Data      struct {
+		Workout *Workout `json:"workout"`
+	} `json:"data"`
+}
+
+// WebhookDelivery is one event queued for one endpoint.
+type WebhookDelivery struct {
+	ID         int64                 `json:"id"`
+	EndpointID int64                 `json:"endpoint_id"`
+	EventID    string                `json:"event_id"`
+	EventType  WebhookEvent          `json:"event_type"`
+	Payload    json.RawMessage       `json:"-"`
+	Status     WebhookDeliveryStatus `json:"status"`
+	Attempts   int                   `json:"attempts"`
+	// NextAttemptAt is only set while the delivery is pending.
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus *int       `json:"response_status"`
+	LastError      *string    `json:"last_error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	// URL and Secret come from the endpoint when a delivery is claimed.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookAttempt is the outcome of sending a claimed delivery. Status is
+// pending when it should be retried at NextAttemptAt.
+type WebhookAttempt struct {
+	Status         WebhookDeliveryStatus
+	ResponseStatus *int
+	Error          string
+	NextAttemptAt  time.Time
+}
+
+type PostgresWebhookStore struct {
+	db *sql.DB
+}
+
+func NewPostgresWebhookStore(db *sql.DB) *PostgresWebhookStore {
+	return &PostgresWebhookStore{db: db}
+}
+
+type WebhookStore interface {
+	CreateWebhookEndpoint(endpoint *WebhookEndpoint) error
+	ListWebhookEndpoints(userID int64) ([]WebhookEndpoint, error)
+	DeleteWebhookEndpoint(id, userID int64) error
+	ListWebhookDeliveries(endpointID, userID int64, status WebhookDeliveryStatus, limit int) ([]WebhookDelivery, error)
+	RetryWebhookDelivery(id, userID int64) error
+	// ClaimWebhookDeliveries hands out up to limit due deliveries and pushes
+	// them lease into the future, so other workers skip them while they are
+	// being sent and they come back if the worker dies.
+	ClaimWebhookDeliveries(limit int, lease time.Duration) ([]WebhookDelivery, error)
+	RecordWebhookAttempt(id int64, attempt WebhookAttempt) error
+	// EnqueueWebhookEvent queues a workout event for every endpoint of the
+	// workout's owner that subscribes to it. Queuing the same event twice is
+	// a no-op.
+	EnqueueWebhookEvent(event OutboxEvent) error
+}
+
+// CreateWebhookEndpoint stores the endpoint with a freshly generated secret.
+func (pg *PostgresWebhookStore) CreateWebhookEndpoint(endpoint *WebhookEndpoint) error {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return err
+	}
+	endpoint.Secret = "whsec_" + hex.EncodeToString(secret)
+
+	events := make([]string, len(endpoint.Events))
+	for i, event := range endpoint.Events {
+		events[i] = string(event)
+	}
+
+	query := `
+  INSERT INTO webhook_endpoints (user_id, url, secret, events)
+  VALUES ($1, $2, $3, $4)
+  RETURNING id, created_at
+  `
+	return pg.db.QueryRow(query, endpoint.UserID, endpoint.URL, endpoint.Secret, events).Scan(&endpoint.ID, &endpoint.CreatedAt)
+}
+
+func (pg *PostgresWebhookStore) ListWebhookEndpoints(userID int64) ([]WebhookEndpoint, error) {
+	query := `
+  SELECT id, user_id, url, array_to_json(events), created_at
+  FROM webhook_endpoints
+  WHERE user_id = $1
+  ORDER BY id
+  `
+
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := []WebhookEndpoint{}
+	for rows.Next() {
+		var endpoint WebhookEndpoint
+		var events []byte
+		err = rows.Scan(&endpoint.ID, &endpoint.UserID, &endpoint.URL, &events, &endpoint.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(events, &endpoint.Events)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, rows.Err()
+}
+
+// DeleteWebhookEndpoint drops the endpoint together with its delivery log.
+// It returns sql.ErrNoRows when the user has no such endpoint.
+func (pg *PostgresWebhookStore) DeleteWebhookEndpoint(id, userID int64) error {
+	result, err := pg.db.Exec(`DELETE FROM webhook_endpoints WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the newest deliveries to one of the user's
+// endpoints, optionally only those with status. It returns nil when the user
+// has no such endpoint.
+func (pg *PostgresWebhookStore) ListWebhookDeliveries(endpointID, userID int64, status WebhookDeliveryStatus, limit int) ([]WebhookDelivery, error) {
+	var exists bool
+	err := pg.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM webhook_endpoints WHERE id = $1 AND user_id = $2)`, endpointID, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	query := `
+  SELECT id, endpoint_id, event_id, event_type, status, attempts,
+    CASE WHEN status = 'pending' THEN next_attempt_at END,
+    last_attempt_at, response_status, last_error, created_at
+  FROM webhook_deliveries
+  WHERE endpoint_id = $1 AND ($2 = '' OR status = $2)
+  ORDER BY id DESC
+  LIMIT $3
+  `
+
+	rows, err := pg.db.Query(query, endpointID, string(status), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		err = rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.LastAttemptAt, &d.ResponseStatus, &d.LastError, &d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RetryWebhookDelivery moves a dead delivery back to pending with a fresh set
+// of attempts. It returns sql.ErrNoRows when the user has no such dead
+// delivery.
+func (pg *PostgresWebhookStore) RetryWebhookDelivery(id, userID int64) error {
+	query := `
+  UPDATE webhook_deliveries d
+  SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP
+  FROM webhook_endpoints e
+  WHERE d.id = $1 AND d.status = 'dead' AND e.id = d.endpoint_id AND e.user_id = $2
+  `
+	result, err := pg.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (pg *PostgresWebhookStore) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	query := `
+  UPDATE webhook_deliveries d
+  SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+  FROM webhook_endpoints e
+  WHERE e.id = d.endpoint_id AND d.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+    ORDER BY next_attempt_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+  )
+  RETURNING d.id, d.endpoint_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.created_at, e.url, e.secret
+  `
+
+	rows, err := pg.db.Query(query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		err = rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.CreatedAt, &d.URL, &d.Secret)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (pg *PostgresWebhookStore) RecordWebhookAttempt(id int64, attempt WebhookAttempt) error {
+	nextAttemptAt := attempt.NextAttemptAt
+	if attempt.Status != WebhookPending {
+		nextAttemptAt = time.Now()
+	}
+
+	query := `
+  UPDATE webhook_deliveries
+  SET status = $2, attempts = attempts + 1, last_attempt_at = CURRENT_TIMESTAMP,
+    response_status = $3, last_error = NULLIF($4, ''), next_attempt_at = $5
+  WHERE id = $1
+  `
+	_, err := pg.db.Exec(query, id, string(attempt.Status), attempt.ResponseStatus, attempt.Error, nextAttemptAt)
+	return err
+}
+
+func (pg *PostgresWebhookStore) EnqueueWebhookEvent(event OutboxEvent) error {
+	var data WorkoutEventPayload
+	err := json.Unmarshal(event.Payload, &data)
+	if err != nil {
+		return err
+	}
+	if data.Workout == nil || data.Workout.UserID == nil {
+		return nil
+	}
+
+	payload := WebhookEventPayload{ID: event.EventID, Type: WebhookEvent(event.Topic), CreatedAt: event.CreatedAt.UTC()}
+	payload.Data.Workout = data.Workout
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	query := `
+  INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload)
+  SELECT id, $2, $3, $4
+  FROM webhook_endpoints
+  WHERE user_id = $1 AND $3 = ANY(events)
+  ON CONFLICT (endpoint_id, event_id) DO NOTHING
+  `
+	_, err = pg.db.Exec(query, *data.Workout.UserID, event.EventID, event.Topic, body)
+	return err
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"database/sql"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/encoding/wkb"
+)
+
+type WorkoutRoute struct {
+	WorkoutID           int      `json:"workout_id"`
+	Sport               string   `json:"sport"`
+	DistanceMeters      float64  `json:"distance_meters"`
+	ElevationGainMeters float64  `json:"elevation_gain_meters"`
+	PaceSecondsPerKm    *float64 `json:"pace_seconds_per_km"`
+	// Distance and PaceSecondsPerUnit repeat the figures above in the
+	// caller's preferred distance unit. Handlers fill them in.
+	Distance           *float64       `json:"distance,omitempty"`
+	PaceSecondsPerUnit *float64       `json:"pace_seconds_per_unit,omitempty"`
+	Geometry           orb.LineString `json:"-"`
+}
+
+// CreateWorkoutWithRoute stores a workout and its recorded route in one
+// transaction.
+func (pg *PostgresWorkoutStore) CreateWorkoutWithRoute(workout *Workout, route *WorkoutRoute, actor Actor) (*Workout, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = insertWorkout(tx, workout)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+  INSERT INTO workout_routes (workout_id, sport, distance_meters, elevation_gain_meters, pace_seconds_per_km, geometry)
+  VALUES ($1, $2, $3, $4, $5, $6)
+  `
+	_, err = tx.Exec(query, workout.ID, route.Sport, route.DistanceMeters, route.ElevationGainMeters, route.PaceSecondsPerKm, wkb.Value(route.Geometry))
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordRevision(tx, int64(workout.ID), RevisionCreate, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	route.WorkoutID = workout.ID
+	workout.Route = route
+	return workout, nil
+}
+
+func (pg *PostgresWorkoutStore) GetWorkoutRoute(workoutID int64) (*WorkoutRoute, error) {
+	route := &WorkoutRoute{}
+	query := `
+  SELECT r.workout_id, COALESCE(r.sport, ''), r.distance_meters, r.elevation_gain_meters, r.pace_seconds_per_km, r.geometry
+  FROM workout_routes r
+  JOIN workouts w ON w.id = r.workout_id AND w.deleted_at IS NULL
+  WHERE r.workout_id = $1
+  `
+	err := pg.db.QueryRow(query, workoutID).Scan(
+		&route.WorkoutID,
+		&route.Sport,
+		&route.DistanceMeters,
+		&route.ElevationGainMeters,
+		&route.PaceSecondsPerKm,
+		wkb.Scanner(&route.Geometry),
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return route, nil
+}
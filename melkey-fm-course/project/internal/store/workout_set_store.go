@@ -0,0 +1,207 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type SetType string
+
+const (
+	SetWarmup  SetType = "warmup"
+	SetWorking SetType = "working"
+	SetDrop    SetType = "drop"
+	SetFailure SetType = "failure"
+)
+
+func (t SetType) Valid() bool {
+	switch t {
+	case SetWarmup, SetWorking, SetDrop, SetFailure:
+		return true
+	}
+	return false
+}
+
+// WorkoutSet is one logged set of an entry. SetNumber follows the order the
+// sets were sent in.
+type WorkoutSet struct {
+	ID          int      `json:"id"`
+	SetNumber   int      `json:"set_number"`
+	Type        SetType  `json:"set_type"`
+	Reps        *int     `json:"reps"`
+	Weight      *float64 `json:"weight"`
+	RPE         *float64 `json:"rpe"`
+	RIR         *int     `json:"rir"`
+	RestSeconds *int     `json:"rest_seconds"`
+}
+
+// Volume totals the working sets of a workout. Warmups are left out.
+type Volume struct {
+	Sets    int     `json:"sets"`
+	Reps    int     `json:"reps"`
+	Tonnage float64 `json:"tonnage"`
+}
+
+// UnmarshalJSON accepts "sets" either as a count, with Reps and Weight shared
+// by every set, or as an array of per-set details.
+func (e *WorkoutEntry) UnmarshalJSON(data []byte) error {
+	type plain WorkoutEntry
+	var aux struct {
+		plain
+		Sets json.RawMessage `json:"sets"`
+	}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	sets := bytes.TrimSpace(aux.Sets)
+	switch {
+	case len(sets) > 0 && sets[0] == '[':
+		err = json.Unmarshal(sets, &aux.plain.SetDetails)
+	case len(sets) > 0:
+		err = json.Unmarshal(sets, &aux.plain.Sets)
+	}
+	if err != nil {
+		return err
+	}
+
+	*e = WorkoutEntry(aux.plain)
+	return nil
+}
+
+// summariseSets numbers the detailed sets and fills the compact columns from
+// them, so readers that only know Sets, Reps and Weight still see the count,
+// the rep count when every set shares it and the heaviest weight lifted.
+func (e *WorkoutEntry) summariseSets() {
+	if len(e.SetDetails) == 0 {
+		return
+	}
+
+	e.Sets = len(e.SetDetails)
+	e.Reps, e.Weight = nil, nil
+	sameReps := true
+	for i := range e.SetDetails {
+		set := &e.SetDetails[i]
+		set.SetNumber = i + 1
+		if set.Type == "" {
+			set.Type = SetWorking
+		}
+
+		if set.Reps == nil || (e.Reps != nil && *e.Reps != *set.Reps) {
+			sameReps = false
+		}
+		if i == 0 && set.Reps != nil {
+			reps := *set.Reps
+			e.Reps = &reps
+		}
+		if set.Weight != nil && (e.Weight == nil || *set.Weight > *e.Weight) {
+			weight := *set.Weight
+			e.Weight = &weight
+		}
+	}
+	if !sameReps {
+		e.Reps = nil
+	}
+}
+
+// Volume totals the entry for a single round, using the working sets when
+// they were logged one by one. It matches the workout_entry_volume view.
+func (e *WorkoutEntry) Volume() Volume {
+	if len(e.SetDetails) == 0 {
+		v := Volume{Sets: e.Sets}
+		if e.Reps != nil {
+			v.Reps = e.Sets * *e.Reps
+			if e.Weight != nil {
+				v.Tonnage = float64(v.Reps) * *e.Weight
+			}
+		}
+		return v
+	}
+
+	var v Volume
+	for _, set := range e.SetDetails {
+		if set.Type == SetWarmup {
+			continue
+		}
+		v.Sets++
+		if set.Reps != nil {
+			v.Reps += *set.Reps
+			if set.Weight != nil {
+				v.Tonnage += float64(*set.Reps) * *set.Weight
+			}
+		}
+	}
+	return v
+}
+
+// TotalVolume adds up every entry of the workout, counting grouped entries
+// once per round.
+func (w *Workout) TotalVolume() *Volume {
+	total := &Volume{}
+	for _, entry := range w.FlatEntries() {
+		v := entry.Volume()
+		total.Sets += v.Sets * entry.Rounds
+		total.Reps += v.Reps * entry.Rounds
+		total.Tonnage += v.Tonnage * float64(entry.Rounds)
+	}
+	return total
+}
+
+func insertSets(tx *sql.Tx, entry *WorkoutEntry) error {
+	for i := range entry.SetDetails {
+		set := &entry.SetDetails[i]
+		query := `
+  INSERT INTO workout_sets (entry_id, set_number, set_type, reps, weight, rpe, rir, rest_seconds)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+  RETURNING id
+  `
+		err := tx.QueryRow(query, entry.ID, set.SetNumber, string(set.Type), set.Reps, set.Weight, set.RPE, set.RIR, set.RestSeconds).Scan(&set.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadSets attaches the per-set details of a workout's entries that share
+// deletedAt.
+func loadSets(q querier, workout *Workout, deletedAt *time.Time) error {
+	entries := map[int]*WorkoutEntry{}
+	for _, entry := range workout.FlatEntries() {
+		entries[entry.ID] = entry.WorkoutEntry
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	query := `
+  SELECT ws.entry_id, ws.id, ws.set_number, ws.set_type, ws.reps, ws.weight, ws.rpe, ws.rir, ws.rest_seconds
+  FROM workout_sets ws
+  JOIN workout_entries we ON we.id = ws.entry_id
+  WHERE we.workout_id = $1 AND we.deleted_at IS NOT DISTINCT FROM $2
+  ORDER BY ws.entry_id, ws.set_number
+  `
+	rows, err := q.Query(query, workout.ID, deletedAt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entryID int
+		var set WorkoutSet
+		err = rows.Scan(&entryID, &set.ID, &set.SetNumber, &set.Type, &set.Reps, &set.Weight, &set.RPE, &set.RIR, &set.RestSeconds)
+		if err != nil {
+			return err
+		}
+		if entry, ok := entries[entryID]; ok {
+			entry.SetDetails = append(entry.SetDetails, set)
+		}
+	}
+
+	return rows.Err()
+}
@@ -1,25 +1,81 @@
 package store
 
-import "database/sql"
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 type Workout struct {
-	ID              int            `json:"id"`
-	Title           string         `json:"title"`
-	Description     string         `json:"description"`
-	DurationMinutes int            `json:"duration_minutes"`
-	CaloriesBurned  int            `json:"calories_burned"`
-	Entries         []WorkoutEntry `json:"entries"`
+	ID              int    `json:"id"`
+	UserID          *int64 `json:"user_id"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	DurationSeconds int    `json:"duration_seconds"`
+	CaloriesBurned  *int   `json:"calories_burned"`
+	// CaloriesEstimated is set when CaloriesBurned was not entered by the
+	// user and has been filled in by an estimate instead.
+	CaloriesEstimated bool `json:"calories_estimated"`
+	// Entries are the entries outside any group. Entries and Groups are
+	// replaced together on update.
+	Entries []WorkoutEntry `json:"entries"`
+	Groups  []EntryGroup   `json:"groups"`
+	// Volume is set wherever the entries are loaded.
+	Volume *Volume `json:"volume,omitempty"`
+	// Tags are the owner's tag names. On update a nil slice leaves the tags
+	// untouched while an empty one clears them.
+	Tags  []string      `json:"tags"`
+	Route *WorkoutRoute `json:"route,omitempty"`
+	// ScheduledAt marks a planned workout; Recurrence optionally repeats it
+	// using an RRULE such as FREQ=WEEKLY;BYDAY=MO,TH.
+	ScheduledAt *time.Time `json:"scheduled_at"`
+	Recurrence  *string    `json:"recurrence"`
+	// PlannedWorkoutID and PlannedFor link a completed workout to the planned
+	// occurrence it fulfils.
+	PlannedWorkoutID *int64     `json:"planned_workout_id"`
+	PlannedFor       *time.Time `json:"planned_for"`
+	// Visibility decides who besides the owner can read the workout. New
+	// workouts default to followers for private profiles, public otherwise.
+	Visibility Visibility `json:"visibility"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	// Engagement is filled in by handlers that show it and is never part of
+	// a revision snapshot.
+	Engagement *Engagement `json:"engagement,omitempty"`
+	// PerformedAt is when the workout happened and decides which day, week
+	// and month it counts towards. It defaults to the time it was logged.
+	PerformedAt time.Time `json:"performed_at"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
+type Visibility string
+
+const (
+	VisibilityPrivate   Visibility = "private"
+	VisibilityFollowers Visibility = "followers"
+	VisibilityPublic    Visibility = "public"
+)
+
+func (v Visibility) Valid() bool {
+	return v == VisibilityPrivate || v == VisibilityFollowers || v == VisibilityPublic
+}
+
+// MaxWeightKg is the largest weight accepted for an entry or a set; it catches
+// typos well before the DECIMAL(7, 3) weight columns would overflow.
+const MaxWeightKg = 999.99
+
 type WorkoutEntry struct {
 	ID              int      `json:"id"`
 	ExerciseName    string   `json:"exercise_name"`
 	Sets            int      `json:"sets"`
 	Reps            *int     `json:"reps"`
-	DurationMinutes *int     `json:"duration_minutes"`
+	DurationSeconds *int     `json:"duration_seconds"`
 	Weight          *float64 `json:"weight"`
 	Notes           string   `json:"notes"`
 	OrderIndex      int      `json:"order_index"`
+	// SetDetails logs each set on its own. When present, Sets, Reps and
+	// Weight are derived from it.
+	SetDetails []WorkoutSet `json:"set_details,omitempty"`
 }
 
 type PostgresWorkoutStore struct {
@@ -30,53 +86,671 @@ func NewPostgresWorkoutStore(db *sql.DB) *PostgresWorkoutStore {
 	return &PostgresWorkoutStore{db: db}
 }
 
+// Every mutation takes the Actor performing it so a revision can be written
+// in the same transaction.
 type WorkoutStore interface {
-	CreateWorkout(*Workout) (*Workout, error)
+	CreateWorkout(workout *Workout, actor Actor) (*Workout, error)
 	GetWorkoutByID(id int64) (*Workout, error)
+	UpdateWorkout(workout *Workout, actor Actor) error
+	ImportWorkouts(workouts []*Workout, actor Actor) error
+	ExportWorkouts(userID int64, fn func(*Workout) error) error
+	CreateWorkoutWithRoute(workout *Workout, route *WorkoutRoute, actor Actor) (*Workout, error)
+	GetWorkoutRoute(workoutID int64) (*WorkoutRoute, error)
+	GetCalendarWorkouts(userID int64) ([]*Workout, error)
+	DeleteWorkout(id, userID int64, actor Actor) error
+	RestoreWorkout(id, userID int64, actor Actor) error
+	ListTrashedWorkouts(userID int64) ([]*Workout, error)
+	PurgeTrash(deletedBefore time.Time) (int64, error)
+	ListRevisions(workoutID, ownerID int64) ([]WorkoutRevision, error)
+	GetRevision(workoutID, ownerID int64, revision int) (*WorkoutRevision, error)
+	RevertWorkout(workoutID, ownerID int64, revision int, actor Actor) (*Workout, error)
+	ListWorkouts(filter WorkoutFilter) ([]*Workout, error)
+	WorkoutsLastModified(userID int64) (time.Time, error)
+	SetWorkoutTags(workoutID, userID int64, tags []string, actor Actor) (*Workout, error)
+}
+
+// TagMatch decides whether a workout needs every filter tag or just one.
+type TagMatch string
+
+const (
+	TagMatchAll TagMatch = "all"
+	TagMatchAny TagMatch = "any"
+)
+
+func (m TagMatch) Valid() bool {
+	return m == TagMatchAll || m == TagMatchAny
 }
 
-func (pg *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error) {
+type WorkoutFilter struct {
+	UserID int64
+	// Tags are compared case insensitively.
+	Tags   []string
+	Match  TagMatch
+	Limit  int
+	Offset int
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func (pg *PostgresWorkoutStore) CreateWorkout(workout *Workout, actor Actor) (*Workout, error) {
 	tx, err := pg.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	err = insertWorkout(tx, workout)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordRevision(tx, int64(workout.ID), RevisionCreate, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return workout, nil
+}
+
+// ImportWorkouts creates all workouts in a single transaction, so a failing
+// row leaves nothing behind.
+func (pg *PostgresWorkoutStore) ImportWorkouts(workouts []*Workout, actor Actor) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, workout := range workouts {
+		err = insertWorkout(tx, workout)
+		if err != nil {
+			return err
+		}
+
+		err = recordRevision(tx, int64(workout.ID), RevisionCreate, actor)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func insertWorkout(tx *sql.Tx, workout *Workout) error {
 	query :=
 		`
-  INSERT INTO workouts (user_id, title, description, duration_minutes, calories_burned)
-  VALUES ($1, $2, $3, $4, $5)
-  RETURNING id 
+  INSERT INTO workouts (user_id, title, description, duration_seconds, calories_burned, performed_at,
+    scheduled_at, recurrence, planned_workout_id, planned_for, visibility)
+  VALUES ($1, $2, $3, $4, $5, COALESCE($6, CURRENT_TIMESTAMP), $7, $8, $9, $10, COALESCE(NULLIF($11, ''),
+    (SELECT CASE WHEN is_private THEN 'followers' ELSE 'public' END FROM users WHERE id = $1), 'public'))
+  RETURNING id, performed_at, created_at, visibility
   `
 
-	err = tx.QueryRow(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned).Scan(&workout.ID)
+	err := tx.QueryRow(query, workout.UserID, workout.Title, workout.Description, workout.DurationSeconds, workout.CaloriesBurned, performedAt(workout),
+		workout.ScheduledAt, workout.Recurrence, workout.PlannedWorkoutID, workout.PlannedFor, string(workout.Visibility)).Scan(&workout.ID, &workout.PerformedAt, &workout.CreatedAt, &workout.Visibility)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// we also need to insert the entries
-	for _, entry := range workout.Entries {
-		query := `
-    INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
-    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-    RETURNING id
-    `
-		err = tx.QueryRow(query, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationMinutes, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+	err = insertEntries(tx, workout)
+	if err != nil {
+		return err
+	}
+
+	if len(workout.Tags) == 0 {
+		workout.Tags = []string{}
+		return nil
+	}
+	return replaceWorkoutTags(tx, workout)
+}
+
+// UpdateWorkout replaces a live workout's fields and entries. It returns
+// sql.ErrNoRows when the workout does not exist or is in the trash.
+func (pg *PostgresWorkoutStore) UpdateWorkout(workout *Workout, actor Actor) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = updateWorkout(tx, workout)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(tx, int64(workout.ID), RevisionUpdate, actor)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func updateWorkout(tx *sql.Tx, workout *Workout) error {
+	query := `
+  UPDATE workouts
+  SET title = $1, description = $2, duration_seconds = $3, calories_burned = $4,
+    scheduled_at = $5, recurrence = $6, planned_workout_id = $7, planned_for = $8,
+    visibility = COALESCE(NULLIF($10, ''), visibility), performed_at = COALESCE($11, performed_at),
+    updated_at = CURRENT_TIMESTAMP
+  WHERE id = $9 AND deleted_at IS NULL
+  `
+
+	// snapshots taken before visibility or performed_at existed leave them
+	// unchanged on revert
+	result, err := tx.Exec(query, workout.Title, workout.Description, workout.DurationSeconds, workout.CaloriesBurned,
+		workout.ScheduledAt, workout.Recurrence, workout.PlannedWorkoutID, workout.PlannedFor, workout.ID, string(workout.Visibility),
+		performedAt(workout))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	// the previous entries live on in the revision history
+	_, err = tx.Exec(`DELETE FROM workout_entries WHERE workout_id = $1 AND deleted_at IS NULL`, workout.ID)
+	if err != nil {
+		return err
+	}
+
+	err = deleteEmptyGroups(tx, workout.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertEntries(tx, workout)
+	if err != nil {
+		return err
+	}
+
+	if workout.Tags == nil {
+		return nil
+	}
+	return replaceWorkoutTags(tx, workout)
+}
+
+// performedAt is nil when the workout carries no time, leaving the column to
+// its default or current value.
+func performedAt(workout *Workout) *time.Time {
+	if workout.PerformedAt.IsZero() {
+		return nil
+	}
+	return &workout.PerformedAt
+}
+
+func insertEntries(tx *sql.Tx, workout *Workout) error {
+	for i := range workout.Entries {
+		err := insertEntry(tx, workout.ID, nil, &workout.Entries[i])
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	err = tx.Commit()
+	err := insertGroups(tx, workout)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return workout, nil
+	workout.Volume = workout.TotalVolume()
+	return nil
+}
+
+func insertEntry(tx *sql.Tx, workoutID int, groupID *int64, entry *WorkoutEntry) error {
+	query := `
+  INSERT INTO workout_entries (workout_id, group_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+  RETURNING id
+  `
+	entry.summariseSets()
+	err := tx.QueryRow(query, workoutID, groupID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+	if err != nil {
+		return err
+	}
+
+	return insertSets(tx, entry)
 }
 
 func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
+	return getWorkout(pg.db, id, false)
+}
+
+// getWorkout loads a workout with its entries, groups and route. Trashed workouts are
+// only returned when includeDeleted is set, together with the entries that
+// were trashed alongside them.
+func getWorkout(q querier, id int64, includeDeleted bool) (*Workout, error) {
 	workout := &Workout{}
+	query := `
+  SELECT w.id, w.user_id, w.title, COALESCE(w.description, ''), w.duration_seconds, w.calories_burned, w.performed_at, w.created_at,
+    w.scheduled_at, w.recurrence, w.planned_workout_id, w.planned_for, w.visibility, w.deleted_at,
+    r.workout_id, COALESCE(r.sport, ''), r.distance_meters, r.elevation_gain_meters, r.pace_seconds_per_km
+  FROM workouts w
+  LEFT JOIN workout_routes r ON r.workout_id = w.id
+  WHERE w.id = $1 AND ($2::boolean OR w.deleted_at IS NULL)
+  `
+	var routeWorkoutID sql.NullInt64
+	var distance, elevationGain sql.NullFloat64
+	route := &WorkoutRoute{}
+	err := q.QueryRow(query, id, includeDeleted).Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationSeconds, &workout.CaloriesBurned, &workout.PerformedAt, &workout.CreatedAt,
+		&workout.ScheduledAt, &workout.Recurrence, &workout.PlannedWorkoutID, &workout.PlannedFor, &workout.Visibility, &workout.DeletedAt,
+		&routeWorkoutID, &route.Sport, &distance, &elevationGain, &route.PaceSecondsPerKm)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if routeWorkoutID.Valid {
+		route.WorkoutID = int(routeWorkoutID.Int64)
+		route.DistanceMeters = distance.Float64
+		route.ElevationGainMeters = elevationGain.Float64
+		workout.Route = route
+	}
+
+	workout.Groups, err = loadGroups(q, workout.ID, workout.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	groupIndex := make(map[int64]int, len(workout.Groups))
+	for i, group := range workout.Groups {
+		groupIndex[int64(group.ID)] = i
+	}
+
+	entryQuery := `
+  SELECT id, group_id, exercise_name, sets, reps, duration_seconds, weight, COALESCE(notes, ''), order_index
+  FROM workout_entries
+  WHERE workout_id = $1 AND deleted_at IS NOT DISTINCT FROM $2
+  ORDER BY order_index
+  `
+	rows, err := q.Query(entryQuery, id, workout.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workout.Entries = []WorkoutEntry{}
+	for rows.Next() {
+		var entry WorkoutEntry
+		var groupID sql.NullInt64
+		err = rows.Scan(&entry.ID, &groupID, &entry.ExerciseName, &entry.Sets, &entry.Reps, &entry.DurationSeconds, &entry.Weight, &entry.Notes, &entry.OrderIndex)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := groupIndex[groupID.Int64]; groupID.Valid && ok {
+			workout.Groups[i].Entries = append(workout.Groups[i].Entries, entry)
+			continue
+		}
+		workout.Entries = append(workout.Entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	// a transaction cannot run the next query while rows are still open
+	rows.Close()
+
+	err = loadSets(q, workout, workout.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	workout.Volume = workout.TotalVolume()
+
+	workout.Tags, err = loadWorkoutTags(q, id)
+	if err != nil {
+		return nil, err
+	}
 
 	return workout, nil
 }
+
+// ListWorkouts returns a user's live, completed workouts newest first with
+// their tags but without entries.
+func (pg *PostgresWorkoutStore) ListWorkouts(filter WorkoutFilter) ([]*Workout, error) {
+	tags := make([]string, 0, len(filter.Tags))
+	for _, tag := range filter.Tags {
+		tags = append(tags, strings.ToLower(tag))
+	}
+
+	query := `
+  SELECT w.id, w.user_id, w.title, COALESCE(w.description, ''), w.duration_seconds, w.calories_burned, w.performed_at, w.created_at,
+    w.planned_workout_id, w.planned_for, w.visibility,
+    (SELECT COALESCE(json_agg(t.name ORDER BY lower(t.name)), '[]')
+     FROM tags t JOIN workout_tags wt ON wt.tag_id = t.id
+     WHERE wt.workout_id = w.id)
+  FROM workouts w
+  WHERE w.user_id = $1 AND w.deleted_at IS NULL AND w.scheduled_at IS NULL
+    AND (cardinality($2::text[]) = 0 OR w.id IN (
+      SELECT wt.workout_id
+      FROM workout_tags wt
+      JOIN tags t ON t.id = wt.tag_id
+      WHERE t.user_id = $1 AND lower(t.name) = ANY($2::text[])
+      GROUP BY wt.workout_id
+      HAVING $3 = 'any' OR COUNT(DISTINCT t.id) = cardinality($2::text[])
+    ))
+  ORDER BY w.performed_at DESC, w.id DESC
+  LIMIT $4 OFFSET $5
+  `
+
+	rows, err := pg.db.Query(query, filter.UserID, tags, string(filter.Match), filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workouts := []*Workout{}
+	for rows.Next() {
+		workout := &Workout{}
+		var tagNames []byte
+		err = rows.Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationSeconds, &workout.CaloriesBurned, &workout.PerformedAt, &workout.CreatedAt,
+			&workout.PlannedWorkoutID, &workout.PlannedFor, &workout.Visibility, &tagNames)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(tagNames, &workout.Tags)
+		if err != nil {
+			return nil, err
+		}
+		workouts = append(workouts, workout)
+	}
+
+	return workouts, rows.Err()
+}
+
+// WorkoutsLastModified returns when the user's workouts, or the preferences
+// they are rendered with, last changed. Deleting and restoring a workout count
+// as changes, so the time only moves forward.
+func (pg *PostgresWorkoutStore) WorkoutsLastModified(userID int64) (time.Time, error) {
+	var modified time.Time
+	query := `
+  SELECT GREATEST(u.updated_at, (SELECT MAX(w.updated_at) FROM workouts w WHERE w.user_id = u.id))
+  FROM users u
+  WHERE u.id = $1
+  `
+	err := pg.db.QueryRow(query, userID).Scan(&modified)
+	return modified, err
+}
+
+// SetWorkoutTags replaces the tags of one of the user's live workouts. It
+// returns sql.ErrNoRows when the user has no such workout.
+func (pg *PostgresWorkoutStore) SetWorkoutTags(workoutID, userID int64, tags []string, actor Actor) (*Workout, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var locked int64
+	err = tx.QueryRow(`SELECT id FROM workouts WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE`, workoutID, userID).Scan(&locked)
+	if err != nil {
+		return nil, err
+	}
+
+	err = replaceWorkoutTags(tx, &Workout{ID: int(workoutID), UserID: &userID, Tags: tags})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE workouts SET updated_at = CURRENT_TIMESTAMP WHERE id = $1`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordRevision(tx, workoutID, RevisionUpdate, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	workout, err := getWorkout(tx, workoutID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return workout, tx.Commit()
+}
+
+// ExportWorkouts walks all of a user's workouts oldest first, calling fn once
+// per workout with its entries loaded. Only one workout is held in memory at a
+// time so large histories can be streamed straight to the client. Groups are
+// flattened into Entries in the order they are performed, with each grouped
+// entry's sets multiplied by the group's rounds.
+func (pg *PostgresWorkoutStore) ExportWorkouts(userID int64, fn func(*Workout) error) error {
+	query := `
+  SELECT w.id, w.user_id, w.title, COALESCE(w.description, ''), w.duration_seconds, w.calories_burned, w.performed_at, w.created_at,
+    we.id, we.exercise_name, we.sets * COALESCE(g.rounds, 1), we.reps, we.duration_seconds, we.weight, we.notes,
+    ROW_NUMBER() OVER (PARTITION BY w.id ORDER BY COALESCE(g.order_index, we.order_index), g.id NULLS FIRST, we.order_index) - 1 AS position
+  FROM workouts w
+  LEFT JOIN workout_entries we ON we.workout_id = w.id AND we.deleted_at IS NULL
+  LEFT JOIN workout_entry_groups g ON g.id = we.group_id
+  WHERE w.user_id = $1 AND w.scheduled_at IS NULL AND w.deleted_at IS NULL
+  ORDER BY w.performed_at, w.id, position
+  `
+
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *Workout
+	for rows.Next() {
+		var w Workout
+		var entryID, sets, orderIndex sql.NullInt64
+		var exerciseName, notes sql.NullString
+		var entry WorkoutEntry
+		err = rows.Scan(&w.ID, &w.UserID, &w.Title, &w.Description, &w.DurationSeconds, &w.CaloriesBurned, &w.PerformedAt, &w.CreatedAt,
+			&entryID, &exerciseName, &sets, &entry.Reps, &entry.DurationSeconds, &entry.Weight, &notes, &orderIndex)
+		if err != nil {
+			return err
+		}
+
+		if current == nil || current.ID != w.ID {
+			if current != nil {
+				err = fn(current)
+				if err != nil {
+					return err
+				}
+			}
+			w.Entries = []WorkoutEntry{}
+			current = &w
+		}
+
+		if entryID.Valid {
+			entry.ID = int(entryID.Int64)
+			entry.ExerciseName = exerciseName.String
+			entry.Sets = int(sets.Int64)
+			entry.Notes = notes.String
+			entry.OrderIndex = int(orderIndex.Int64)
+			current.Entries = append(current.Entries, entry)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if current != nil {
+		return fn(current)
+	}
+	return nil
+}
+
+// GetCalendarWorkouts returns a user's planned workouts together with the
+// completed workouts that fulfil one of their occurrences. Entries are not
+// loaded.
+func (pg *PostgresWorkoutStore) GetCalendarWorkouts(userID int64) ([]*Workout, error) {
+	query := `
+  SELECT id, user_id, title, COALESCE(description, ''), duration_seconds, calories_burned, performed_at, created_at,
+    scheduled_at, recurrence, planned_workout_id, planned_for
+  FROM workouts w
+  WHERE user_id = $1 AND deleted_at IS NULL
+    AND (scheduled_at IS NOT NULL OR planned_workout_id IS NOT NULL)
+    AND NOT EXISTS (
+      SELECT 1 FROM workouts p
+      WHERE p.id = w.planned_workout_id AND p.deleted_at IS NOT NULL
+    )
+  ORDER BY id
+  `
+
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workouts := []*Workout{}
+	for rows.Next() {
+		workout := &Workout{}
+		err = rows.Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationSeconds, &workout.CaloriesBurned, &workout.PerformedAt, &workout.CreatedAt,
+			&workout.ScheduledAt, &workout.Recurrence, &workout.PlannedWorkoutID, &workout.PlannedFor)
+		if err != nil {
+			return nil, err
+		}
+		workouts = append(workouts, workout)
+	}
+
+	return workouts, rows.Err()
+}
+
+// DeleteWorkout moves a workout and its entries to the trash. It returns
+// sql.ErrNoRows when the user has no such live workout.
+func (pg *PostgresWorkoutStore) DeleteWorkout(id, userID int64, actor Actor) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	query := `
+  UPDATE workouts
+  SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+  WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+  RETURNING deleted_at
+  `
+	err = tx.QueryRow(query, id, userID).Scan(&deletedAt)
+	if err != nil {
+		return err
+	}
+
+	// stamp the entries with the same time so restore only brings back the
+	// ones trashed together with the workout
+	_, err = tx.Exec(`UPDATE workout_entries SET deleted_at = $2 WHERE workout_id = $1 AND deleted_at IS NULL`, id, deletedAt)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(tx, id, RevisionDelete, actor)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RestoreWorkout brings a trashed workout back. It returns sql.ErrNoRows when
+// the user has no such workout in the trash.
+func (pg *PostgresWorkoutStore) RestoreWorkout(id, userID int64, actor Actor) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	query := `
+  SELECT deleted_at
+  FROM workouts
+  WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+  FOR UPDATE
+  `
+	err = tx.QueryRow(query, id, userID).Scan(&deletedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE workouts SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE workout_entries SET deleted_at = NULL WHERE workout_id = $1 AND deleted_at = $2`, id, deletedAt)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(tx, id, RevisionRestore, actor)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresWorkoutStore) ListTrashedWorkouts(userID int64) ([]*Workout, error) {
+	query := `
+  SELECT id, user_id, title, COALESCE(description, ''), duration_seconds, calories_burned, performed_at, created_at, deleted_at
+  FROM workouts
+  WHERE user_id = $1 AND deleted_at IS NOT NULL
+  ORDER BY deleted_at DESC
+  `
+
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workouts := []*Workout{}
+	for rows.Next() {
+		workout := &Workout{}
+		err = rows.Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationSeconds, &workout.CaloriesBurned, &workout.PerformedAt, &workout.CreatedAt, &workout.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		workouts = append(workouts, workout)
+	}
+
+	return workouts, rows.Err()
+}
+
+// PurgeTrash permanently deletes workouts and entries trashed before
+// deletedBefore and reports how many workouts were removed. The delete
+// revision written when they were trashed remains as the last record.
+func (pg *PostgresWorkoutStore) PurgeTrash(deletedBefore time.Time) (int64, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM workout_entries WHERE deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`DELETE FROM workouts WHERE deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, tx.Commit()
+}
@@ -0,0 +1,299 @@
+package tracks
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+)
+
+var (
+	ErrUnknownFormat = errors.New("file is neither GPX nor TCX")
+	ErrNoTrackPoints = errors.New("file contains no track points with a position")
+)
+
+type Point struct {
+	Lat       float64
+	Lon       float64
+	Elevation *float64
+	Time      time.Time
+}
+
+// Track is the format independent result of parsing a GPX or TCX file.
+// Recorded* fields hold totals the device wrote itself, which are preferred
+// over values derived from the points.
+type Track struct {
+	Name   string
+	Sport  string
+	Points []Point
+
+	RecordedSeconds  float64
+	RecordedDistance float64
+	RecordedCalories int
+}
+
+type Metrics struct {
+	StartedAt           time.Time
+	DurationSeconds     float64
+	DistanceMeters      float64
+	ElevationGainMeters float64
+	// PaceSecondsPerKm is zero when no distance was covered.
+	PaceSecondsPerKm float64
+}
+
+type gpxFile struct {
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []struct {
+				Lat  float64  `xml:"lat,attr"`
+				Lon  float64  `xml:"lon,attr"`
+				Ele  *float64 `xml:"ele"`
+				Time string   `xml:"time"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+type tcxFile struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		Laps  []struct {
+			TotalTimeSeconds float64 `xml:"TotalTimeSeconds"`
+			DistanceMeters   float64 `xml:"DistanceMeters"`
+			Calories         int     `xml:"Calories"`
+			Points           []struct {
+				Time     string `xml:"Time"`
+				Position *struct {
+					Lat float64 `xml:"LatitudeDegrees"`
+					Lon float64 `xml:"LongitudeDegrees"`
+				} `xml:"Position"`
+				Altitude *float64 `xml:"AltitudeMeters"`
+			} `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+// Parse reads a GPX or TCX document, detected from its root element.
+func Parse(r io.Reader) (*Track, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var track *Track
+	switch strings.ToLower(root) {
+	case "gpx":
+		track, err = parseGPX(data)
+	case "trainingcenterdatabase":
+		track, err = parseTCX(data)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(track.Points) == 0 {
+		return nil, ErrNoTrackPoints
+	}
+	return track, nil
+}
+
+func rootElement(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return "", ErrUnknownFormat
+		}
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func parseGPX(data []byte) (*Track, error) {
+	var file gpxFile
+	err := xml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, err
+	}
+
+	track := &Track{}
+	for _, trk := range file.Tracks {
+		if track.Name == "" {
+			track.Name = trk.Name
+		}
+		if track.Sport == "" {
+			track.Sport = trk.Type
+		}
+		for _, seg := range trk.Segments {
+			for _, pt := range seg.Points {
+				track.Points = append(track.Points, Point{
+					Lat:       pt.Lat,
+					Lon:       pt.Lon,
+					Elevation: pt.Ele,
+					Time:      parseTime(pt.Time),
+				})
+			}
+		}
+	}
+	return track, nil
+}
+
+func parseTCX(data []byte) (*Track, error) {
+	var file tcxFile
+	err := xml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, err
+	}
+
+	track := &Track{}
+	for _, activity := range file.Activities {
+		if track.Sport == "" {
+			track.Sport = activity.Sport
+		}
+		for _, lap := range activity.Laps {
+			track.RecordedSeconds += lap.TotalTimeSeconds
+			track.RecordedDistance += lap.DistanceMeters
+			track.RecordedCalories += lap.Calories
+			for _, pt := range lap.Points {
+				// TCX writes points without a position while the GPS has no fix
+				if pt.Position == nil {
+					continue
+				}
+				track.Points = append(track.Points, Point{
+					Lat:       pt.Position.Lat,
+					Lon:       pt.Position.Lon,
+					Elevation: pt.Altitude,
+					Time:      parseTime(pt.Time),
+				})
+			}
+		}
+	}
+	return track, nil
+}
+
+func parseTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// LineString returns the route in GeoJSON axis order (lon, lat).
+func (t *Track) LineString() orb.LineString {
+	ls := make(orb.LineString, 0, len(t.Points))
+	for _, pt := range t.Points {
+		ls = append(ls, orb.Point{pt.Lon, pt.Lat})
+	}
+	return ls
+}
+
+// elevationThreshold is the climb or drop, in metres, that has to build up
+// before it counts. GPS altitude wanders by a few metres between fixes, and
+// summing every small rise would report a flat route as hilly.
+const elevationThreshold = 3.0
+
+func (t *Track) Metrics() Metrics {
+	m := Metrics{}
+
+	// points are not always written in order, so the span runs from the
+	// earliest to the latest timestamp rather than first to last point
+	var first, last time.Time
+	for _, pt := range t.Points {
+		if pt.Time.IsZero() {
+			continue
+		}
+		if first.IsZero() || pt.Time.Before(first) {
+			first = pt.Time
+		}
+		if pt.Time.After(last) {
+			last = pt.Time
+		}
+	}
+	m.StartedAt = first
+
+	m.DurationSeconds = t.RecordedSeconds
+	if m.DurationSeconds == 0 && !first.IsZero() {
+		m.DurationSeconds = last.Sub(first).Seconds()
+	}
+
+	m.DistanceMeters = t.RecordedDistance
+	if m.DistanceMeters == 0 {
+		m.DistanceMeters = geo.LengthHaversine(t.LineString())
+	}
+
+	m.ElevationGainMeters = t.elevationGain()
+
+	if m.DistanceMeters > 0 {
+		m.PaceSecondsPerKm = m.DurationSeconds / (m.DistanceMeters / 1000)
+	}
+
+	m.DistanceMeters = math.Round(m.DistanceMeters*10) / 10
+	m.ElevationGainMeters = math.Round(m.ElevationGainMeters*10) / 10
+	m.PaceSecondsPerKm = math.Round(m.PaceSecondsPerKm)
+	return m
+}
+
+// elevationGain sums the climbs between points with hysteresis: a climb is
+// only counted once the altitude has risen elevationThreshold above the last
+// reference, and the reference only follows a descent once it drops by as
+// much, so noise around a steady altitude adds nothing.
+func (t *Track) elevationGain() float64 {
+	gain := 0.0
+	var ref *float64
+	for _, pt := range t.Points {
+		if pt.Elevation == nil {
+			continue
+		}
+		switch {
+		case ref == nil:
+			ref = pt.Elevation
+		case *pt.Elevation-*ref >= elevationThreshold:
+			gain += *pt.Elevation - *ref
+			ref = pt.Elevation
+		case *ref-*pt.Elevation >= elevationThreshold:
+			ref = pt.Elevation
+		}
+	}
+	return gain
+}
+
+// ExerciseName maps the sport written by the device to a name the calorie
+// estimator's MET table understands.
+func (t *Track) ExerciseName() string {
+	sport := strings.ToLower(t.Sport)
+	switch {
+	case strings.Contains(sport, "run"):
+		return "Running"
+	case strings.Contains(sport, "bik"), strings.Contains(sport, "cycl"), strings.Contains(sport, "ride"):
+		return "Cycling"
+	case strings.Contains(sport, "walk"):
+		return "Walking"
+	case strings.Contains(sport, "hik"):
+		return "Hiking"
+	case strings.Contains(sport, "swim"):
+		return "Swimming"
+	case strings.Contains(sport, "row"):
+		return "Rowing"
+	}
+	return "Cardio"
+}
@@ -0,0 +1,286 @@
+package tracks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gpx builds a single segment GPX document. Each point is "lat,lon,ele,time"
+// where ele and time may be left empty.
+func gpx(name, sport string, points ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">`)
+	fmt.Fprintf(&b, "<trk><name>%s</name><type>%s</type><trkseg>", name, sport)
+	for _, p := range points {
+		parts := strings.Split(p, ",")
+		fmt.Fprintf(&b, `<trkpt lat="%s" lon="%s">`, parts[0], parts[1])
+		if parts[2] != "" {
+			fmt.Fprintf(&b, "<ele>%s</ele>", parts[2])
+		}
+		if parts[3] != "" {
+			fmt.Fprintf(&b, "<time>%s</time>", parts[3])
+		}
+		b.WriteString("</trkpt>")
+	}
+	b.WriteString("</trkseg></trk></gpx>")
+	return b.String()
+}
+
+const tcx = `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+  <Activities>
+    <Activity Sport="Running">
+      <Id>2024-03-02T08:00:00Z</Id>
+      <Lap StartTime="2024-03-02T08:00:00Z">
+        <TotalTimeSeconds>1200</TotalTimeSeconds>
+        <DistanceMeters>3000</DistanceMeters>
+        <Calories>210</Calories>
+        <Track>
+          <Trackpoint>
+            <Time>2024-03-02T08:00:00Z</Time>
+            <Position><LatitudeDegrees>51.5</LatitudeDegrees><LongitudeDegrees>-0.1</LongitudeDegrees></Position>
+            <AltitudeMeters>20</AltitudeMeters>
+          </Trackpoint>
+          <Trackpoint>
+            <Time>2024-03-02T08:01:00Z</Time>
+          </Trackpoint>
+          <Trackpoint>
+            <Time>2024-03-02T08:20:00Z</Time>
+            <Position><LatitudeDegrees>51.52</LatitudeDegrees><LongitudeDegrees>-0.1</LongitudeDegrees></Position>
+            <AltitudeMeters>32</AltitudeMeters>
+          </Trackpoint>
+        </Track>
+      </Lap>
+      <Lap StartTime="2024-03-02T08:20:00Z">
+        <TotalTimeSeconds>600</TotalTimeSeconds>
+        <DistanceMeters>2000</DistanceMeters>
+        <Calories>90</Calories>
+        <Track>
+          <Trackpoint>
+            <Time>2024-03-02T08:30:00Z</Time>
+            <Position><LatitudeDegrees>51.53</LatitudeDegrees><LongitudeDegrees>-0.1</LongitudeDegrees></Position>
+            <AltitudeMeters>30</AltitudeMeters>
+          </Trackpoint>
+        </Track>
+      </Lap>
+    </Activity>
+  </Activities>
+</TrainingCenterDatabase>`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name         string
+		doc          string
+		wantName     string
+		wantSport    string
+		wantPoints   int
+		wantExercise string
+		wantErr      error
+	}{
+		{
+			name:         "gpx",
+			doc:          gpx("Morning Ride", "cycling", "0,0,100,2024-03-02T08:00:00Z", "0.01,0,110,2024-03-02T08:05:00Z"),
+			wantName:     "Morning Ride",
+			wantSport:    "cycling",
+			wantPoints:   2,
+			wantExercise: "Cycling",
+		},
+		{
+			name:         "tcx skips points without a position",
+			doc:          tcx,
+			wantSport:    "Running",
+			wantPoints:   3,
+			wantExercise: "Running",
+		},
+		{
+			name:    "neither format",
+			doc:     `<kml><Document/></kml>`,
+			wantErr: ErrUnknownFormat,
+		},
+		{
+			name:    "empty document",
+			doc:     ``,
+			wantErr: ErrUnknownFormat,
+		},
+		{
+			name:    "gpx without points",
+			doc:     gpx("Empty", "running"),
+			wantErr: ErrNoTrackPoints,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track, err := Parse(strings.NewReader(tt.doc))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Parse error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if track.Name != tt.wantName || track.Sport != tt.wantSport {
+				t.Errorf("name, sport = %q, %q, want %q, %q", track.Name, track.Sport, tt.wantName, tt.wantSport)
+			}
+			if len(track.Points) != tt.wantPoints {
+				t.Errorf("%d points, want %d", len(track.Points), tt.wantPoints)
+			}
+			if got := track.ExerciseName(); got != tt.wantExercise {
+				t.Errorf("ExerciseName = %q, want %q", got, tt.wantExercise)
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	// 0.01 degrees of latitude is about 1113.2 m on the sphere orb uses
+	tests := []struct {
+		name          string
+		doc           string
+		wantStartedAt string
+		wantDuration  float64
+		wantDistance  float64
+		wantPace      float64
+		wantGain      float64
+	}{
+		{
+			name: "distance, duration, pace and gain from the points",
+			doc: gpx("Run", "running",
+				"0,0,100,2024-03-02T08:00:00Z",
+				"0.01,0,110,2024-03-02T08:05:00Z",
+				"0.02,0,105,2024-03-02T08:10:00Z",
+			),
+			wantStartedAt: "2024-03-02T08:00:00Z",
+			wantDuration:  600,
+			wantDistance:  2226.4,
+			wantPace:      269,
+			wantGain:      10,
+		},
+		{
+			name: "points out of order",
+			doc: gpx("Run", "running",
+				"0,0,100,2024-03-02T08:05:00Z",
+				"0.01,0,110,2024-03-02T08:10:00Z",
+				"0.02,0,105,2024-03-02T08:00:00Z",
+			),
+			wantStartedAt: "2024-03-02T08:00:00Z",
+			wantDuration:  600,
+			wantDistance:  2226.4,
+			wantPace:      269,
+			wantGain:      10,
+		},
+		{
+			name: "no timestamps",
+			doc: gpx("Run", "running",
+				"0,0,100,",
+				"0.01,0,110,",
+			),
+			wantDistance: 1113.2,
+			wantGain:     10,
+		},
+		{
+			name: "some timestamps missing",
+			doc: gpx("Run", "running",
+				"0,0,,",
+				"0.01,0,,2024-03-02T08:00:00Z",
+				"0.02,0,,2024-03-02T08:04:00Z",
+			),
+			wantStartedAt: "2024-03-02T08:00:00Z",
+			wantDuration:  240,
+			wantDistance:  2226.4,
+			wantPace:      108,
+		},
+		{
+			name: "no elevation",
+			doc: gpx("Run", "running",
+				"0,0,,2024-03-02T08:00:00Z",
+				"0.01,0,,2024-03-02T08:05:00Z",
+			),
+			wantStartedAt: "2024-03-02T08:00:00Z",
+			wantDuration:  300,
+			wantDistance:  1113.2,
+			wantPace:      269,
+		},
+		{
+			name: "altitude noise on the flat is ignored",
+			doc: gpx("Run", "running",
+				"0,0,100,", "0,0.001,101,", "0,0.002,99,", "0,0.003,102,",
+				"0,0.004,100,", "0,0.005,101,", "0,0.006,99,",
+			),
+			wantDistance: 667.9,
+		},
+		{
+			name: "a noisy climb counts once",
+			doc: gpx("Run", "running",
+				"0,0,100,", "0,0.001,102,", "0,0.002,101,", "0,0.003,104,",
+				"0,0.004,103,", "0,0.005,106,", "0,0.006,105,", "0,0.007,110,",
+			),
+			wantDistance: 779.2,
+			wantGain:     10,
+		},
+		{
+			name: "separate climbs add up",
+			doc: gpx("Run", "running",
+				"0,0,100,", "0,0.001,120,", "0,0.002,90,", "0,0.003,95,",
+			),
+			wantDistance: 334,
+			wantGain:     25,
+		},
+		{
+			name:          "recorded lap totals win over the points",
+			doc:           tcx,
+			wantStartedAt: "2024-03-02T08:00:00Z",
+			wantDuration:  1800,
+			wantDistance:  5000,
+			wantPace:      360,
+			wantGain:      12,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track, err := Parse(strings.NewReader(tt.doc))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			m := track.Metrics()
+
+			var wantStartedAt time.Time
+			if tt.wantStartedAt != "" {
+				wantStartedAt, _ = time.Parse(time.RFC3339, tt.wantStartedAt)
+			}
+			if !m.StartedAt.Equal(wantStartedAt) {
+				t.Errorf("StartedAt = %v, want %v", m.StartedAt, wantStartedAt)
+			}
+			if m.DurationSeconds != tt.wantDuration {
+				t.Errorf("DurationSeconds = %v, want %v", m.DurationSeconds, tt.wantDuration)
+			}
+			if m.DistanceMeters != tt.wantDistance {
+				t.Errorf("DistanceMeters = %v, want %v", m.DistanceMeters, tt.wantDistance)
+			}
+			if m.PaceSecondsPerKm != tt.wantPace {
+				t.Errorf("PaceSecondsPerKm = %v, want %v", m.PaceSecondsPerKm, tt.wantPace)
+			}
+			if m.ElevationGainMeters != tt.wantGain {
+				t.Errorf("ElevationGainMeters = %v, want %v", m.ElevationGainMeters, tt.wantGain)
+			}
+		})
+	}
+}
+
+func TestParseTCXTotals(t *testing.T) {
+	track, err := Parse(strings.NewReader(tcx))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if track.RecordedSeconds != 1800 || track.RecordedDistance != 5000 || track.RecordedCalories != 300 {
+		t.Errorf("recorded totals = %vs, %vm, %vkcal, want 1800s, 5000m, 300kcal",
+			track.RecordedSeconds, track.RecordedDistance, track.RecordedCalories)
+	}
+}
@@ -0,0 +1,129 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+type Weight string
+
+const (
+	Kilograms Weight = "kg"
+	Pounds    Weight = "lb"
+)
+
+type Distance string
+
+const (
+	Kilometres Distance = "km"
+	Miles      Distance = "mi"
+)
+
+const (
+	kilogramsPerPound = 0.45359237
+	metresPerMile     = 1609.344
+)
+
+func (w Weight) Valid() bool {
+	return w == Kilograms || w == Pounds
+}
+
+func (d Distance) Valid() bool {
+	return d == Kilometres || d == Miles
+}
+
+// Preference is the set of units values are shown in.
+type Preference struct {
+	Weight   Weight   `json:"weight"`
+	Distance Distance `json:"distance"`
+	// WeightIncrement rounds displayed weights to a multiple of itself, e.g.
+	// 0.5 for the smallest plate pair in the gym. Zero rounds to hundredths.
+	WeightIncrement float64 `json:"weight_increment,omitempty"`
+}
+
+var Metric = Preference{Weight: Kilograms, Distance: Kilometres}
+
+// Override applies a comma separated ?units= value such as "imperial" or
+// "lb,km". The increment is only kept while weights stay in the unit it was
+// chosen for.
+func (p Preference) Override(value string) (Preference, error) {
+	out := p
+	for _, token := range strings.Split(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(token)) {
+		case "metric":
+			out.Weight, out.Distance = Kilograms, Kilometres
+		case "imperial":
+			out.Weight, out.Distance = Pounds, Miles
+		case "kg":
+			out.Weight = Kilograms
+		case "lb", "lbs":
+			out.Weight = Pounds
+		case "km":
+			out.Distance = Kilometres
+		case "mi":
+			out.Distance = Miles
+		default:
+			return p, errors.New("units must be metric, imperial or a list of kg, lb, km and mi")
+		}
+	}
+
+	if out.Weight != p.Weight {
+		out.WeightIncrement = 0
+	}
+	return out, nil
+}
+
+// WeightOut converts kilograms to the preferred unit and rounds the result.
+func (p Preference) WeightOut(kg float64) float64 {
+	v := kg
+	if p.Weight == Pounds {
+		v = kg / kilogramsPerPound
+	}
+	return Round(v, p.WeightIncrement)
+}
+
+// TonnageOut converts a weight × reps total. Totals are not rounded to the
+// plate increment.
+func (p Preference) TonnageOut(kg float64) float64 {
+	if p.Weight == Pounds {
+		return Round(kg/kilogramsPerPound, 0)
+	}
+	return Round(kg, 0)
+}
+
+// WeightIn converts a weight entered in the preferred unit to kilograms.
+func (p Preference) WeightIn(v float64) float64 {
+	if p.Weight == Pounds {
+		v *= kilogramsPerPound
+	}
+	return Round(v, 0.001)
+}
+
+// DistanceOut converts metres to kilometres or miles.
+func (p Preference) DistanceOut(metres float64) float64 {
+	if p.Distance == Miles {
+		return Round(metres/metresPerMile, 0.01)
+	}
+	return Round(metres/1000, 0.01)
+}
+
+// PaceOut converts seconds per kilometre to seconds per preferred distance
+// unit.
+func (p Preference) PaceOut(secondsPerKm float64) float64 {
+	if p.Distance == Miles {
+		return math.Round(secondsPerKm * metresPerMile / 1000)
+	}
+	return math.Round(secondsPerKm)
+}
+
+// Round rounds v to the nearest multiple of increment, or to hundredths when
+// increment is not positive.
+func Round(v, increment float64) float64 {
+	if increment <= 0 {
+		increment = 0.01
+	}
+	r := math.Round(v/increment) * increment
+	// clean up binary noise such as 102.50000000000001
+	return math.Round(r*1000) / 1000
+}
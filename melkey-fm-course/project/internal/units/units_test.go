@@ -0,0 +1,105 @@
+package units
+
+import "testing"
+
+var imperial = Preference{Weight: Pounds, Distance: Miles}
+
+func TestOverride(t *testing.T) {
+	plates := Preference{Weight: Kilograms, Distance: Kilometres, WeightIncrement: 2.5}
+
+	tests := []struct {
+		name    string
+		p       Preference
+		value   string
+		want    Preference
+		wantErr bool
+	}{
+		{name: "imperial", p: Metric, value: "imperial", want: imperial},
+		{name: "metric", p: imperial, value: "metric", want: Metric},
+		{name: "mixed", p: Metric, value: "lb,km", want: Preference{Weight: Pounds, Distance: Kilometres}},
+		{name: "case and spaces", p: Metric, value: " LBS , Mi", want: imperial},
+		{name: "later tokens win", p: Metric, value: "imperial,kg", want: Preference{Weight: Kilograms, Distance: Miles}},
+		{name: "increment kept for the same weight unit", p: plates, value: "mi", want: Preference{Weight: Kilograms, Distance: Miles, WeightIncrement: 2.5}},
+		{name: "increment dropped when the weight unit changes", p: plates, value: "lb", want: Preference{Weight: Pounds, Distance: Kilometres}},
+		{name: "unknown unit", p: plates, value: "stone", want: plates, wantErr: true},
+		{name: "empty", p: Metric, value: "", want: Metric, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.Override(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Override(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Override(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConversions(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"kg out", Metric.WeightOut(102.456), 102.46},
+		{"lb out", imperial.WeightOut(100), 220.46},
+		{"lb out to the nearest plate", Preference{Weight: Pounds, WeightIncrement: 5}.WeightOut(100), 220},
+		{"kg out to the nearest plate", Preference{Weight: Kilograms, WeightIncrement: 2.5}.WeightOut(101.3), 102.5},
+		{"kg in", Metric.WeightIn(102.5), 102.5},
+		{"lb in", imperial.WeightIn(225), 102.058},
+		{"lb round trip", imperial.WeightOut(imperial.WeightIn(225)), 225},
+		{"kg tonnage", Preference{Weight: Kilograms, WeightIncrement: 2.5}.TonnageOut(1234.567), 1234.57},
+		{"lb tonnage", Preference{Weight: Pounds, WeightIncrement: 5}.TonnageOut(1000), 2204.62},
+		{"km distance", Metric.DistanceOut(5123), 5.12},
+		{"mi distance", imperial.DistanceOut(5000), 3.11},
+		{"km pace", Metric.PaceOut(300.4), 300},
+		{"mi pace", imperial.PaceOut(300), 483},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		v, increment, want float64
+	}{
+		{102.456, 0, 102.46},
+		{102.456, -1, 102.46},
+		{102.4, 0.5, 102.5},
+		{102.2, 0.5, 102},
+		{102.25, 2.5, 102.5},
+		{0.1 + 0.2, 0.1, 0.3},
+		{41, 0.001, 41},
+	}
+
+	for _, tt := range tests {
+		if got := Round(tt.v, tt.increment); got != tt.want {
+			t.Errorf("Round(%v, %v) = %v, want %v", tt.v, tt.increment, got, tt.want)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, w := range []Weight{Kilograms, Pounds} {
+		if !w.Valid() {
+			t.Errorf("%q is not valid", w)
+		}
+	}
+	for _, d := range []Distance{Kilometres, Miles} {
+		if !d.Valid() {
+			t.Errorf("%q is not valid", d)
+		}
+	}
+	if Weight("st").Valid() || Distance("yd").Valid() {
+		t.Error("unknown units are valid")
+	}
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Envelope map[string]interface{}
+
+func WriteJSON(w http.ResponseWriter, status int, data Envelope) error {
+	js, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	js = append(js, '\n')
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+	return nil
+}
+
+func ReadIDParam(r *http.Request) (int64, error) {
+	idParam := chi.URLParam(r, "id")
+	if idParam == "" {
+		return 0, errors.New("invalid id parameter")
+	}
+
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid id parameter type")
+	}
+
+	return id, nil
+}
+
+// NotModified sets Last-Modified and reports whether the request's
+// If-Modified-Since shows the client already has the current version, in
+// which case a 304 has been written and the handler should stop. HTTP dates
+// only carry whole seconds, so modified is truncated to match.
+func NotModified(w http.ResponseWriter, r *http.Request, modified time.Time) bool {
+	modified = modified.Truncate(time.Second)
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	// the response depends on who asks, so shared caches must keep out and
+	// clients must check back before reusing it
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.After(since) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
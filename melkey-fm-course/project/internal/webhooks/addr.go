@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// ErrForbiddenAddress is returned for endpoints on loopback, private,
+// link-local or unspecified addresses, which would let a user make the server
+// call its own admin ports, the cloud metadata service at 169.254.169.254 or
+// anything else on the internal network.
+var ErrForbiddenAddress = errors.New("webhook url must resolve to a public address")
+
+// PublicAddr reports whether deliveries may be sent to addr.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified()
+}
+
+// CheckHost resolves host and fails with ErrForbiddenAddress when any of its
+// addresses is not public. It is meant for registration; the dispatcher
+// checks again when it dials, since DNS can change in between.
+func CheckHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !PublicAddr(addr) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !PublicAddr(addr) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// dialControl refuses connections to addresses that are not public. It runs
+// after name resolution, on the address actually being dialled.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !PublicAddr(addrPort.Addr()) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
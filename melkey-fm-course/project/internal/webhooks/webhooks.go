@@ -0,0 +1,209 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/backoff"
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+)
+
+const (
+	// SignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>" where
+	// the MAC covers "<t>.<body>" keyed with the endpoint secret.
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	EventIDHeader   = "X-Webhook-Id"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var ErrInvalidSignature = errors.New("webhook signature is invalid")
+
+// Sign returns the signature header value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+// Verify checks a signature header against body, rejecting signatures older
+// than tolerance so captured requests cannot be replayed later. Receivers can
+// use it as is.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sigs = append(sigs, value)
+		}
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 && time.Since(time.Unix(sec, 0)).Abs() > tolerance {
+		return ErrInvalidSignature
+	}
+
+	want := mac(secret, ts, body)
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(want)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func mac(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Dispatcher sends queued deliveries, retrying failures with exponential
+// backoff until MaxAttempts is reached and the delivery is marked dead.
+type Dispatcher struct {
+	Store  store.WebhookStore
+	Client *http.Client
+	Logger *log.Logger
+	// BatchSize caps how many deliveries one pass claims.
+	BatchSize   int
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func NewDispatcher(webhookStore store.WebhookStore, logger *log.Logger) *Dispatcher {
+	// endpoints are user supplied, so every connection is checked against
+	// the address it resolved to, and no proxy is used that would hide it
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: dialControl}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Dispatcher{
+		Store: webhookStore,
+		Client: &http.Client{
+			Transport: transport,
+			Timeout:   10 * time.Second,
+			// a redirect counts as a failed delivery rather than being
+			// followed somewhere the user did not register
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Logger:      logger,
+		BatchSize:   50,
+		MaxAttempts: 8,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    6 * time.Hour,
+	}
+}
+
+// Enqueue is the outbox subscriber for workout events. It fans the event out
+// to the owner's endpoints, where DeliverDue picks it up.
+func (d *Dispatcher) Enqueue(ctx context.Context, event store.OutboxEvent) error {
+	return d.Store.EnqueueWebhookEvent(event)
+}
+
+// DeliverDue sends every delivery that is currently due and returns how many
+// were attempted.
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	// the lease outlives a full batch of timeouts so a slow pass never hands
+	// the same delivery to another worker
+	lease := time.Duration(d.BatchSize)*d.Client.Timeout + time.Minute
+	deliveries, err := d.Store.ClaimWebhookDeliveries(d.BatchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			// unsent deliveries are picked up again once the lease runs out
+			return 0, ctx.Err()
+		}
+
+		attempt := d.send(ctx, delivery)
+		err = d.Store.RecordWebhookAttempt(delivery.ID, attempt)
+		if err != nil {
+			return 0, err
+		}
+		if attempt.Status == store.WebhookDead {
+			d.Logger.Printf("webhook delivery %d to endpoint %d is dead after %d attempts: %s", delivery.ID, delivery.EndpointID, delivery.Attempts+1, attempt.Error)
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery store.WebhookDelivery) store.WebhookAttempt {
+	status, err := d.post(ctx, delivery)
+	attempt := store.WebhookAttempt{Status: store.WebhookSucceeded}
+	if status != 0 {
+		attempt.ResponseStatus = &status
+	}
+	if err == nil {
+		return attempt
+	}
+
+	attempt.Error = err.Error()
+	attempts := delivery.Attempts + 1
+	if attempts >= d.MaxAttempts {
+		attempt.Status = store.WebhookDead
+		return attempt
+	}
+	attempt.Status = store.WebhookPending
+	attempt.NextAttemptAt = time.Now().Add(d.Backoff(attempts))
+	return attempt
+}
+
+func (d *Dispatcher) post(ctx context.Context, delivery store.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "workouts-webhooks/1")
+	req.Header.Set(EventHeader, string(delivery.EventType))
+	req.Header.Set(EventIDHeader, delivery.EventID)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drain a little so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Backoff returns the wait after the given number of failed attempts:
+// BaseDelay doubled per attempt up to MaxDelay, plus up to 10% jitter so
+// endpoints coming back up are not hit by every retry at once.
+func (d *Dispatcher) Backoff(attempts int) time.Duration {
+	return backoff.Jittered(d.BaseDelay, d.MaxDelay, attempts)
+}
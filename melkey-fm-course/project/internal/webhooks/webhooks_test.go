@@ -0,0 +1,366 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		secret    string
+		header    string
+		body      []byte
+		tolerance time.Duration
+		wantErr   bool
+	}{
+		{
+			name:      "valid signature",
+			secret:    "s3cret",
+			header:    Sign("s3cret", now, body),
+			body:      body,
+			tolerance: 5 * time.Minute,
+		},
+		{
+			name:      "wrong secret",
+			secret:    "other",
+			header:    Sign("s3cret", now, body),
+			body:      body,
+			tolerance: 5 * time.Minute,
+			wantErr:   true,
+		},
+		{
+			name:      "tampered body",
+			secret:    "s3cret",
+			header:    Sign("s3cret", now, body),
+			body:      []byte(`{"id":"evt_2"}`),
+			tolerance: 5 * time.Minute,
+			wantErr:   true,
+		},
+		{
+			name:      "older than the tolerance",
+			secret:    "s3cret",
+			header:    Sign("s3cret", now.Add(-10*time.Minute), body),
+			body:      body,
+			tolerance: 5 * time.Minute,
+			wantErr:   true,
+		},
+		{
+			name:      "too far in the future",
+			secret:    "s3cret",
+			header:    Sign("s3cret", now.Add(10*time.Minute), body),
+			body:      body,
+			tolerance: 5 * time.Minute,
+			wantErr:   true,
+		},
+		{
+			name:    "zero tolerance skips the age check",
+			secret:  "s3cret",
+			header:  Sign("s3cret", now.Add(-24*time.Hour), body),
+			body:    body,
+			wantErr: false,
+		},
+		{
+			name:      "one of several v1 signatures matches",
+			secret:    "s3cret",
+			header:    Sign("old", now, body) + ",v1=" + mac("s3cret", strconv.FormatInt(now.Unix(), 10), body),
+			body:      body,
+			tolerance: 5 * time.Minute,
+		},
+		{
+			name:      "missing timestamp",
+			secret:    "s3cret",
+			header:    "v1=" + mac("s3cret", "", body),
+			body:      body,
+			tolerance: 5 * time.Minute,
+			wantErr:   true,
+		},
+		{
+			name:      "missing signature",
+			secret:    "s3cret",
+			header:    "t=" + strconv.FormatInt(now.Unix(), 10),
+			body:      body,
+			tolerance: 5 * time.Minute,
+			wantErr:   true,
+		},
+		{
+			name:    "empty header",
+			secret:  "s3cret",
+			body:    body,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, tt.tolerance)
+			if tt.wantErr && !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("Verify = %v, want ErrInvalidSignature", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("Verify = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	d := &Dispatcher{BaseDelay: 30 * time.Second, MaxDelay: 10 * time.Minute}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempts), func(t *testing.T) {
+			for range 100 {
+				got := d.Backoff(tt.attempts)
+				if got < tt.want || got > tt.want+tt.want/10 {
+					t.Fatalf("Backoff(%d) = %v, want between %v and %v", tt.attempts, got, tt.want, tt.want+tt.want/10)
+				}
+			}
+		})
+	}
+}
+
+// memoryWebhookStore hands out the deliveries it was given once and records
+// the attempts made for them. Only the methods the dispatcher uses are
+// implemented.
+type memoryWebhookStore struct {
+	store.WebhookStore
+
+	mu         sync.Mutex
+	deliveries []store.WebhookDelivery
+	attempts   map[int64]store.WebhookAttempt
+}
+
+func (s *memoryWebhookStore) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]store.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(limit, len(s.deliveries))
+	claimed := s.deliveries[:n]
+	s.deliveries = s.deliveries[n:]
+	return claimed, nil
+}
+
+func (s *memoryWebhookStore) RecordWebhookAttempt(id int64, attempt store.WebhookAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attempts == nil {
+		s.attempts = make(map[int64]store.WebhookAttempt)
+	}
+	s.attempts[id] = attempt
+	return nil
+}
+
+func newTestDispatcher(webhookStore store.WebhookStore) *Dispatcher {
+	d := NewDispatcher(webhookStore, log.New(io.Discard, "", 0))
+	// the test receiver listens on loopback, which the real transport refuses
+	d.Client.Transport = http.DefaultTransport
+	return d
+}
+
+func TestDispatcherDeliverDue(t *testing.T) {
+	const secret = "whsec_test"
+	payload := json.RawMessage(`{"id":"evt_1","type":"workout.created"}`)
+
+	tests := []struct {
+		name         string
+		respond      int
+		attempts     int
+		wantStatus   store.WebhookDeliveryStatus
+		wantResponse int
+		wantRetry    bool
+	}{
+		{
+			name:         "accepted",
+			respond:      http.StatusNoContent,
+			wantStatus:   store.WebhookSucceeded,
+			wantResponse: http.StatusNoContent,
+		},
+		{
+			name:         "server error is retried",
+			respond:      http.StatusInternalServerError,
+			wantStatus:   store.WebhookPending,
+			wantResponse: http.StatusInternalServerError,
+			wantRetry:    true,
+		},
+		{
+			name:         "redirects are not followed",
+			respond:      http.StatusFound,
+			wantStatus:   store.WebhookPending,
+			wantResponse: http.StatusFound,
+			wantRetry:    true,
+		},
+		{
+			name:         "last attempt is dead",
+			respond:      http.StatusBadGateway,
+			attempts:     7,
+			wantStatus:   store.WebhookDead,
+			wantResponse: http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			var gotBody []byte
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				gotBody, _ = io.ReadAll(r.Body)
+				if tt.respond == http.StatusFound {
+					w.Header().Set("Location", "http://169.254.169.254/")
+				}
+				w.WriteHeader(tt.respond)
+			}))
+			defer receiver.Close()
+
+			webhookStore := &memoryWebhookStore{deliveries: []store.WebhookDelivery{{
+				ID:         42,
+				EndpointID: 7,
+				EventID:    "evt_1",
+				EventType:  store.WebhookWorkoutCreated,
+				Payload:    payload,
+				Attempts:   tt.attempts,
+				URL:        receiver.URL,
+				Secret:     secret,
+			}}}
+			d := newTestDispatcher(webhookStore)
+
+			start := time.Now()
+			n, err := d.DeliverDue(context.Background())
+			if err != nil {
+				t.Fatalf("DeliverDue: %v", err)
+			}
+			if n != 1 {
+				t.Fatalf("DeliverDue sent %d deliveries, want 1", n)
+			}
+
+			if got == nil {
+				t.Fatal("receiver was not called")
+			}
+			if got.Method != http.MethodPost {
+				t.Errorf("method = %s, want POST", got.Method)
+			}
+			if h := got.Header.Get(EventHeader); h != string(store.WebhookWorkoutCreated) {
+				t.Errorf("%s = %q", EventHeader, h)
+			}
+			if h := got.Header.Get(EventIDHeader); h != "evt_1" {
+				t.Errorf("%s = %q", EventIDHeader, h)
+			}
+			if h := got.Header.Get(DeliveryHeader); h != "42" {
+				t.Errorf("%s = %q", DeliveryHeader, h)
+			}
+			if string(gotBody) != string(payload) {
+				t.Errorf("body = %s, want %s", gotBody, payload)
+			}
+			if err := Verify(secret, got.Header.Get(SignatureHeader), gotBody, time.Minute); err != nil {
+				t.Errorf("receiver could not verify the signature: %v", err)
+			}
+
+			attempt, ok := webhookStore.attempts[42]
+			if !ok {
+				t.Fatal("no attempt was recorded")
+			}
+			if attempt.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", attempt.Status, tt.wantStatus)
+			}
+			if attempt.ResponseStatus == nil || *attempt.ResponseStatus != tt.wantResponse {
+				t.Errorf("response status = %v, want %d", attempt.ResponseStatus, tt.wantResponse)
+			}
+			if tt.wantStatus != store.WebhookSucceeded && attempt.Error == "" {
+				t.Error("failed attempt has no error")
+			}
+			if tt.wantRetry {
+				wait := attempt.NextAttemptAt.Sub(start)
+				want := d.Backoff(tt.attempts + 1)
+				if wait < want*9/10 || wait > want*12/10 {
+					t.Errorf("next attempt in %v, want about %v", wait, want)
+				}
+			} else if !attempt.NextAttemptAt.IsZero() {
+				t.Errorf("next attempt = %v, want none", attempt.NextAttemptAt)
+			}
+		})
+	}
+}
+
+func TestDispatcherRetriesUntilDelivered(t *testing.T) {
+	var calls int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	delivery := store.WebhookDelivery{ID: 1, EventID: "evt_1", Payload: json.RawMessage(`{}`), URL: receiver.URL, Secret: "s"}
+	webhookStore := &memoryWebhookStore{}
+	d := newTestDispatcher(webhookStore)
+
+	for want := 1; want <= 3; want++ {
+		// feed the delivery back as the store would once it is due again
+		webhookStore.deliveries = append(webhookStore.deliveries, delivery)
+		if _, err := d.DeliverDue(context.Background()); err != nil {
+			t.Fatalf("DeliverDue: %v", err)
+		}
+		if calls != want {
+			t.Fatalf("receiver called %d times, want %d", calls, want)
+		}
+
+		attempt := webhookStore.attempts[delivery.ID]
+		if want < 3 && attempt.Status != store.WebhookPending {
+			t.Fatalf("attempt %d status = %s, want pending", want, attempt.Status)
+		}
+		if want == 3 && attempt.Status != store.WebhookSucceeded {
+			t.Fatalf("attempt %d status = %s, want succeeded", want, attempt.Status)
+		}
+		delivery.Attempts++
+	}
+}
+
+func TestDispatcherRefusesLoopback(t *testing.T) {
+	var called bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer receiver.Close()
+
+	webhookStore := &memoryWebhookStore{deliveries: []store.WebhookDelivery{{ID: 1, Payload: json.RawMessage(`{}`), URL: receiver.URL}}}
+	d := NewDispatcher(webhookStore, log.New(io.Discard, "", 0))
+
+	if _, err := d.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	if called {
+		t.Fatal("dispatcher connected to a loopback address")
+	}
+	attempt := webhookStore.attempts[1]
+	if attempt.Status != store.WebhookPending || attempt.ResponseStatus != nil {
+		t.Fatalf("attempt = %+v, want a pending retry without a response", attempt)
+	}
+}
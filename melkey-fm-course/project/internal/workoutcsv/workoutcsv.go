@@ -0,0 +1,626 @@
+package workoutcsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+)
+
+type Format string
+
+const (
+	FormatNative Format = "native"
+	FormatStrong Format = "strong"
+	FormatHevy   Format = "hevy"
+)
+
+// Header is the column layout written by Writer and read back by Parse. Each
+// row describes one entry; the workout columns repeat for every entry.
+var Header = []string{
+	"workout_id",
+	"date",
+	"title",
+	"description",
+	"duration_seconds",
+	"calories_burned",
+	"exercise_name",
+	"sets",
+	"reps",
+	"entry_duration_seconds",
+	"weight",
+	"notes",
+	"order_index",
+}
+
+const kgPerLb = 0.45359237
+
+var ErrUnknownFormat = errors.New("unrecognised CSV header, expected this API's export or a Strong/Hevy export")
+
+type Writer struct {
+	w *csv.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: csv.NewWriter(w)}
+}
+
+func (cw *Writer) WriteHeader() error {
+	return cw.w.Write(Header)
+}
+
+// WriteWorkout writes one row per entry. Workouts without entries still get a
+// row so they survive a round trip.
+func (cw *Writer) WriteWorkout(workout *store.Workout) error {
+	base := []string{
+		strconv.Itoa(workout.ID),
+		workout.PerformedAt.Format(time.RFC3339),
+		workout.Title,
+		workout.Description,
+		strconv.Itoa(workout.DurationSeconds),
+		formatIntPtr(workout.CaloriesBurned),
+	}
+
+	if len(workout.Entries) == 0 {
+		return cw.w.Write(append(base, "", "", "", "", "", "", ""))
+	}
+
+	for _, entry := range workout.Entries {
+		weight := ""
+		if entry.Weight != nil {
+			weight = strconv.FormatFloat(*entry.Weight, 'f', -1, 64)
+		}
+
+		row := append(append([]string{}, base...),
+			entry.ExerciseName,
+			strconv.Itoa(entry.Sets),
+			formatIntPtr(entry.Reps),
+			formatIntPtr(entry.DurationSeconds),
+			weight,
+			entry.Notes,
+			strconv.Itoa(entry.OrderIndex),
+		)
+		err := cw.w.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cw *Writer) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type RowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+type Result struct {
+	Format   Format           `json:"format"`
+	Workouts []*store.Workout `json:"-"`
+	Errors   []RowError       `json:"errors"`
+}
+
+// Parse reads a CSV in any of the supported formats. Row level problems are
+// collected in Result.Errors rather than aborting, so callers can report every
+// bad row at once; the returned error is only for unreadable input. Dates
+// without an explicit offset are read in loc.
+func Parse(r io.Reader, loc *time.Location) (*Result, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+
+	p := &parser{columns: columns, loc: loc, result: &Result{Errors: []RowError{}}}
+	switch {
+	case p.has("title", "exercise_name", "sets"):
+		p.result.Format = FormatNative
+	case p.has("workout name", "exercise name", "set order"):
+		p.result.Format = FormatStrong
+	case p.has("title", "start_time", "exercise_title"):
+		p.result.Format = FormatHevy
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	row := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		row++
+		if err != nil {
+			p.fail(row, "", err.Error())
+			continue
+		}
+
+		p.record = record
+		p.row = row
+		switch p.result.Format {
+		case FormatNative:
+			p.nativeRow()
+		case FormatStrong:
+			p.strongRow()
+		case FormatHevy:
+			p.hevyRow()
+		}
+	}
+	p.finishEntry()
+
+	for _, workout := range p.result.Workouts {
+		if len(workout.Entries) == 0 && p.result.Format != FormatNative {
+			p.fail(p.workoutRows[workout], "", "workout has no sets with reps or duration")
+		}
+	}
+
+	return p.result, nil
+}
+
+type parser struct {
+	columns map[string]int
+	loc     *time.Location
+	result  *Result
+
+	record []string
+	row    int
+
+	// grouping state, keyed by the columns that identify a workout
+	workouts    map[string]*store.Workout
+	workoutRows map[*store.Workout]int
+	current     *store.Workout
+
+	// set level formats accumulate consecutive sets of one exercise
+	sets []setRow
+}
+
+type setRow struct {
+	exercise string
+	weight   *float64
+	reps     int
+	seconds  int
+	notes    string
+}
+
+func (p *parser) has(names ...string) bool {
+	for _, name := range names {
+		if _, ok := p.columns[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) get(name string) string {
+	i, ok := p.columns[name]
+	if !ok || i >= len(p.record) {
+		return ""
+	}
+	return strings.TrimSpace(p.record[i])
+}
+
+func (p *parser) fail(row int, column, message string) {
+	p.result.Errors = append(p.result.Errors, RowError{Row: row, Column: column, Message: message})
+}
+
+// workout returns the workout identified by key, creating it from build on
+// first sight. Rows for one workout do not have to be contiguous.
+func (p *parser) workout(key string, build func() *store.Workout) *store.Workout {
+	if p.workouts == nil {
+		p.workouts = map[string]*store.Workout{}
+		p.workoutRows = map[*store.Workout]int{}
+	}
+
+	workout, ok := p.workouts[key]
+	if !ok {
+		workout = build()
+		workout.Entries = []store.WorkoutEntry{}
+		p.workouts[key] = workout
+		p.workoutRows[workout] = p.row
+		p.result.Workouts = append(p.result.Workouts, workout)
+	}
+	return workout
+}
+
+func (p *parser) nativeRow() {
+	ok := true
+	title := p.get("title")
+	if title == "" {
+		p.fail(p.row, "title", "title is required")
+		ok = false
+	}
+
+	var performedAt time.Time
+	if value := p.get("date"); value != "" {
+		t, err := parseTime(value, p.loc, time.RFC3339, time.DateTime, time.DateOnly)
+		if err != nil {
+			p.fail(p.row, "date", "expected RFC 3339 or YYYY-MM-DD")
+			ok = false
+		}
+		performedAt = t
+	}
+
+	durationCol, durationFactor := p.durationColumn("duration_seconds", "duration_minutes")
+	duration, durationOK := p.int(durationCol, true)
+	duration *= durationFactor
+	calories, caloriesOK := p.optionalInt("calories_burned")
+	ok = ok && durationOK && caloriesOK
+
+	exercise := p.get("exercise_name")
+	var entry *store.WorkoutEntry
+	if exercise != "" {
+		sets, setsOK := p.int("sets", true)
+		reps, repsOK := p.optionalInt("reps")
+		secondsCol, secondsFactor := p.durationColumn("entry_duration_seconds", "entry_duration_minutes")
+		seconds, secondsOK := p.optionalInt(secondsCol)
+		weight, weightOK := p.optionalWeight("weight", 1)
+		orderIndex, orderOK := p.optionalInt("order_index")
+		ok = ok && setsOK && repsOK && secondsOK && weightOK && orderOK
+		if seconds != nil {
+			*seconds *= secondsFactor
+		}
+
+		if setsOK && sets < 1 {
+			p.fail(p.row, "sets", "sets must be at least 1")
+			ok = false
+		}
+		if repsOK && secondsOK && (reps == nil) == (seconds == nil) {
+			p.fail(p.row, "reps", "exactly one of reps and "+secondsCol+" is required")
+			ok = false
+		}
+
+		entry = &store.WorkoutEntry{
+			ExerciseName:    exercise,
+			Sets:            sets,
+			Reps:            reps,
+			DurationSeconds: seconds,
+			Weight:          weight,
+			Notes:           p.get("notes"),
+		}
+		if orderIndex != nil {
+			entry.OrderIndex = *orderIndex
+		} else {
+			entry.OrderIndex = -1
+		}
+	} else if p.get("sets") != "" || p.get("reps") != "" || p.get("weight") != "" {
+		p.fail(p.row, "exercise_name", "exercise_name is required when entry columns are set")
+		ok = false
+	}
+
+	if !ok {
+		return
+	}
+
+	key := p.get("workout_id")
+	if key == "" {
+		key = p.get("date") + "\x00" + title
+	}
+	workout := p.workout(key, func() *store.Workout {
+		return &store.Workout{
+			Title:           title,
+			Description:     p.get("description"),
+			DurationSeconds: duration,
+			CaloriesBurned:  calories,
+			PerformedAt:     performedAt,
+		}
+	})
+
+	if entry != nil {
+		if entry.OrderIndex < 0 {
+			entry.OrderIndex = len(workout.Entries)
+		}
+		workout.Entries = append(workout.Entries, *entry)
+	}
+}
+
+// strongRow handles the Strong app export, which has one row per set:
+// Date, Workout Name, Duration, Exercise Name, Set Order, Weight, Reps,
+// Distance, Seconds, Notes, Workout Notes, RPE.
+func (p *parser) strongRow() {
+	started, err := parseTime(p.get("date"), p.loc, time.DateTime, "2006-01-02 15:04", time.RFC3339)
+	if err != nil {
+		p.fail(p.row, "Date", "expected YYYY-MM-DD HH:MM:SS")
+		return
+	}
+
+	title := p.get("workout name")
+	if title == "" {
+		p.fail(p.row, "Workout Name", "workout name is required")
+		return
+	}
+
+	duration, err := parseStrongDuration(p.get("duration"))
+	if err != nil || duration < 0 {
+		p.fail(p.row, "Duration", "expected a duration such as 1h 5m")
+		return
+	}
+
+	factor := 1.0
+	if unit := strings.ToLower(p.get("weight unit")); unit == "lbs" || unit == "lb" {
+		factor = kgPerLb
+	}
+
+	set, ok := p.setRow("exercise name", "weight", "reps", "seconds", "notes", factor)
+	if !ok {
+		return
+	}
+
+	workout := p.workout(p.get("date")+"\x00"+title, func() *store.Workout {
+		return &store.Workout{
+			Title:           title,
+			Description:     p.get("workout notes"),
+			DurationSeconds: duration,
+			PerformedAt:     started,
+		}
+	})
+	p.addSet(workout, set)
+}
+
+// hevyRow handles the Hevy export, which has one row per set with
+// title, start_time, end_time, description, exercise_title, set_index,
+// weight_kg (or weight_lbs), reps, duration_seconds and exercise_notes.
+func (p *parser) hevyRow() {
+	layouts := []string{"2 Jan 2006, 15:04", "02 Jan 2006, 15:04", time.RFC3339, time.DateTime}
+	started, err := parseTime(p.get("start_time"), p.loc, layouts...)
+	if err != nil {
+		p.fail(p.row, "start_time", "expected a date such as 15 Jan 2024, 18:30")
+		return
+	}
+
+	duration := 0
+	if value := p.get("end_time"); value != "" {
+		ended, err := parseTime(value, p.loc, layouts...)
+		if err != nil {
+			p.fail(p.row, "end_time", "expected a date such as 15 Jan 2024, 18:30")
+			return
+		}
+		if ended.Before(started) {
+			p.fail(p.row, "end_time", "end_time cannot be before start_time")
+			return
+		}
+		duration = int(ended.Sub(started).Seconds())
+	}
+
+	title := p.get("title")
+	if title == "" {
+		p.fail(p.row, "title", "title is required")
+		return
+	}
+
+	weightColumn, factor := "weight_kg", 1.0
+	if !p.has("weight_kg") && p.has("weight_lbs") {
+		weightColumn, factor = "weight_lbs", kgPerLb
+	}
+
+	set, ok := p.setRow("exercise_title", weightColumn, "reps", "duration_seconds", "exercise_notes", factor)
+	if !ok {
+		return
+	}
+
+	workout := p.workout(p.get("start_time")+"\x00"+title, func() *store.Workout {
+		return &store.Workout{
+			Title:           title,
+			Description:     p.get("description"),
+			DurationSeconds: duration,
+			PerformedAt:     started,
+		}
+	})
+	p.addSet(workout, set)
+}
+
+func (p *parser) setRow(exerciseCol, weightCol, repsCol, secondsCol, notesCol string, factor float64) (setRow, bool) {
+	set := setRow{exercise: p.get(exerciseCol), notes: p.get(notesCol)}
+	if set.exercise == "" {
+		p.fail(p.row, exerciseCol, "exercise name is required")
+		return set, false
+	}
+
+	weight, ok := p.optionalWeight(weightCol, factor)
+	if !ok {
+		return set, false
+	}
+	set.weight = weight
+
+	reps, ok := p.optionalFloat(repsCol)
+	if !ok {
+		return set, false
+	}
+	seconds, ok := p.optionalFloat(secondsCol)
+	if !ok {
+		return set, false
+	}
+	set.reps = int(reps)
+	set.seconds = int(seconds)
+	return set, true
+}
+
+// addSet appends a set to the running entry, starting a new entry whenever the
+// workout or exercise changes.
+func (p *parser) addSet(workout *store.Workout, set setRow) {
+	if p.current != workout || (len(p.sets) > 0 && p.sets[0].exercise != set.exercise) {
+		p.finishEntry()
+		p.current = workout
+	}
+	p.sets = append(p.sets, set)
+}
+
+// finishEntry collapses the accumulated sets into a single compact entry. The
+// entry keeps the heaviest set's reps and weight; sets with neither reps nor
+// duration (rest timers, skipped sets) are dropped.
+func (p *parser) finishEntry() {
+	defer func() { p.sets = nil }()
+	if p.current == nil || len(p.sets) == 0 {
+		return
+	}
+
+	var top *setRow
+	count, seconds := 0, 0
+	notes := []string{}
+	for i := range p.sets {
+		set := &p.sets[i]
+		if set.reps <= 0 && set.seconds <= 0 {
+			continue
+		}
+		count++
+		seconds += set.seconds
+		if set.notes != "" && !slices.Contains(notes, set.notes) {
+			notes = append(notes, set.notes)
+		}
+		if top == nil || weightOf(set) > weightOf(top) || (weightOf(set) == weightOf(top) && set.reps > top.reps) {
+			top = set
+		}
+	}
+	if count == 0 {
+		return
+	}
+
+	entry := store.WorkoutEntry{
+		ExerciseName: top.exercise,
+		Sets:         count,
+		Weight:       top.weight,
+		Notes:        strings.Join(notes, "; "),
+		OrderIndex:   len(p.current.Entries),
+	}
+	if top.reps > 0 {
+		reps := top.reps
+		entry.Reps = &reps
+	} else {
+		entry.DurationSeconds = &seconds
+	}
+	p.current.Entries = append(p.current.Entries, entry)
+}
+
+// durationColumn picks the column a duration in seconds is read from, along
+// with the factor that converts it to seconds: exports made before durations
+// were kept in seconds have minutesCol instead.
+func (p *parser) durationColumn(secondsCol, minutesCol string) (string, int) {
+	if !p.has(secondsCol) && p.has(minutesCol) {
+		return minutesCol, 60
+	}
+	return secondsCol, 1
+}
+
+func (p *parser) int(column string, required bool) (int, bool) {
+	value := p.get(column)
+	if value == "" {
+		if required {
+			p.fail(p.row, column, column+" is required")
+			return 0, false
+		}
+		return 0, true
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		p.fail(p.row, column, column+" must be a non-negative whole number")
+		return 0, false
+	}
+	return n, true
+}
+
+func (p *parser) optionalInt(column string) (*int, bool) {
+	if p.get(column) == "" {
+		return nil, true
+	}
+	n, ok := p.int(column, true)
+	if !ok {
+		return nil, false
+	}
+	return &n, true
+}
+
+func (p *parser) optionalFloat(column string) (float64, bool) {
+	value := p.get(column)
+	if value == "" {
+		return 0, true
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		p.fail(p.row, column, column+" must be a non-negative number")
+		return 0, false
+	}
+	return f, true
+}
+
+// optionalWeight parses a weight and converts it to kg with factor. Zero is
+// treated as "no weight" since the lifting apps export bodyweight sets as 0.
+func (p *parser) optionalWeight(column string, factor float64) (*float64, bool) {
+	f, ok := p.optionalFloat(column)
+	if !ok || f == 0 {
+		return nil, ok
+	}
+
+	f *= factor
+	if f > store.MaxWeightKg {
+		p.fail(p.row, column, fmt.Sprintf("weight cannot exceed %v", store.MaxWeightKg))
+		return nil, false
+	}
+	f = math.Round(f*100) / 100
+	return &f, true
+}
+
+func parseTime(value string, loc *time.Location, layouts ...string) (time.Time, error) {
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		t, err = time.ParseInLocation(layout, value, loc)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseStrongDuration reads Strong's "1h 5m" style durations into seconds.
+func parseStrongDuration(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		return n, nil
+	}
+
+	d, err := time.ParseDuration(strings.ReplaceAll(value, " ", ""))
+	if err != nil {
+		return 0, err
+	}
+	return int(d.Seconds()), nil
+}
+
+func weightOf(set *setRow) float64 {
+	if set.weight == nil {
+		return 0
+	}
+	return *set.weight
+}
+
+func formatIntPtr(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
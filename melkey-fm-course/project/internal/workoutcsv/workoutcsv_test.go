@@ -0,0 +1,326 @@
+package workoutcsv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/store"
+)
+
+// summarize renders parsed workouts one line per workout and entry, which
+// keeps the expectations below readable.
+func summarize(workouts []*store.Workout) []string {
+	lines := []string{}
+	for _, w := range workouts {
+		line := fmt.Sprintf("%s | %s | %ds", w.Title, w.PerformedAt.Format(time.RFC3339), w.DurationSeconds)
+		if w.CaloriesBurned != nil {
+			line += fmt.Sprintf(" | %dkcal", *w.CaloriesBurned)
+		}
+		if w.Description != "" {
+			line += " | " + w.Description
+		}
+		lines = append(lines, line)
+
+		for _, e := range w.Entries {
+			line := fmt.Sprintf("  %d. %s %dx", e.OrderIndex, e.ExerciseName, e.Sets)
+			if e.Reps != nil {
+				line += fmt.Sprintf("%d", *e.Reps)
+			}
+			if e.DurationSeconds != nil {
+				line += fmt.Sprintf("%ds", *e.DurationSeconds)
+			}
+			if e.Weight != nil {
+				line += fmt.Sprintf(" @%gkg", *e.Weight)
+			}
+			if e.Notes != "" {
+				line += " (" + e.Notes + ")"
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		csv        string
+		wantFormat Format
+		want       []string
+		wantErrors []RowError
+	}{
+		{
+			name: "native",
+			csv: "workout_id,date,title,description,duration_seconds,calories_burned,exercise_name,sets,reps,entry_duration_seconds,weight,notes,order_index\n" +
+				"1,2024-01-15T18:30:00Z,Leg day,heavy,3900,450,Squat,3,5,,100,,0\n" +
+				"1,2024-01-15T18:30:00Z,Leg day,heavy,3900,450,Plank,2,,45,,,1\n" +
+				"2,2024-01-16,Rest,,0,,,,,,,,\n",
+			wantFormat: FormatNative,
+			want: []string{
+				"Leg day | 2024-01-15T18:30:00Z | 3900s | 450kcal | heavy",
+				"  0. Squat 3x5 @100kg",
+				"  1. Plank 2x45s",
+				"Rest | 2024-01-16T00:00:00Z | 0s",
+			},
+		},
+		{
+			name: "native without ids groups by date and title, in any row order",
+			csv: "date,title,duration_seconds,exercise_name,sets,reps\n" +
+				"2024-01-15 18:30:00,Push,1800,Bench,3,8\n" +
+				"2024-01-15 18:30:00,Pull,1800,Row,3,10\n" +
+				"2024-01-15 18:30:00,Push,1800,Dip,2,12\n",
+			wantFormat: FormatNative,
+			want: []string{
+				"Push | 2024-01-15T18:30:00Z | 1800s",
+				"  0. Bench 3x8",
+				"  1. Dip 2x12",
+				"Pull | 2024-01-15T18:30:00Z | 1800s",
+				"  0. Row 3x10",
+			},
+		},
+		{
+			name: "native with the legacy minute columns",
+			csv: "date,title,duration_minutes,exercise_name,sets,reps,entry_duration_minutes\n" +
+				"2024-01-15,Run,45,Intervals,4,,3\n",
+			wantFormat: FormatNative,
+			want: []string{
+				"Run | 2024-01-15T00:00:00Z | 2700s",
+				"  0. Intervals 4x180s",
+			},
+		},
+		{
+			name: "native row errors are collected",
+			csv: "date,title,duration_seconds,exercise_name,sets,reps,entry_duration_seconds,weight\n" +
+				"2024-01-15,,60,,,,,\n" +
+				"yesterday,Run,60,,,,,\n" +
+				"2024-01-15,Run,-1,,,,,\n" +
+				"2024-01-15,Lift,60,Squat,0,5,,\n" +
+				"2024-01-15,Lift,60,Squat,3,5,30,\n" +
+				"2024-01-15,Lift,60,Squat,3,5,,1000\n" +
+				"2024-01-15,Lift,60,,3,,,\n" +
+				"2024-01-15,Lift,,Squat,3,5,,\n",
+			wantFormat: FormatNative,
+			want:       []string{},
+			wantErrors: []RowError{
+				{Row: 2, Column: "title", Message: "title is required"},
+				{Row: 3, Column: "date", Message: "expected RFC 3339 or YYYY-MM-DD"},
+				{Row: 4, Column: "duration_seconds", Message: "duration_seconds must be a non-negative whole number"},
+				{Row: 5, Column: "sets", Message: "sets must be at least 1"},
+				{Row: 6, Column: "reps", Message: "exactly one of reps and entry_duration_seconds is required"},
+				{Row: 7, Column: "weight", Message: "weight cannot exceed 999.99"},
+				{Row: 8, Column: "exercise_name", Message: "exercise_name is required when entry columns are set"},
+				{Row: 9, Column: "duration_seconds", Message: "duration_seconds is required"},
+			},
+		},
+		{
+			name: "strong",
+			csv: "\ufeffDate,Workout Name,Duration,Exercise Name,Set Order,Weight,Weight Unit,Reps,Distance,Seconds,Notes,Workout Notes,RPE\n" +
+				"2024-01-15 18:30:00,Evening,1h 5m,Squat,1,60,kg,8,,,,felt good,\n" +
+				"2024-01-15 18:30:00,Evening,1h 5m,Squat,2,100,kg,5,,,grind,felt good,\n" +
+				"2024-01-15 18:30:00,Evening,1h 5m,Squat,3,100,kg,6,,,,felt good,\n" +
+				"2024-01-15 18:30:00,Evening,1h 5m,Squat,4,0,kg,0,,,,felt good,\n" +
+				"2024-01-15 18:30:00,Evening,1h 5m,Plank,1,0,kg,0,,60,,felt good,\n" +
+				"2024-01-15 18:30:00,Evening,1h 5m,Plank,2,0,kg,0,,45,,felt good,\n" +
+				"2024-01-17 07:00,Morning,30m,Bench Press,1,135,lbs,10,,,,,\n",
+			wantFormat: FormatStrong,
+			want: []string{
+				"Evening | 2024-01-15T18:30:00Z | 3900s | felt good",
+				"  0. Squat 3x6 @100kg (grind)",
+				"  1. Plank 2x105s",
+				"Morning | 2024-01-17T07:00:00Z | 1800s",
+				"  0. Bench Press 1x10 @61.23kg",
+			},
+		},
+		{
+			name: "strong workout with only empty sets",
+			csv: "Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Seconds\n" +
+				"2024-01-15 18:30:00,Skipped,10m,Squat,1,0,0,0\n" +
+				"2024-01-15 18:30:00,Skipped,,Squat,2,,,\n" +
+				"someday,Bad,10m,Squat,1,0,5,0\n" +
+				"2024-01-15 18:30:00,Bad,ten minutes,Squat,1,0,5,0\n",
+			wantFormat: FormatStrong,
+			want: []string{
+				"Skipped | 2024-01-15T18:30:00Z | 600s",
+			},
+			wantErrors: []RowError{
+				{Row: 4, Column: "Date", Message: "expected YYYY-MM-DD HH:MM:SS"},
+				{Row: 5, Column: "Duration", Message: "expected a duration such as 1h 5m"},
+				{Row: 2, Message: "workout has no sets with reps or duration"},
+			},
+		},
+		{
+			name: "hevy in kg",
+			csv: `"title","start_time","end_time","description","exercise_title","superset_id","exercise_notes","set_index","set_type","weight_kg","reps","distance_km","duration_seconds","rpe"` + "\n" +
+				`"Upper","15 Jan 2024, 18:30","15 Jan 2024, 19:35","","Bench Press (Barbell)","","","0","warmup","40","10","","",""` + "\n" +
+				`"Upper","15 Jan 2024, 18:30","15 Jan 2024, 19:35","","Bench Press (Barbell)","","","1","normal","80","5","","",""` + "\n" +
+				`"Upper","15 Jan 2024, 18:30","15 Jan 2024, 19:35","","Pull Up","","slow","0","normal","","8","","",""` + "\n" +
+				`"Upper","15 Jan 2024, 18:30","15 Jan 2024, 19:35","","Bench Press (Barbell)","","","2","normal","80","4","","",""` + "\n",
+			wantFormat: FormatHevy,
+			want: []string{
+				"Upper | 2024-01-15T18:30:00Z | 3900s",
+				"  0. Bench Press (Barbell) 2x5 @80kg",
+				"  1. Pull Up 1x8 (slow)",
+				"  2. Bench Press (Barbell) 1x4 @80kg",
+			},
+		},
+		{
+			name: "hevy in lbs",
+			csv: "title,start_time,end_time,exercise_title,weight_lbs,reps,duration_seconds\n" +
+				`Legs,"2 Feb 2024, 07:05",,Squat,225,5,` + "\n" +
+				`Legs,"2 Feb 2024, 07:05",,Wall Sit,,,90` + "\n" +
+				`Legs,"2 Feb 2024, 08:00","2 Feb 2024, 07:00",Squat,225,5,` + "\n",
+			wantFormat: FormatHevy,
+			want: []string{
+				"Legs | 2024-02-02T07:05:00Z | 0s",
+				"  0. Squat 1x5 @102.06kg",
+				"  1. Wall Sit 1x90s",
+			},
+			wantErrors: []RowError{
+				{Row: 4, Column: "end_time", Message: "end_time cannot be before start_time"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Parse(strings.NewReader(tt.csv), time.UTC)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if result.Format != tt.wantFormat {
+				t.Errorf("Format = %s, want %s", result.Format, tt.wantFormat)
+			}
+			if got := summarize(result.Workouts); !slices.Equal(got, tt.want) {
+				t.Errorf("workouts:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			wantErrors := tt.wantErrors
+			if wantErrors == nil {
+				wantErrors = []RowError{}
+			}
+			if !slices.Equal(result.Errors, wantErrors) {
+				t.Errorf("errors:\n%+v\nwant:\n%+v", result.Errors, wantErrors)
+			}
+		})
+	}
+}
+
+func TestParseUsesLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	csv := "date,title,duration_seconds,exercise_name,sets\n" +
+		"2024-01-15 18:30:00,Local,60,,\n" +
+		"2024-01-15T18:30:00Z,Explicit,60,,\n"
+	result, err := Parse(strings.NewReader(csv), berlin)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := []time.Time{
+		time.Date(2024, 1, 15, 17, 30, 0, 0, time.UTC),
+		time.Date(2024, 1, 15, 18, 30, 0, 0, time.UTC),
+	}
+	for i, w := range result.Workouts {
+		if !w.PerformedAt.Equal(want[i]) {
+			t.Errorf("%s performed at %v, want %v", w.Title, w.PerformedAt, want[i])
+		}
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	tests := []string{
+		"",
+		"foo,bar\n1,2\n",
+		"title,sets\nLeg day,3\n",
+	}
+
+	for _, csv := range tests {
+		if _, err := Parse(strings.NewReader(csv), time.UTC); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("Parse(%q) = %v, want ErrUnknownFormat", csv, err)
+		}
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	reps, seconds, calories := 5, 45, 300
+	weight := 102.5
+	workouts := []*store.Workout{
+		{
+			ID:              1,
+			Title:           "Leg day, again",
+			Description:     "quotes \"and\" commas",
+			DurationSeconds: 3725,
+			CaloriesBurned:  &calories,
+			PerformedAt:     time.Date(2024, 1, 15, 18, 30, 0, 0, time.UTC),
+			Entries: []store.WorkoutEntry{
+				{ExerciseName: "Squat", Sets: 3, Reps: &reps, Weight: &weight, OrderIndex: 0},
+				{ExerciseName: "Plank", Sets: 2, DurationSeconds: &seconds, Notes: "each side", OrderIndex: 1},
+			},
+		},
+		{
+			ID:              2,
+			Title:           "Rest",
+			DurationSeconds: 0,
+			PerformedAt:     time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteHeader(); err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+	for _, workout := range workouts {
+		if err := w.WriteWorkout(workout); err != nil {
+			t.Fatalf("WriteWorkout: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	result, err := Parse(&buf, time.UTC)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		t.Fatalf("round trip produced errors: %+v", result.Errors)
+	}
+	if got, want := summarize(result.Workouts), summarize(workouts); !slices.Equal(got, want) {
+		t.Errorf("round trip:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseStrongDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"90", 90, false},
+		{"45m", 2700, false},
+		{"1h 5m", 3900, false},
+		{"1h 5m 30s", 3930, false},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseStrongDuration(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStrongDuration(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseStrongDuration(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
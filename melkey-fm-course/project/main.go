@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/HrushiBorhade/golang/melkey-fm-course/project/internal/app"
@@ -13,16 +18,48 @@ import (
 func main() {
 
 	var port int
+	cfg := app.Config{RateLimits: app.DefaultRateLimits}
+	var purgeInterval time.Duration
+	var webhookInterval time.Duration
+	var outboxInterval time.Duration
+	var shutdownTimeout time.Duration
 	flag.IntVar(&port, "port", 8080, "Go Backend Server Port")
+	flag.DurationVar(&cfg.TrashRetention, "trash-retention", 30*24*time.Hour, "How long deleted workouts can be restored")
+	flag.DurationVar(&purgeInterval, "trash-purge-interval", time.Hour, "How often expired trash is purged")
+	flag.DurationVar(&outboxInterval, "outbox-interval", time.Second, "How often the outbox is polled for new events")
+	flag.DurationVar(&cfg.OutboxRetention, "outbox-retention", 7*24*time.Hour, "How long delivered outbox events are kept")
+	flag.DurationVar(&webhookInterval, "webhook-interval", 5*time.Second, "How often queued webhook deliveries are sent")
+	flag.BoolVar(&cfg.RateLimit, "rate-limit", true, "Limit requests per user and per IP")
+	flag.Var(&cfg.RateLimits.API, "rate-limit-api", "Requests per user to the authenticated API (limit/window, 0 turns it off)")
+	flag.Var(&cfg.RateLimits.Client, "rate-limit-client", "Requests per IP to the authenticated API, checked before the token (limit/window, 0 turns it off)")
+	flag.Var(&cfg.RateLimits.Public, "rate-limit-public", "Requests per IP to calendar feeds and share links (limit/window, 0 turns it off)")
+	flag.Var(&cfg.RateLimits.Auth, "rate-limit-auth", "Requests per IP to registration and login (limit/window, 0 turns it off)")
+	flag.BoolVar(&cfg.TrustProxy, "trust-proxy", false, "Take client IPs from X-Forwarded-For/X-Real-IP (only behind a proxy)")
+	flag.StringVar(&cfg.SearchBackend, "search", "fulltext", "Search backend (fulltext|like)")
+	flag.StringVar(&cfg.Mail.From, "mail-from", "Workouts <no-reply@localhost>", "Sender address of outgoing email")
+	flag.StringVar(&cfg.Mail.SMTPHost, "smtp-host", "", "SMTP server; email is written to -mail-dir when empty")
+	flag.IntVar(&cfg.Mail.SMTPPort, "smtp-port", 587, "SMTP server port")
+	flag.StringVar(&cfg.Mail.SMTPUsername, "smtp-username", "", "SMTP username")
+	flag.StringVar(&cfg.Mail.Dir, "mail-dir", "mail", "Directory outgoing email is written to without an SMTP server")
+	// kept out of the flags so it does not show up in the process list
+	cfg.Mail.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "How long requests and running jobs get to finish on shutdown")
 	flag.Parse()
 
-	app, err := app.NewApplication()
+	app, err := app.NewApplication(cfg)
 	if err != nil {
 		panic(err)
 	}
 
 	defer app.DB.Close()
 
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	app.Start()
+	go app.RunTrashPurge(ctx, purgeInterval)
+	go app.RunOutboxRelay(ctx, outboxInterval)
+	go app.RunWebhookDeliveries(ctx, webhookInterval)
+
 	r := routes.SetUpRoutes(app)
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", port),
@@ -33,8 +70,32 @@ func main() {
 	}
 	app.Logger.Printf("App is Running on port : %d ✅\n", port)
 
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		app.Logger.Printf("shutting down")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err := server.Shutdown(shutdownCtx)
+		if err != nil {
+			app.Logger.Printf("ERROR: server shutdown: %v", err)
+		}
+	}()
+
 	err = server.ListenAndServe()
-	if err != nil {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		app.Logger.Fatal(err)
 	}
+	// ListenAndServe returns as soon as Shutdown starts
+	<-shutdownDone
+
+	// let running jobs finish before the database goes away
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	err = app.Shutdown(shutdownCtx)
+	if err != nil {
+		app.Logger.Printf("ERROR: background shutdown: %v", err)
+	}
 }
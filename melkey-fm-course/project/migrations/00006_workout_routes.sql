@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_routes (
+  workout_id BIGINT PRIMARY KEY REFERENCES workouts(id) ON DELETE CASCADE,
+  sport VARCHAR(50),
+  distance_meters DOUBLE PRECISION NOT NULL,
+  elevation_gain_meters DOUBLE PRECISION NOT NULL,
+  pace_seconds_per_km DOUBLE PRECISION,
+  -- WKB encoded LineString in lon/lat order
+  geometry BYTEA NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+)
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_routes;
+-- +goose StatementEnd
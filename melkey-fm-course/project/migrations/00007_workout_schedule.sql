@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workouts
+  ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMP WITH TIME ZONE,
+  ADD COLUMN IF NOT EXISTS recurrence TEXT,
+  ADD COLUMN IF NOT EXISTS planned_workout_id BIGINT REFERENCES workouts(id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS planned_for TIMESTAMP WITH TIME ZONE,
+  ADD CONSTRAINT recurrence_needs_schedule CHECK (recurrence IS NULL OR scheduled_at IS NOT NULL),
+  ADD CONSTRAINT planned_occurrence CHECK ((planned_workout_id IS NULL) = (planned_for IS NULL));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workouts_planned_occurrence ON workouts (planned_workout_id, planned_for);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_planned_occurrence;
+ALTER TABLE workouts
+  DROP CONSTRAINT IF EXISTS planned_occurrence,
+  DROP CONSTRAINT IF EXISTS recurrence_needs_schedule,
+  DROP COLUMN IF EXISTS planned_for,
+  DROP COLUMN IF EXISTS planned_workout_id,
+  DROP COLUMN IF EXISTS recurrence,
+  DROP COLUMN IF EXISTS scheduled_at;
+-- +goose StatementEnd
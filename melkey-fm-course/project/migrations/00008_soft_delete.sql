@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE workout_entries ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_workouts_deleted_at ON workouts (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- purging a plan sets planned_workout_id to NULL on the workouts that
+-- fulfilled it, so only require planned_for when a plan is referenced
+ALTER TABLE workouts
+  DROP CONSTRAINT IF EXISTS planned_occurrence,
+  ADD CONSTRAINT planned_occurrence CHECK (planned_workout_id IS NULL OR planned_for IS NOT NULL);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE workouts SET planned_for = NULL WHERE planned_workout_id IS NULL;
+ALTER TABLE workouts
+  DROP CONSTRAINT IF EXISTS planned_occurrence,
+  ADD CONSTRAINT planned_occurrence CHECK ((planned_workout_id IS NULL) = (planned_for IS NULL));
+
+DROP INDEX IF EXISTS idx_workouts_deleted_at;
+ALTER TABLE workout_entries DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE workouts DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
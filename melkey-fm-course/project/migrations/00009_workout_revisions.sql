@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_revisions (
+  id BIGSERIAL PRIMARY KEY,
+  -- no foreign key so the history outlives a purged workout
+  workout_id BIGINT NOT NULL,
+  owner_id BIGINT,
+  revision INTEGER NOT NULL,
+  action VARCHAR(20) NOT NULL,
+  snapshot JSONB NOT NULL,
+  actor_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+  request_id TEXT,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (workout_id, revision)
+);
+
+CREATE FUNCTION workout_revisions_immutable() RETURNS trigger AS $$
+BEGIN
+  -- the only change allowed is ON DELETE SET NULL when the actor is deleted
+  IF TG_OP = 'UPDATE' AND NEW.actor_id IS NULL
+    AND (NEW.id, NEW.workout_id, NEW.owner_id, NEW.revision, NEW.action, NEW.snapshot, NEW.request_id, NEW.created_at)
+      IS NOT DISTINCT FROM
+      (OLD.id, OLD.workout_id, OLD.owner_id, OLD.revision, OLD.action, OLD.snapshot, OLD.request_id, OLD.created_at) THEN
+    RETURN NEW;
+  END IF;
+  RAISE EXCEPTION 'workout revisions are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER workout_revisions_immutable
+  BEFORE UPDATE OR DELETE ON workout_revisions
+  FOR EACH ROW EXECUTE FUNCTION workout_revisions_immutable();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_revisions;
+DROP FUNCTION IF EXISTS workout_revisions_immutable();
+-- +goose StatementEnd
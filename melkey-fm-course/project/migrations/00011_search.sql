@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS search_vector tsvector
+  GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(description, '')), 'B')
+  ) STORED;
+
+-- a generated column cannot read other rows, so entry notes get their own
+-- vector and the search query combines the two
+ALTER TABLE workout_entries ADD COLUMN IF NOT EXISTS search_vector tsvector
+  GENERATED ALWAYS AS (setweight(to_tsvector('english', COALESCE(notes, '')), 'C')) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_workouts_search_vector ON workouts USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_workout_entries_search_vector ON workout_entries USING GIN (search_vector);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workout_entries_search_vector;
+DROP INDEX IF EXISTS idx_workouts_search_vector;
+ALTER TABLE workout_entries DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE workouts DROP COLUMN IF EXISTS search_vector;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- workouts were readable by anyone before visibility existed
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS visibility VARCHAR(10) NOT NULL DEFAULT 'public'
+  CONSTRAINT workout_visibility CHECK (visibility IN ('private', 'followers', 'public'));
+
+CREATE TABLE IF NOT EXISTS follows (
+  follower_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  followee_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  status VARCHAR(10) NOT NULL CHECK (status IN ('pending', 'accepted')),
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  accepted_at TIMESTAMP WITH TIME ZONE,
+  PRIMARY KEY (follower_id, followee_id),
+  CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follows_followee_id ON follows (followee_id, status);
+CREATE INDEX IF NOT EXISTS idx_workouts_feed ON workouts (user_id, created_at DESC, id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_feed;
+DROP TABLE follows;
+ALTER TABLE workouts DROP COLUMN IF EXISTS visibility;
+ALTER TABLE users DROP COLUMN IF EXISTS is_private;
+-- +goose StatementEnd
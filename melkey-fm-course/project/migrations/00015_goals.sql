@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS goals (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  kind VARCHAR(20) NOT NULL CHECK (kind IN ('workouts', 'calories', 'duration_minutes', 'lift')),
+  -- periodic goals reset every week or month, lift goals are met once
+  period VARCHAR(10) CHECK (period IN ('week', 'month')),
+  target DOUBLE PRECISION NOT NULL CHECK (target > 0),
+  exercise_name VARCHAR(255),
+  timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+  achieved_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT goal_shape CHECK (
+    (kind = 'lift' AND period IS NULL AND exercise_name IS NOT NULL) OR
+    (kind <> 'lift' AND period IS NOT NULL AND exercise_name IS NULL)
+  )
+);
+
+CREATE INDEX IF NOT EXISTS idx_goals_user_id ON goals (user_id);
+
+-- one row per period in which a periodic goal was met
+CREATE TABLE IF NOT EXISTS goal_achievements (
+  goal_id BIGINT NOT NULL REFERENCES goals(id) ON DELETE CASCADE,
+  period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+  achieved_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (goal_id, period_start)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE goal_achievements;
+DROP TABLE goals;
+-- +goose StatementEnd
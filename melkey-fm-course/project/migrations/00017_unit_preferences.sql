@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- weights are always stored in kilograms; the extra precision keeps values
+-- entered in pounds stable when they are converted back for display
+ALTER TABLE workout_entries ALTER COLUMN weight TYPE DECIMAL(7, 3);
+
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS weight_unit VARCHAR(2) NOT NULL DEFAULT 'kg' CHECK (weight_unit IN ('kg', 'lb')),
+  ADD COLUMN IF NOT EXISTS distance_unit VARCHAR(2) NOT NULL DEFAULT 'km' CHECK (distance_unit IN ('km', 'mi')),
+  ADD COLUMN IF NOT EXISTS weight_increment DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (weight_increment >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+  DROP COLUMN IF EXISTS weight_increment,
+  DROP COLUMN IF EXISTS distance_unit,
+  DROP COLUMN IF EXISTS weight_unit;
+
+ALTER TABLE workout_entries ALTER COLUMN weight TYPE DECIMAL(5, 2);
+-- +goose StatementEnd
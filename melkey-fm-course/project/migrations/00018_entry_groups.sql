@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_entry_groups (
+  id BIGSERIAL PRIMARY KEY,
+  workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+  kind VARCHAR(20) NOT NULL CHECK (kind IN ('superset', 'circuit', 'giant_set')),
+  rounds INTEGER NOT NULL DEFAULT 1 CHECK (rounds BETWEEN 1 AND 100),
+  rest_seconds INTEGER CHECK (rest_seconds >= 0),
+  -- shares one ordering with the workout's ungrouped entries
+  order_index INTEGER NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (id, workout_id)
+);
+
+-- the composite key keeps an entry from joining another workout's group
+ALTER TABLE workout_entries
+  ADD COLUMN IF NOT EXISTS group_id BIGINT,
+  ADD CONSTRAINT workout_entries_group_fkey FOREIGN KEY (group_id, workout_id)
+    REFERENCES workout_entry_groups (id, workout_id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_workout_entries_group_id ON workout_entries (group_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+  DROP CONSTRAINT IF EXISTS workout_entries_group_fkey,
+  DROP COLUMN IF EXISTS group_id;
+
+DROP TABLE workout_entry_groups;
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_sets (
+  id BIGSERIAL PRIMARY KEY,
+  entry_id BIGINT NOT NULL REFERENCES workout_entries(id) ON DELETE CASCADE,
+  set_number INTEGER NOT NULL CHECK (set_number >= 1),
+  set_type VARCHAR(10) NOT NULL DEFAULT 'working' CHECK (set_type IN ('warmup', 'working', 'drop', 'failure')),
+  reps INTEGER CHECK (reps >= 0),
+  weight DECIMAL(7, 3) CHECK (weight >= 0),
+  rpe NUMERIC(3, 1) CHECK (rpe BETWEEN 1 AND 10),
+  rir INTEGER CHECK (rir BETWEEN 0 AND 10),
+  rest_seconds INTEGER CHECK (rest_seconds >= 0),
+  UNIQUE (entry_id, set_number)
+);
+
+-- volume per entry, from its working sets when they were logged one by one
+-- and from the compact sets x reps x weight columns otherwise; warmups are
+-- left out of the totals
+CREATE OR REPLACE VIEW workout_entry_volume AS
+SELECT we.id AS entry_id,
+  COALESCE(s.sets, we.sets) AS sets,
+  COALESCE(s.reps, we.sets * COALESCE(we.reps, 0)) AS reps,
+  COALESCE(s.tonnage, we.sets * COALESCE(we.reps, 0) * COALESCE(we.weight, 0)) AS tonnage
+FROM workout_entries we
+LEFT JOIN (
+  SELECT entry_id,
+    COUNT(*) FILTER (WHERE set_type <> 'warmup') AS sets,
+    COALESCE(SUM(reps) FILTER (WHERE set_type <> 'warmup'), 0) AS reps,
+    COALESCE(SUM(COALESCE(reps, 0) * COALESCE(weight, 0)) FILTER (WHERE set_type <> 'warmup'), 0) AS tonnage
+  FROM workout_sets
+  GROUP BY entry_id
+) s ON s.entry_id = we.id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP VIEW workout_entry_volume;
+DROP TABLE workout_sets;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- goals carried their own zone until now; the newest one is the best guess
+-- for where the user lives
+UPDATE users u
+SET timezone = g.timezone
+FROM (
+  SELECT DISTINCT ON (user_id) user_id, timezone
+  FROM goals
+  ORDER BY user_id, created_at DESC
+) g
+WHERE g.user_id = u.id;
+
+ALTER TABLE goals DROP COLUMN IF EXISTS timezone;
+
+-- created_at stays the time the row was written; performed_at is when the
+-- workout happened, which imports and uploads used to store in created_at
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS performed_at TIMESTAMP WITH TIME ZONE;
+UPDATE workouts SET performed_at = COALESCE(created_at, CURRENT_TIMESTAMP) WHERE performed_at IS NULL;
+ALTER TABLE workouts
+  ALTER COLUMN performed_at SET DEFAULT CURRENT_TIMESTAMP,
+  ALTER COLUMN performed_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_workouts_user_performed_at ON workouts (user_id, performed_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_user_performed_at;
+ALTER TABLE workouts DROP COLUMN IF EXISTS performed_at;
+
+ALTER TABLE goals ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+UPDATE goals g SET timezone = u.timezone FROM users u WHERE u.id = g.user_id;
+
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
+-- +goose StatementEnd
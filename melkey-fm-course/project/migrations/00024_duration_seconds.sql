@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- durations are stored in seconds so that imported and tracked workouts keep
+-- their precision; workout_entries.duration_seconds already had the name but
+-- held minutes
+ALTER TABLE workouts RENAME COLUMN duration_minutes TO duration_seconds;
+UPDATE workouts SET duration_seconds = duration_seconds * 60;
+UPDATE workout_entries SET duration_seconds = duration_seconds * 60 WHERE duration_seconds IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE workout_entries SET duration_seconds = ROUND(duration_seconds / 60.0) WHERE duration_seconds IS NOT NULL;
+UPDATE workouts SET duration_seconds = ROUND(duration_seconds / 60.0);
+ALTER TABLE workouts RENAME COLUMN duration_seconds TO duration_minutes;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the feed is ordered by when workouts were performed, not when their rows
+-- were written
+DROP INDEX IF EXISTS idx_workouts_feed;
+CREATE INDEX IF NOT EXISTS idx_workouts_feed ON workouts (user_id, performed_at DESC, id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_feed;
+CREATE INDEX IF NOT EXISTS idx_workouts_feed ON workouts (user_id, created_at DESC, id DESC);
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- duration goals are measured in seconds like the workouts they count
+ALTER TABLE goals DROP CONSTRAINT IF EXISTS goals_kind_check;
+UPDATE goals SET kind = 'duration_seconds', target = target * 60 WHERE kind = 'duration_minutes';
+ALTER TABLE goals ADD CONSTRAINT goals_kind_check
+  CHECK (kind IN ('workouts', 'calories', 'duration_seconds', 'lift'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE goals DROP CONSTRAINT IF EXISTS goals_kind_check;
+UPDATE goals SET kind = 'duration_minutes', target = target / 60 WHERE kind = 'duration_seconds';
+ALTER TABLE goals ADD CONSTRAINT goals_kind_check
+  CHECK (kind IN ('workouts', 'calories', 'duration_minutes', 'lift'));
+-- +goose StatementEnd